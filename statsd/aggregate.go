@@ -0,0 +1,254 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultAggregateInterval is the aggregation window, if
+// ClientConfig.Aggregate is set without an AggregateInterval.
+const defaultAggregateInterval = time.Second
+
+// aggregateOverhead is the estimated memory used by a series, on top of its
+// name and tags, for ClientConfig.MaxBufferMemory.
+const aggregateOverhead = 128
+
+// aggregate is the value of a series so far in the window
+type aggregate struct {
+	// the first client to submit to the series, which submits the aggregate.
+	// any other would submit the same stat.
+	client *Client
+	stat   string
+	tags   []Tag
+	value  interface{}
+	// memory reserved for the series, when there is a budget
+	size int
+}
+
+// aggregator coalesces counters and gauges within a window (see
+// ClientConfig.Aggregate): counters are summed, and gauges keep their last
+// value. At the end of each window, one stat is submitted per series.
+type aggregator struct {
+	mx     sync.Mutex
+	counts map[string]*aggregate
+	gauges map[string]*aggregate
+	// memory reserved for the series so far, when there is a budget
+	budget   *memoryBudget
+	held     int
+	counters *clientCounters
+	// whether the window was already flushed early, to make room
+	flushedEarly bool
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newAggregator(interval time.Duration, budget *memoryBudget, counters *clientCounters) *aggregator {
+	if interval <= 0 {
+		interval = defaultAggregateInterval
+	}
+
+	a := &aggregator{
+		counts: make(map[string]*aggregate),
+		gauges: make(map[string]*aggregate),
+		done:   make(chan struct{}),
+
+		budget:   budget,
+		counters: counters,
+	}
+	go a.run(interval)
+	return a
+}
+
+// seriesKey identifies the series a stat is aggregated into, by the prefix,
+// name and tags (default, context and per-call) it is submitted with, so
+// stats to the same series from different SubStatters (eg. one per request,
+// from WithContext) are merged. Trace ID tags are sampled, so don't identify
+// a series.
+func seriesKey(s *Client, stat string, tags []Tag) string {
+	if len(s.tags) == 0 && len(s.ctxTags) == 0 {
+		return adjustKey(s.prefix, stat, tags)
+	}
+	merged := make([]Tag, 0, len(s.tags)+len(s.ctxTags)+len(tags))
+	merged = append(merged, s.tags...)
+	merged = append(merged, s.ctxTags...)
+	merged = append(merged, tags...)
+	return adjustKey(s.prefix, stat, merged)
+}
+
+// canAggregate reports whether a stat can be aggregated. Sampled stats, and
+// stats with per-call options (eg. a timestamp), are submitted as usual.
+func canAggregate(rate float32, tags []Tag) bool {
+	return rate >= 1 && !hasOptions(tags)
+}
+
+// addCount adds value to the sum for the counter series
+func (a *aggregator) addCount(s *Client, stat string, value int64, tags []Tag) error {
+	return a.add(s, stat, value, nil, tags)
+}
+
+// setGauge replaces the value for the gauge series
+func (a *aggregator) setGauge(s *Client, stat string, value interface{}, tags []Tag) error {
+	return a.add(s, stat, 0, value, tags)
+}
+
+// add adds count to a counter series, or replaces the value of a gauge
+// series if gauge is not nil, adding the series if it is new. New
+// series must fit in the memory budget, if there is one: if not, the
+// aggregates so far are flushed to make room (once per window), and if there
+// is still none, the stat is dropped and counted, returning ErrOverMemory if
+// the client is strict.
+func (a *aggregator) add(s *Client, stat string, count int64, gauge interface{}, tags []Tag) error {
+	key := seriesKey(s, stat, tags)
+
+	a.mx.Lock()
+	if a.update(key, count, gauge) {
+		a.mx.Unlock()
+		return nil
+	}
+
+	n := 0
+	if a.budget != nil {
+		// flushing takes the lock, so reserve without it
+		a.mx.Unlock()
+		n = len(key) + len(stat) + aggregateOverhead
+		if !a.reserve(n) {
+			atomic.AddInt64(&a.counters.droppedOverMemory, 1)
+			return s.dropped(ErrOverMemory)
+		}
+		a.mx.Lock()
+		if a.update(key, count, gauge) {
+			// added in the meantime
+			a.mx.Unlock()
+			a.budget.release(n)
+			return nil
+		}
+	}
+
+	if gauge != nil {
+		a.gauges[key] = &aggregate{s, stat, append([]Tag(nil), tags...), gauge, n}
+	} else {
+		a.counts[key] = &aggregate{s, stat, append([]Tag(nil), tags...), count, n}
+	}
+	a.held += n
+	a.mx.Unlock()
+	return nil
+}
+
+// update updates an existing counter or gauge series, as for add, and
+// reports whether there was one. Must be called with mx held.
+func (a *aggregator) update(key string, count int64, gauge interface{}) bool {
+	if gauge != nil {
+		agg, ok := a.gauges[key]
+		if ok {
+			agg.value = gauge
+		}
+		return ok
+	}
+
+	agg, ok := a.counts[key]
+	if ok {
+		agg.value = agg.value.(int64) + count
+	}
+	return ok
+}
+
+// reserve reserves n bytes of the memory budget, flushing the aggregates so
+// far to make room if needed, unless that was already done this window.
+// Otherwise, while others (eg. request batches) hold the memory, every new
+// series would flush them all. Reports whether there was room.
+func (a *aggregator) reserve(n int) bool {
+	if a.budget.reserve(n) {
+		return true
+	}
+
+	a.mx.Lock()
+	early := a.flushedEarly
+	a.flushedEarly = true
+	a.mx.Unlock()
+	if early {
+		return false
+	}
+	a.flush()
+	return a.budget.reserve(n)
+}
+
+// flushGauge submits, and clears, any value held for the gauge series, so
+// that a gauge update submitted straight away (eg. a delta) is applied after
+// it, not overwritten by it at the end of the window.
+func (a *aggregator) flushGauge(s *Client, stat string, tags []Tag) error {
+	key := seriesKey(s, stat, tags)
+
+	a.mx.Lock()
+	agg, ok := a.gauges[key]
+	if ok {
+		delete(a.gauges, key)
+		a.held -= agg.size
+	}
+	a.mx.Unlock()
+	if !ok {
+		return nil
+	}
+
+	a.budget.release(agg.size)
+	return agg.client.submit(agg.stat, "", agg.value, "|g", 1, agg.tags)
+}
+
+// flush submits, and clears, the aggregates so far. Returns the first error.
+func (a *aggregator) flush() error {
+	a.mx.Lock()
+	counts, gauges, held := a.counts, a.gauges, a.held
+	a.counts = make(map[string]*aggregate, len(counts))
+	a.gauges = make(map[string]*aggregate, len(gauges))
+	a.held = 0
+	a.mx.Unlock()
+	a.budget.release(held)
+
+	var err error
+	for _, agg := range counts {
+		if cerr := agg.client.submitCount(agg.stat, agg.value.(int64), 1, agg.tags); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	for _, agg := range gauges {
+		if gerr := agg.client.submit(agg.stat, "", agg.value, "|g", 1, agg.tags); gerr != nil && err == nil {
+			err = gerr
+		}
+	}
+	return err
+}
+
+// flushWindow flushes the aggregates at the end of a window, starting the
+// next one.
+func (a *aggregator) flushWindow() error {
+	a.mx.Lock()
+	a.flushedEarly = false
+	a.mx.Unlock()
+	return a.flush()
+}
+
+// stop stops the window flushes. It is safe to call more than once.
+func (a *aggregator) stop() {
+	a.once.Do(func() {
+		close(a.done)
+	})
+}
+
+func (a *aggregator) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+			a.flushWindow()
+		}
+	}
+}
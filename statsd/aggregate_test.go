@@ -0,0 +1,173 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		c.Inc("count", 1, 1.0)
+	}
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("aggregated stats should wait for the window, got %q", got)
+	}
+
+	c.(*Client).Flush()
+	expected := []string{"test.count:1000|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAggregateSeries(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	client.SetSamplerFunc(func(float32) bool { return true })
+
+	c.Inc("count", 5, 1.0)
+	c.Dec("count", 2, 1.0)
+	c.Inc("count", 1, 1.0, Tag{"tag1", "val1"})
+	c.Inc("count", 1, 1.0, Tag{"tag1", "val1"})
+	c.Gauge("gauge", 1, 1.0)
+	c.Gauge("gauge", 7, 1.0)
+	client.GaugeFloat("fgauge", 1.5, 1.0)
+	c.NewSubStatter("sub").Inc("count", 3, 1.0)
+
+	// sampled stats, other types, and stats with options are not aggregated
+	c.Inc("count", 1, 0.5)
+	c.Timing("timing", 5, 1.0)
+	c.Inc("count", 1, 1.0, WithTimestamp(time.Unix(1656581400, 0)))
+
+	unaggregated := []string{
+		"test.count:1|c|@0.500000",
+		"test.timing:5|ms",
+		"test.count:1|c|T1656581400",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, unaggregated) {
+		t.Fatalf("got %q expected %q", got, unaggregated)
+	}
+
+	// flushed on close
+	c.Close()
+	got := rs.sent()[len(unaggregated):]
+	sort.Strings(got)
+	expected := []string{
+		"test.count:2|c|#tag1:val1",
+		"test.count:3|c",
+		"test.fgauge:1.5|g",
+		"test.gauge:7|g",
+		"test.sub.count:3|c",
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAggregateInterval(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Inc("count", 1, 1.0)
+	c.Inc("count", 1, 1.0)
+
+	deadline := time.Now().Add(time.Second)
+	for len(rs.sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the window flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expected := []string{"test.count:2|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAggregateGaugeDelta(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the held value goes out ahead of the delta, which is not aggregated
+	c.Gauge("gauge", 10, 1.0)
+	c.GaugeDelta("gauge", 1, 1.0)
+	expected := []string{"test.gauge:10|g", "test.gauge:+1|g"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	// nothing is left to overwrite the delta at the end of the window
+	c.Close()
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAggregateWithContext(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// a fresh SubStatter per request still submits to the same series
+	ctx := ContextWithTags(context.Background(), Tag{"route", "home"})
+	for i := 0; i < 1000; i++ {
+		client.WithContext(ctx).Inc("count", 1, 1.0)
+	}
+	client.WithContext(context.Background()).Inc("count", 1, 1.0)
+
+	client.Flush()
+	expected := []string{"test.count:1000|c|#route:home", "test.count:1|c"}
+	got := rs.sent()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -5,6 +5,7 @@
 package statsd
 
 import (
+	"fmt"
 	"testing"
 	"time"
 )
@@ -96,3 +97,32 @@ func BenchmarkBufferedClientSetIntSample(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkBufferedClientShards compares a single buffer to a sharded one,
+// with stats sent from many goroutines at once.
+func BenchmarkBufferedClientShards(b *testing.B) {
+	for _, shards := range []int{0, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c, err := NewClientWithConfig(&ClientConfig{
+				Sender:        discardSender{},
+				Prefix:        "test",
+				UseBuffered:   true,
+				FlushInterval: time.Second,
+				BufferShards:  shards,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer c.Close()
+
+			b.SetParallelism(8)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					c.Inc("benchinc", 1, 1)
+				}
+			})
+		})
+	}
+}
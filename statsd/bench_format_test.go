@@ -0,0 +1,269 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// discardSender drops everything sent to it, so that benchmarks measure
+// client formatting overhead only.
+type discardSender struct{}
+
+func (discardSender) Send(data []byte) (int, error) { return len(data), nil }
+func (discardSender) Close() error                  { return nil }
+
+func newBenchClient(b *testing.B) Statter {
+	c, err := NewClientWithSender(discardSender{}, "test", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return c
+}
+
+func BenchmarkInc(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc("benchinc", 123456, 1)
+	}
+}
+
+// BenchmarkIncRate compares unsampled stats, which the default sampler sends
+// without consulting the random source, to sampled ones.
+func BenchmarkIncRate(b *testing.B) {
+	for _, rate := range []float32{1, 0.999999} {
+		b.Run(strconv.FormatFloat(float64(rate), 'f', -1, 32), func(b *testing.B) {
+			c := newBenchClient(b)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Inc("benchinc", 123456, rate)
+			}
+		})
+	}
+}
+
+func BenchmarkGauge(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Gauge("benchgauge", -123456, 1)
+	}
+}
+
+func BenchmarkGaugeDelta(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GaugeDelta("benchgauge", 123456, 1)
+	}
+}
+
+func BenchmarkSetInt(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SetInt("benchset", 123456, 1)
+	}
+}
+
+func BenchmarkIncTags(b *testing.B) {
+	c := newBenchClient(b)
+	tags := []Tag{{"tag1", "val1"}, {"tag2", "val2"}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc("benchinc", 123456, 1, tags...)
+	}
+}
+
+func BenchmarkTiming(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Timing("benchtiming", 123456, 1)
+	}
+}
+
+func BenchmarkTimingDuration(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.TimingDuration("benchtiming", 1500*time.Microsecond, 1)
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set("benchset", "some-member", 1)
+	}
+}
+
+func BenchmarkBufferedInc(b *testing.B) {
+	sender, err := NewBufferedSenderWithSender(discardSender{}, 10*time.Millisecond, 1432)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, err := NewClientWithSender(sender, "test", 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc("benchinc", 123456, 1)
+	}
+}
+
+func BenchmarkBufferedIncSingleWriter(b *testing.B) {
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:        discardSender{},
+		Prefix:        "test",
+		UseBuffered:   true,
+		FlushInterval: 10 * time.Millisecond,
+		SingleWriter:  true,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc("benchinc", 123456, 1)
+	}
+}
+
+func TestFormatEquivalence(t *testing.T) {
+	for _, tt := range statsdPacketTests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, tt.Prefix, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		method := reflect.ValueOf(c).MethodByName(tt.Method)
+		e := method.Call([]reflect.Value{
+			reflect.ValueOf(tt.Stat),
+			reflect.ValueOf(tt.Value),
+			reflect.ValueOf(tt.Rate)})[0]
+		if errInter := e.Interface(); errInter != nil {
+			t.Fatal(errInter.(error))
+		}
+
+		sent := rs.sent()
+		if len(sent) != 1 || sent[0] != tt.Expected {
+			t.Fatalf("%s got %q expected '%s'", tt.Method, sent, tt.Expected)
+		}
+	}
+}
+
+func TestFormatAllocs(t *testing.T) {
+	c, err := NewClientWithSender(discardSender{}, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []Tag{{"tag1", "val1"}, {"tag2", "val2"}}
+
+	tests := map[string]func(){
+		"Inc":            func() { c.Inc("count", 123456, 1) },
+		"IncTags":        func() { c.Inc("count", 123456, 1, tags...) },
+		"IncManyTags":    func() { c.Inc("count", 123456, 1, manyTags...) },
+		"Dec":            func() { c.Dec("count", 123456, 1) },
+		"Gauge":          func() { c.Gauge("gauge", -123456, 1) },
+		"GaugeDelta":     func() { c.GaugeDelta("gauge", 123456, 1) },
+		"GaugeDeltaNeg":  func() { c.GaugeDelta("gauge", -123456, 1) },
+		"SetInt":         func() { c.SetInt("set", 123456, 1) },
+		"Timing":         func() { c.Timing("timing", 123456, 1) },
+		"TimingDuration": func() { c.TimingDuration("timing", 1500*time.Microsecond, 1) },
+		"Set":            func() { c.Set("set", "member", 1) },
+	}
+	for name, fn := range tests {
+		if allocs := testing.AllocsPerRun(100, fn); allocs != 0 {
+			t.Errorf("%s: expected no allocations, got %v", name, allocs)
+		}
+	}
+}
+
+func BenchmarkSetBytes(b *testing.B) {
+	c := newBenchClient(b).(*Client)
+	value := []byte("0123456789abcdef0123456789abcdef")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SetBytes("benchset", value, 1)
+	}
+}
+
+func BenchmarkSetBytesString(b *testing.B) {
+	c := newBenchClient(b)
+	value := []byte("0123456789abcdef0123456789abcdef")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set("benchset", string(value), 1)
+	}
+}
+
+// BenchmarkEmit compares Emit to calling the method for the stat type by
+// name, with reflection.
+func BenchmarkEmit(b *testing.B) {
+	c := newBenchClient(b).(*Client)
+	m := Metric{TypeGauge, "benchgauge", int64(1), 1, nil, false}
+
+	b.Run("Emit", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.Emit(m)
+		}
+	})
+	b.Run("reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reflect.ValueOf(c).MethodByName("Gauge").Call([]reflect.Value{
+				reflect.ValueOf(m.Name),
+				reflect.ValueOf(m.Value),
+				reflect.ValueOf(m.Rate),
+			})
+		}
+	})
+}
+
+// BenchmarkIncManyTags measures a stat with a repeated, larger, tag set, as
+// submitted by instrumented request handlers.
+func BenchmarkIncManyTags(b *testing.B) {
+	c := newBenchClient(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc("count", 1, 1, manyTags...)
+	}
+}
+
+// manyTags is a repeated tag set, for BenchmarkIncManyTags and
+// TestFormatAllocs.
+var manyTags = []Tag{
+	{"service", "checkout"},
+	{"env", "production"},
+	{"region", "us-east-1"},
+	{"endpoint", "/api/v1/orders"},
+	{"method", "POST"},
+	{"status", "200"},
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "time"
+
+// boundStat is a stat name, and tags, declared once for reuse
+type boundStat struct {
+	client *Client
+	stat   string
+	tags   []Tag
+}
+
+func newBoundStat(s *Client, stat string, tags []Tag) boundStat {
+	return boundStat{s, stat, append([]Tag(nil), tags...)}
+}
+
+// withTags returns the declared tags, followed by tags
+func (b boundStat) withTags(tags []Tag) []Tag {
+	if len(b.tags) == 0 {
+		return tags
+	}
+	if len(tags) == 0 {
+		return b.tags
+	}
+	return append(b.tags[:len(b.tags):len(b.tags)], tags...)
+}
+
+// Name returns the declared stat name.
+func (b boundStat) Name() string {
+	return b.stat
+}
+
+// A BoundCounter is a count stat, declared once with NewCounter so its name
+// is not repeated (and possibly mistyped) at every call site. eg.
+//
+//	var requests = client.NewCounter("app.requests")
+//	...
+//	requests.Inc(1, 1.0)
+//
+// The constructors are named NewCounter, NewGauge etc., rather than Counter,
+// Gauge etc., as Client.Gauge and Client.Histogram already submit stats.
+type BoundCounter struct {
+	boundStat
+}
+
+// NewCounter returns a BoundCounter that submits to stat. tags are submitted
+// with every count, before any per-call tags.
+func (s *Client) NewCounter(stat string, tags ...Tag) *BoundCounter {
+	return &BoundCounter{newBoundStat(s, stat, tags)}
+}
+
+// Inc increments the counter, as for Client.Inc.
+func (c *BoundCounter) Inc(value int64, rate float32, tags ...Tag) error {
+	return c.client.Inc(c.stat, value, rate, c.withTags(tags)...)
+}
+
+// Dec decrements the counter, as for Client.Dec.
+func (c *BoundCounter) Dec(value int64, rate float32, tags ...Tag) error {
+	return c.client.Dec(c.stat, value, rate, c.withTags(tags)...)
+}
+
+// A BoundGauge is a gauge stat, declared once with NewGauge.
+type BoundGauge struct {
+	boundStat
+}
+
+// NewGauge returns a BoundGauge that submits to stat. tags are submitted
+// with every value, before any per-call tags.
+func (s *Client) NewGauge(stat string, tags ...Tag) *BoundGauge {
+	return &BoundGauge{newBoundStat(s, stat, tags)}
+}
+
+// Set submits the gauge value, as for Client.Gauge.
+func (g *BoundGauge) Set(value int64, rate float32, tags ...Tag) error {
+	return g.client.Gauge(g.stat, value, rate, g.withTags(tags)...)
+}
+
+// SetFloat submits the gauge value, as for Client.GaugeFloat.
+func (g *BoundGauge) SetFloat(value float64, rate float32, tags ...Tag) error {
+	return g.client.GaugeFloat(g.stat, value, rate, g.withTags(tags)...)
+}
+
+// A BoundTimer is a timing stat, declared once with NewTimer.
+type BoundTimer struct {
+	boundStat
+}
+
+// NewTimer returns a BoundTimer that submits to stat. tags are submitted
+// with every timing, before any per-call tags.
+func (s *Client) NewTimer(stat string, tags ...Tag) *BoundTimer {
+	return &BoundTimer{newBoundStat(s, stat, tags)}
+}
+
+// Record submits a timing, as for Client.TimingDuration.
+func (t *BoundTimer) Record(delta time.Duration, rate float32, tags ...Tag) error {
+	return t.client.TimingDuration(t.stat, delta, rate, t.withTags(tags)...)
+}
+
+// Since submits the time elapsed since start, as for Client.TimingSince.
+func (t *BoundTimer) Since(start time.Time, rate float32, tags ...Tag) error {
+	return t.Record(time.Since(start), rate, tags...)
+}
+
+// A BoundHistogram is a histogram stat, declared once with NewHistogram.
+type BoundHistogram struct {
+	boundStat
+}
+
+// NewHistogram returns a BoundHistogram that submits to stat. tags are
+// submitted with every value, before any per-call tags.
+func (s *Client) NewHistogram(stat string, tags ...Tag) *BoundHistogram {
+	return &BoundHistogram{newBoundStat(s, stat, tags)}
+}
+
+// Observe submits a histogram value, as for Client.Histogram.
+func (h *BoundHistogram) Observe(value float64, rate float32, tags ...Tag) error {
+	return h.client.Histogram(h.stat, value, rate, h.withTags(tags)...)
+}
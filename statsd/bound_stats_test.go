@@ -0,0 +1,46 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBoundStats(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	requests := client.NewCounter("app.requests", Tag{"service", "api"})
+	if name := requests.Name(); name != "app.requests" {
+		t.Fatalf("got name %q", name)
+	}
+	requests.Inc(1, 1.0)
+	requests.Inc(2, 1.0, Tag{"code", "200"})
+	requests.Dec(1, 1.0)
+
+	client.NewGauge("app.queue").Set(5, 1.0)
+	client.NewGauge("app.load").SetFloat(0.5, 1.0)
+	client.NewTimer("app.latency").Record(1500*time.Microsecond, 1.0)
+	client.NewHistogram("app.size", Tag{"service", "api"}).Observe(42, 1.0)
+
+	expected := []string{
+		"test.app.requests:1|c|#service:api",
+		"test.app.requests:2|c|#service:api,code:200",
+		"test.app.requests:-1|c|#service:api",
+		"test.app.queue:5|g",
+		"test.app.load:0.5|g",
+		"test.app.latency:1.5|ms",
+		"test.app.size:42|h|#service:api",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A BucketedTimer approximates a histogram of durations with a counter per
+// latency bucket, for servers without histogram support (eg. plain statsd).
+// Each observation increments the "stat.bucket" counter, tagged with the
+// upper bound of its bucket in milliseconds (le:<upper>, or le:+Inf for
+// observations above the highest bound). Unlike HistogramBuckets with
+// BucketGauges, the counts are per bucket, not cumulative.
+type BucketedTimer struct {
+	client *Client
+	stat   string
+	bounds []time.Duration
+	// le tag values, for each bound and then the overflow bucket
+	les []string
+}
+
+// NewBucketedTimer returns a BucketedTimer that submits to stat, with the
+// given bucket upper bounds. The bounds need not be sorted.
+func (s *Client) NewBucketedTimer(stat string, buckets []time.Duration) *BucketedTimer {
+	bounds := append([]time.Duration(nil), buckets...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	les := make([]string, len(bounds)+1)
+	for i, b := range bounds {
+		les[i] = strconv.FormatFloat(float64(b)/float64(time.Millisecond), 'f', -1, 64)
+	}
+	les[len(bounds)] = "+Inf"
+
+	return &BucketedTimer{
+		client: s,
+		stat:   joinPathComp(stat, "bucket"),
+		bounds: bounds,
+		les:    les,
+	}
+}
+
+// Observe increments the counter for the smallest bucket with an upper bound
+// of at least d.
+// rate is the sample rate (0.0 to 1.0).
+func (t *BucketedTimer) Observe(d time.Duration, rate float32, tags ...Tag) error {
+	i := sort.Search(len(t.bounds), func(i int) bool { return t.bounds[i] >= d })
+	// le tag last, after the caller's tags
+	btags := append(tags[:len(tags):len(tags)], Tag{"le", t.les[i]})
+	return t.client.Inc(t.stat, 1, rate, btags...)
+}
+
+// Since observes the time elapsed since start, as for Observe.
+func (t *BucketedTimer) Since(start time.Time, rate float32, tags ...Tag) error {
+	return t.Observe(time.Since(start), rate, tags...)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketedTimer(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unsorted bounds are sorted
+	timer := c.(*Client).NewBucketedTimer("latency", []time.Duration{
+		100 * time.Millisecond,
+		500 * time.Microsecond,
+		10 * time.Millisecond,
+	})
+
+	for _, d := range []time.Duration{
+		0,
+		500 * time.Microsecond,
+		time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		time.Second,
+	} {
+		if err := timer.Observe(d, 1.0, Tag{"tag1", "val1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"test.latency.bucket:1|c|#tag1:val1,le:0.5",
+		"test.latency.bucket:1|c|#tag1:val1,le:0.5",
+		"test.latency.bucket:1|c|#tag1:val1,le:10",
+		"test.latency.bucket:1|c|#tag1:val1,le:10",
+		"test.latency.bucket:1|c|#tag1:val1,le:100",
+		"test.latency.bucket:1|c|#tag1:val1,le:+Inf",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestBucketedTimerNoBuckets(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.(*Client).NewBucketedTimer("latency", nil).Observe(time.Second, 1.0)
+
+	expected := []string{"test.latency.bucket:1|c|#le:+Inf"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "runtime"
+
+// EmitBuildInfo submits a gauge of 1, tagged with the version and commit of
+// the application and the go version it was built with (as "version",
+// "commit" and "go_version"), for filtering dashboards by build. It is
+// usually called once at startup. eg.
+//
+//	statsd.EmitBuildInfo(client, "build_info", version, commit)
+func EmitBuildInfo(c Statter, stat string, version, commit string) error {
+	return c.Gauge(stat, 1, 1.0,
+		Tag{"version", version},
+		Tag{"commit", commit},
+		Tag{"go_version", runtime.Version()},
+	)
+}
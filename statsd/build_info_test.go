@@ -0,0 +1,30 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestEmitBuildInfo(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EmitBuildInfo(c, "build_info", "1.2.3", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"test.build_info:1|g|#version:1.2.3,commit:abc123,go_version:" + runtime.Version(),
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// Stat types, as written on the wire, for Supports.
+const (
+	TypeCount        = "c"
+	TypeGauge        = "g"
+	TypeTiming       = "ms"
+	TypeSet          = "s"
+	TypeHistogram    = "h"
+	TypeDistribution = "d"
+)
+
+// ServerFlavor is the kind of server a client submits to, which determines
+// the stat types it supports (see ClientConfig.Server).
+type ServerFlavor uint8
+
+const (
+	// ServerUnknown makes no assumptions about the server, so every stat
+	// type is reported as supported. This is the default.
+	ServerUnknown ServerFlavor = iota
+	// ServerStatsd is a plain (etsy) statsd server, supporting counts,
+	// gauges, timings and sets.
+	ServerStatsd
+	// ServerDogStatsD is a DogStatsD server, which also supports histograms
+	// and distributions.
+	ServerDogStatsD
+)
+
+// serverTypes maps each known server flavor to the stat types it supports
+var serverTypes = map[ServerFlavor]map[string]bool{
+	ServerStatsd: {
+		TypeCount: true, TypeGauge: true, TypeTiming: true, TypeSet: true,
+	},
+	ServerDogStatsD: {
+		TypeCount: true, TypeGauge: true, TypeTiming: true, TypeSet: true,
+		TypeHistogram: true, TypeDistribution: true,
+	},
+}
+
+// The CapabilityReporter interface wraps Supports, for code that needs to
+// fall back when a stat type is not supported by the server (eg. submit a
+// timing instead of a histogram).
+type CapabilityReporter interface {
+	Supports(statType string) bool
+}
+
+// Supports reports whether the server supports a stat type, as written on the
+// wire (eg. TypeHistogram), according to ClientConfig.Server. Unsupported
+// stats are usually dropped by the server without an error. eg.
+//
+//	if client.Supports(statsd.TypeHistogram) {
+//		client.Histogram("size", size, 1.0)
+//	} else {
+//		client.Timing("size", int64(size), 1.0)
+//	}
+//
+// A client for an unknown server (the default) reports every type as
+// supported. A nil client supports nothing.
+func (s *Client) Supports(statType string) bool {
+	if s == nil {
+		return false
+	}
+
+	types, ok := serverTypes[s.server]
+	if !ok {
+		return true
+	}
+	return types[statType]
+}
+
+// typeSuffix returns suffix, the type suffix for a stat of statType, or the
+// timing suffix if the server does not support statType and
+// ClientConfig.HistogramFallback is set.
+func (s *Client) typeSuffix(statType, suffix string) string {
+	if s.histogramFallback && !s.Supports(statType) {
+		return "|ms"
+	}
+	return suffix
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSupports(t *testing.T) {
+	types := []string{TypeCount, TypeGauge, TypeTiming, TypeSet, TypeHistogram, TypeDistribution}
+	tests := []struct {
+		Server    ServerFlavor
+		Supported map[string]bool
+	}{
+		{ServerStatsd, map[string]bool{
+			TypeCount: true, TypeGauge: true, TypeTiming: true, TypeSet: true,
+		}},
+		{ServerDogStatsD, map[string]bool{
+			TypeCount: true, TypeGauge: true, TypeTiming: true, TypeSet: true,
+			TypeHistogram: true, TypeDistribution: true,
+		}},
+		{ServerUnknown, map[string]bool{
+			TypeCount: true, TypeGauge: true, TypeTiming: true, TypeSet: true,
+			TypeHistogram: true, TypeDistribution: true,
+		}},
+	}
+
+	for _, tt := range tests {
+		c, err := newClientWithConfig(&recordingSender{}, &ClientConfig{Server: tt.Server})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var cr CapabilityReporter = c.(*Client)
+		// SubStatters report the same capabilities
+		sub := c.NewSubStatter("sub").(CapabilityReporter)
+		for _, typ := range types {
+			if got := cr.Supports(typ); got != tt.Supported[typ] {
+				t.Errorf("server %d, type %q: got %v expected %v", tt.Server, typ, got, tt.Supported[typ])
+			}
+			if got := sub.Supports(typ); got != tt.Supported[typ] {
+				t.Errorf("server %d substatter, type %q: got %v expected %v", tt.Server, typ, got, tt.Supported[typ])
+			}
+		}
+	}
+
+	var nilClient *Client
+	if nilClient.Supports(TypeCount) {
+		t.Error("expected a nil client to support nothing")
+	}
+}
+
+func TestHistogramFallback(t *testing.T) {
+	tests := []struct {
+		Server   ServerFlavor
+		Fallback bool
+		Expected []string
+	}{
+		{ServerDogStatsD, true, []string{"test.size:1.5|h", "test.size:2|h", "test.size:1.5|d"}},
+		{ServerStatsd, true, []string{"test.size:1.5|ms", "test.size:2|ms", "test.size:1.5|ms"}},
+		// explicit only
+		{ServerStatsd, false, []string{"test.size:1.5|h", "test.size:2|h", "test.size:1.5|d"}},
+		{ServerUnknown, true, []string{"test.size:1.5|h", "test.size:2|h", "test.size:1.5|d"}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			Prefix:            "test",
+			Server:            tt.Server,
+			HistogramFallback: tt.Fallback,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := c.(*Client)
+
+		client.Histogram("size", 1.5, 1.0)
+		client.HistogramBuckets("size", []float64{2}, []int64{1}, BucketValues, 1.0)
+		client.Distribution("size", 1.5, 1.0)
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("server %d, fallback %v: got %q expected %q", tt.Server, tt.Fallback, got, tt.Expected)
+		}
+	}
+}
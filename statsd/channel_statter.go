@@ -0,0 +1,95 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+)
+
+// errChannelFull is returned by a strict channel Statter for a stat dropped
+// because the channel is full.
+var errChannelFull = errors.New("metric channel full, stat dropped")
+
+// NewChannelStatter returns a Statter that pushes each stat submitted to it
+// onto metrics, as a Metric, instead of sending it, for processing in-process
+// (eg. enriching or rerouting stats) before they are sent on (eg. with
+// Client.Emit). The channel belongs to the caller, and is not closed by
+// Close.
+//
+// config sets the prefix, tags, sampling etc. as for NewClientWithConfig,
+// and may be nil. Settings for sending stats (eg. Address, UseBuffered) are
+// ignored.
+//
+// Metrics are pushed as submitted: names include the prefix, tags include
+// any default and context tags, values have the type the stat was submitted
+// with, and gauge deltas are marked as such. Per-call options, apart from
+// WithPrefix and Absolute, are not carried. Raw stats have no Type, and
+// their value as a string.
+//
+// Submitting never blocks: if the channel is full, the stat is dropped, and
+// counted (see ClientStats.DroppedStats).
+func NewChannelStatter(metrics chan<- Metric, config *ClientConfig) (Statter, error) {
+	if config == nil {
+		config = &ClientConfig{}
+	}
+	c, err := newClientWithConfig(channelSender{}, config)
+	if err != nil {
+		return nil, err
+	}
+	c.(*Client).metrics = metrics
+	return c, nil
+}
+
+// channelSender is the sender of a channel Statter, which is never sent to
+type channelSender struct{}
+
+func (channelSender) Send(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (channelSender) Close() error {
+	return nil
+}
+
+// pushMetric pushes a stat onto the metrics channel, in place of sending it.
+// A negative gauge delta is submitted with a "-" vprefix, and its magnitude.
+func (s *Client) pushMetric(stat, vprefix string, value interface{}, suffix string, rate float32, tags []Tag, opts *callOptions) error {
+	m := Metric{
+		Type:  strings.TrimPrefix(suffix, "|"),
+		Name:  s.fullStatName(stat, opts),
+		Rate:  rate,
+		Delta: vprefix != "",
+	}
+	// re-box the value, so that value itself never escapes, and the send
+	// path stays allocation free
+	switch v := value.(type) {
+	case int64:
+		m.Value = v
+	case uint64:
+		m.Value = -int64(v)
+	case float64:
+		if vprefix == "-" {
+			v = -v
+		}
+		m.Value = v
+	case string:
+		m.Value = v
+	case []byte:
+		m.Value = string(v)
+	}
+	if len(tags) > 0 {
+		m.Tags = append([]Tag(nil), tags...)
+	}
+
+	select {
+	case s.metrics <- m:
+		return nil
+	default:
+		atomic.AddInt64(&s.counters.droppedStats, 1)
+		return s.dropped(errChannelFull)
+	}
+}
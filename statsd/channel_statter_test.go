@@ -0,0 +1,151 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChannelStatter(t *testing.T) {
+	metrics := make(chan Metric, 16)
+	c, err := NewChannelStatter(metrics, &ClientConfig{
+		Prefix: "test",
+		Tags:   []Tag{{"env", "prod"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	client.SetSamplerFunc(func(float32) bool { return true })
+
+	client.Inc("count", 2, 0.5, Tag{"tag1", "a,b"}, Tag{"tag2", "val2"})
+	client.GaugeFloat("gauge", 1.5, 1.0)
+	client.TimingDuration("timing", 5*time.Millisecond, 1.0)
+	client.Set("set", "member", 1.0)
+	client.Histogram("histogram", 3, 1.0, Tag{"key", ""})
+	client.NewSubStatter("sub").Inc("count", 1, 1.0, Absolute())
+
+	env := Tag{"env", "prod"}
+	expected := []Metric{
+		{TypeCount, "test.count", int64(2), 0.5, []Tag{env, {"tag1", "a,b"}, {"tag2", "val2"}}, false},
+		{TypeGauge, "test.gauge", 1.5, 1, []Tag{env}, false},
+		{TypeTiming, "test.timing", 5.0, 1, []Tag{env}, false},
+		{TypeSet, "test.set", "member", 1, []Tag{env}, false},
+		{TypeHistogram, "test.histogram", 3.0, 1, []Tag{env, {"key", ""}}, false},
+		{TypeCount, "count", int64(1), 1, []Tag{env}, false},
+	}
+	for _, e := range expected {
+		select {
+		case m := <-metrics:
+			if !reflect.DeepEqual(m, e) {
+				t.Fatalf("got %+v expected %+v", m, e)
+			}
+		default:
+			t.Fatalf("expected %+v on the channel", e)
+		}
+	}
+	if n := client.Stats().DroppedStats; n != 0 {
+		t.Fatalf("expected no drops, got %d", n)
+	}
+}
+
+func TestChannelStatterGauges(t *testing.T) {
+	metrics := make(chan Metric, 16)
+	c, err := NewChannelStatter(metrics, &ClientConfig{TagFormat: InfixComma})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// deltas are marked, and absolute gauges never are, whatever their sign
+	client.GaugeDelta("gauge", 5, 1.0)
+	client.GaugeDelta("gauge", -5, 1.0)
+	client.GaugeDelta("gauge", math.MinInt64, 1.0)
+	client.GaugeFloatDelta("gauge", -1.5, 1.0)
+	client.GaugeDiff("gauge", 1, 3, 1.0, Tag{"tag1", "val1"})
+
+	expected := []Metric{
+		{TypeGauge, "gauge", int64(5), 1, nil, true},
+		{TypeGauge, "gauge", int64(-5), 1, nil, true},
+		{TypeGauge, "gauge", int64(math.MinInt64), 1, nil, true},
+		{TypeGauge, "gauge", -1.5, 1, nil, true},
+		// tags are kept apart from the name, whatever the tag format
+		{TypeGauge, "gauge", int64(-2), 1, []Tag{{"tag1", "val1"}}, false},
+	}
+	for _, e := range expected {
+		select {
+		case m := <-metrics:
+			if !reflect.DeepEqual(m, e) {
+				t.Fatalf("got %+v expected %+v", m, e)
+			}
+		default:
+			t.Fatalf("expected %+v on the channel", e)
+		}
+	}
+	if len(metrics) != 0 {
+		t.Fatalf("unexpected metric %+v", <-metrics)
+	}
+}
+
+func TestChannelStatterOverflow(t *testing.T) {
+	metrics := make(chan Metric, 2)
+	for _, strict := range []bool{false, true} {
+		c, err := NewChannelStatter(metrics, &ClientConfig{Strict: strict})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Inc("a", 1, 1.0)
+		c.Inc("b", 1, 1.0)
+		err = c.Inc("c", 1, 1.0)
+		switch {
+		case strict && err != errChannelFull:
+			t.Fatalf("expected errChannelFull when strict, got %v", err)
+		case !strict && err != nil:
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if n := c.(*Client).Stats().DroppedStats; n != 1 {
+			t.Fatalf("expected 1 drop, got %d", n)
+		}
+		if m := <-metrics; m.Name != "a" {
+			t.Fatalf("expected the first stat to be kept, got %+v", m)
+		}
+		if m := <-metrics; m.Name != "b" {
+			t.Fatalf("expected the second stat to be kept, got %+v", m)
+		}
+	}
+}
+
+func TestChannelStatterForward(t *testing.T) {
+	metrics := make(chan Metric, 16)
+	c, err := NewChannelStatter(metrics, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &recordingSender{}
+	fwd, err := NewClientWithSender(rs, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// forwarded stats are the same on the wire
+	c.Gauge("gauge", 10, 1.0)
+	c.GaugeDelta("gauge", -3, 1.0)
+	c.(*Client).GaugeFloatDelta("gauge", 1.5, 1.0)
+	for len(metrics) > 0 {
+		if err := fwd.(*Client).Emit(<-metrics); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"gauge:10|g", "gauge:-3|g", "gauge:+1.5|g"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
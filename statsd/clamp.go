@@ -0,0 +1,81 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// valueClamps limits the values of the named stats to a range (see
+// ClientConfig.ClampRange). It is read-only once created, so is safe for
+// concurrent use.
+type valueClamps map[string][2]float64
+
+// newValueClamps returns a copy of ranges, or nil if there are none, so
+// clamping can be skipped entirely.
+func newValueClamps(ranges map[string][2]float64) valueClamps {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	c := make(valueClamps, len(ranges))
+	for stat, r := range ranges {
+		c[stat] = r
+	}
+	return c
+}
+
+// clampFloat returns value limited to the range for stat, and whether it was
+// altered.
+func (c valueClamps) clampFloat(stat string, value float64) (float64, bool) {
+	r, ok := c[stat]
+	if !ok {
+		return value, false
+	}
+	switch {
+	case value < r[0]:
+		return r[0], true
+	case value > r[1]:
+		return r[1], true
+	}
+	return value, false
+}
+
+// clampInt returns value limited to the range for stat, rounded inwards to
+// whole numbers, and whether it was altered.
+func (c valueClamps) clampInt(stat string, value int64) (int64, bool) {
+	r, ok := c[stat]
+	if !ok {
+		return value, false
+	}
+	switch {
+	case float64(value) < r[0]:
+		return int64(math.Ceil(r[0])), true
+	case float64(value) > r[1]:
+		return int64(math.Floor(r[1])), true
+	}
+	return value, false
+}
+
+// clampFloat returns value limited to the configured range for stat,
+// counting it if it was altered.
+func (s *Client) clampFloat(stat string, value float64) float64 {
+	value, clamped := s.clamps.clampFloat(stat, value)
+	if clamped {
+		atomic.AddInt64(&s.counters.clampedValues, 1)
+	}
+	return value
+}
+
+// clampInt returns value limited to the configured range for stat, counting
+// it if it was altered.
+func (s *Client) clampInt(stat string, value int64) int64 {
+	value, clamped := s.clamps.clampInt(stat, value)
+	if clamped {
+		atomic.AddInt64(&s.counters.clampedValues, 1)
+	}
+	return value
+}
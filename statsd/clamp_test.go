@@ -0,0 +1,51 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClampRange(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix: "test",
+		ClampRange: map[string][2]float64{
+			"temp":    {-40, 125.5},
+			"latency": {0, 60000},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	client.Gauge("temp", 1e18, 1.0)
+	client.Gauge("temp", 20, 1.0)
+	client.GaugeFloat("temp", -1e18, 1.0)
+	client.Histogram("temp", 130, 1.0)
+	client.TimingDuration("latency", time.Hour, 1.0)
+	client.Timing("latency", -5, 1.0)
+	// other stats are not clamped
+	client.Gauge("other", 1e18, 1.0)
+
+	expected := []string{
+		"test.temp:125|g",
+		"test.temp:20|g",
+		"test.temp:-40|g",
+		"test.temp:125.5|h",
+		"test.latency:60000|ms",
+		"test.latency:0|ms",
+		"test.other:1000000000000000000|g",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if n := client.Stats().ClampedValues; n != 5 {
+		t.Fatalf("expected 5 clamped values, got %d", n)
+	}
+}
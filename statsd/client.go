@@ -5,15 +5,30 @@
 package statsd
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
+	"math"
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 var bufPool = newBufferPool()
 
+var errNoFormat = errors.New("No matching type format")
+
+var errPingUnsupported = errors.New("sender does not support Ping")
+
+var errReservedChars = errors.New("value contains reserved characters")
+
+var errInvalidTimingUnit = errors.New("timing unit must be positive")
+
+// characters that may not appear in a raw value, as they would corrupt the
+// wire format.
+const reservedValueChars = ":|\n"
+
 // The StatSender interface wraps all the statsd metric methods
 type StatSender interface {
 	Inc(string, int64, float32, ...Tag) error
@@ -45,6 +60,13 @@ type Statter interface {
 	Close() error
 }
 
+// The Introspector interface wraps accessors for how a stat client is
+// configured, for wrappers that need to adapt to it.
+type Introspector interface {
+	Prefix() string
+	TagFormat() TagFormat
+}
+
 // The SubStatter interface defines the behavior of a stat child/subclient
 type SubStatter interface {
 	StatSender
@@ -52,18 +74,55 @@ type SubStatter interface {
 	NewSubStatter(string) SubStatter
 }
 
+// CounterScaling controls how sampled counters are submitted.
+type CounterScaling uint8
+
+const (
+	// CounterScaleServer submits the count as-is, along with the sample rate.
+	// The server scales the count up by 1/rate. This is the default, and
+	// what most servers (including DogStatsD) expect.
+	CounterScaleServer CounterScaling = iota
+	// CounterScaleClient scales the count up by 1/rate in the client, and
+	// submits it without a sample rate, so the server does not scale it again.
+	// The scaled count is rounded to the nearest integer, as counts are
+	// integers on the wire (eg. 1 at rate 0.3 is submitted as 3).
+	CounterScaleClient
+	// CounterScaleNone submits the count as-is, without a sample rate, so
+	// that the server does not scale it. Use this when counts are already
+	// true totals (eg. pre-aggregated), and must not be multiplied.
+	CounterScaleNone
+)
+
 // The SamplerFunc type defines a function that can serve
 // as a Client sampler function.
 type SamplerFunc func(float32) bool
 
-// DefaultSampler is the default rate sampler function
+// DefaultSampler is the default rate sampler function. Rates of 1 or more
+// are always sent, and 0 or less never are, without consulting the random
+// source.
 func DefaultSampler(rate float32) bool {
+	if rate <= 0 {
+		return false
+	}
 	if rate < 1 {
 		return rand.Float32() < rate
 	}
 	return true
 }
 
+// SampleEvery returns the sample rate that sends 1 of every n stats, eg.
+// SampleEvery(1000) is 0.001, which is easier to get right than the float.
+//
+//	client.Inc("requests", 1, statsd.SampleEvery(1000))
+//
+// An n of 1 or less returns 1, for no sampling.
+func SampleEvery(n int) float32 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 / float32(n)
+}
+
 // A Client is a statsd client.
 type Client struct {
 	// prefix for statsd name
@@ -74,6 +133,75 @@ type Client struct {
 	sampler SamplerFunc
 	// tag handler
 	tagFormat TagFormat
+	// first occurrence sampling bypass
+	primer *primer
+	// tags added to every stat
+	tags []Tag
+	// tags taken from a context.Context
+	ctxTags []Tag
+	// sampled counter handling
+	counterScaling CounterScaling
+	// tag key filter
+	tagFilter *tagFilter
+	// TimingDuration unit. 0 means milliseconds.
+	timingUnit time.Duration
+	// guard against negative deltas for monotonic counters, nil if none
+	monotonic *monotonicGuard
+	// sample stats as usual, but leave the sample rate off the wire
+	omitSampleRate bool
+	// how tags with empty values are submitted
+	emptyTags EmptyTagValues
+	// sampled out counter values, nil unless sample adjustment is enabled
+	adjuster *sampleAdjuster
+	// additional destinations, each sent every stat in its own tag format
+	mirrors []mirror
+	// counts of dropped/altered stats, shared with substatters
+	counters *clientCounters
+	// distinct stat names submitted, nil unless tracking is enabled
+	catalog *statCatalog
+	// sample rate for stats submitted with a rate of 1, 0 means unsampled
+	defaultRate float32
+	// per stat sample rate floors, nil if none
+	minRates map[string]float32
+	// per stat value ranges, nil if none
+	clamps valueClamps
+	// per stat count scale factors, nil if none
+	scales valueScales
+	// handling of tags with the same key
+	duplicateTags DuplicateTagPolicy
+	// return errors for dropped stats, and the largest stat line allowed,
+	// 0 if unbounded (see ClientConfig.Strict)
+	strict    bool
+	maxPacket int
+	// client side aggregation of counters and gauges, nil if disabled
+	aggregator *aggregator
+	// suppression of unchanged gauges, nil if none
+	gaugeDedup *gaugeDeduper
+	// trace ID tagging, nil if disabled
+	tracer *traceTagger
+	// trace ID taken from a context.Context
+	ctxTraceID string
+	// kind of server submitted to, for Supports
+	server ServerFlavor
+	// submit unsupported histograms and distributions as timings
+	histogramFallback bool
+	// non-zero while disabled, shared with SubStatters (see SetEnabled)
+	disabled *int32
+	// lock-free counter accumulation for AddLocal, nil if disabled
+	local *localCounters
+	// soft cap on aggregated and batched stat memory, nil if none
+	memory *memoryBudget
+	// separator and flush size for request batches, nil and 0 for the
+	// defaults (see ClientConfig.BufferSeparator and FlushBytes)
+	batchSeparator  []byte
+	batchFlushBytes int
+	// rewrites stat names before submission, nil if none
+	nameTransform func(string) string
+	// flushes the client on signals, nil if none
+	signals *signalFlusher
+	// stats are pushed onto metrics instead of being sent, for
+	// NewChannelStatter. nil otherwise.
+	metrics chan<- Metric
 }
 
 // Close closes the connection and cleans up.
@@ -82,21 +210,122 @@ func (s *Client) Close() error {
 		return nil
 	}
 
+	if s.signals != nil {
+		s.signals.stop()
+	}
+	if s.aggregator != nil {
+		s.aggregator.stop()
+		s.aggregator.flush()
+	}
+	if s.local != nil {
+		s.local.stop()
+		s.local.flush()
+	}
+
 	err := s.sender.Close()
+	for _, m := range s.mirrors {
+		if merr := m.sender.Close(); merr != nil && err == nil {
+			err = merr
+		}
+	}
 	return err
 }
 
+// Flush sends any aggregated stats (see ClientConfig.Aggregate and
+// ClientConfig.LocalCounters), and any buffered stats, right away, if the
+// client sender buffers (implements Flusher). Otherwise, it is a noop.
+func (s *Client) Flush() error {
+	if s == nil {
+		return nil
+	}
+
+	var err error
+	if s.aggregator != nil {
+		err = s.aggregator.flush()
+	}
+	if s.local != nil {
+		if lerr := s.local.flush(); lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+	if f, ok := s.sender.(Flusher); ok {
+		if ferr := f.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	for _, m := range s.mirrors {
+		if f, ok := m.sender.(Flusher); ok {
+			if merr := f.Flush(); merr != nil && err == nil {
+				err = merr
+			}
+		}
+	}
+	return err
+}
+
+// BufferStats returns the buffer fill and flush statistics of the client
+// sender, if it is a BufferedSender. Otherwise, a zero BufferStats is
+// returned.
+func (s *Client) BufferStats() BufferStats {
+	if s == nil {
+		return BufferStats{}
+	}
+
+	if bs, ok := s.sender.(interface{ BufferStats() BufferStats }); ok {
+		return bs.BufferStats()
+	}
+	return BufferStats{}
+}
+
+// BreakerState returns the state of the circuit breaker around the client
+// sender (see ClientConfig.BreakerFailures). It is always BreakerClosed for a
+// client without a breaker, or a nil client.
+func (s *Client) BreakerState() BreakerState {
+	if s == nil {
+		return BreakerClosed
+	}
+
+	return breakerState(s.sender)
+}
+
+// Ping checks whether the statsd server is reachable, if the client sender
+// supports it (implements Pinger). Otherwise, an error is returned.
+// A nil client is a noop, and always returns nil.
+//
+// Note that for udp senders this is best-effort. See SimpleSender.Ping.
+func (s *Client) Ping() error {
+	if s == nil {
+		return nil
+	}
+
+	return ping(s.sender)
+}
+
+// ping a sender, if it supports it
+func ping(sender Sender) error {
+	p, ok := sender.(Pinger)
+	if !ok {
+		return errPingUnsupported
+	}
+	return p.Ping()
+}
+
 // Inc increments a statsd count type.
 // stat is a string name for the metric.
 // value is the integer value
 // rate is the sample rate (0.0 to 1.0)
 // tags is a []Tag
 func (s *Client) Inc(stat string, value int64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeSampled(stat, rate)
+	if !ok {
+		s.holdCount(stat, value, tags)
+		return s.excluded(true)
 	}
 
-	return s.submit(stat, "", value, "|c", rate, tags)
+	if s.aggregator != nil && canAggregate(rate, tags) {
+		return s.aggregator.addCount(s, stat, value, tags)
+	}
+	return s.submitCount(stat, value, rate, tags)
 }
 
 // Dec decrements a statsd count type.
@@ -104,11 +333,53 @@ func (s *Client) Inc(stat string, value int64, rate float32, tags ...Tag) error
 // value is the integer value.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) Dec(stat string, value int64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeSampled(stat, rate)
+	if !ok {
+		s.holdCount(stat, -value, tags)
+		return s.excluded(true)
 	}
 
-	return s.submit(stat, "", -value, "|c", rate, tags)
+	if s.aggregator != nil && canAggregate(rate, tags) {
+		return s.aggregator.addCount(s, stat, -value, tags)
+	}
+	return s.submitCount(stat, -value, rate, tags)
+}
+
+// CountOutcome counts an operation and its outcome, incrementing the
+// "<stat>.total" count, and the "<stat>.errors" count too if err is not nil,
+// so the error ratio can be computed downstream. Both counts are sampled
+// together, so either both or neither are submitted.
+// stat is a string name for the operation.
+// err is the error the operation returned, if any.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) CountOutcome(stat string, err error, rate float32, tags ...Tag) error {
+	stats := [2]string{joinPathComp(stat, "total"), joinPathComp(stat, "errors")}
+	n := 1
+	if err != nil {
+		n = 2
+	}
+
+	rate, ok := s.includeSampled(stat, rate)
+	if !ok {
+		for _, name := range stats[:n] {
+			s.holdCount(name, 1, tags)
+		}
+		return s.excluded(true)
+	}
+
+	var ferr error
+	for _, name := range stats[:n] {
+		if s.aggregator != nil && canAggregate(rate, tags) {
+			if aerr := s.aggregator.addCount(s, name, 1, tags); aerr != nil && ferr == nil {
+				ferr = aerr
+			}
+			continue
+		}
+		if serr := s.submitCount(name, 1, rate, tags); serr != nil && ferr == nil {
+			ferr = serr
+		}
+	}
+	return ferr
 }
 
 // Gauge submits/updates a statsd gauge type.
@@ -116,10 +387,23 @@ func (s *Client) Dec(stat string, value int64, rate float32, tags ...Tag) error
 // value is the integer value.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) Gauge(stat string, value int64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+	if s.clamps != nil {
+		value = s.clampInt(stat, value)
+	}
+	if s.gaugeDedup != nil && s.gaugeDedup.suppress(s.prefix, stat, value, tags) {
 		return nil
 	}
 
+	if s.aggregator != nil && canAggregate(rate, tags) {
+		return s.aggregator.setGauge(s, stat, value, tags)
+	}
+	if err := s.flushHeldGauge(stat, tags); err != nil {
+		return err
+	}
 	return s.submit(stat, "", value, "|g", rate, tags)
 }
 
@@ -128,17 +412,76 @@ func (s *Client) Gauge(stat string, value int64, rate float32, tags ...Tag) erro
 // value is the (positive or negative) change.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) GaugeDelta(stat string, value int64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	if err := s.flushHeldGauge(stat, tags); err != nil {
+		return err
 	}
 
-	// if negative, the submit formatter will prefix with a - already
-	// so only special case the positive value.
+	// deltas always have a sign prefix, so a channel Statter can tell them
+	// from absolute gauges. negative values go as their magnitude, which
+	// is a uint64 so that it fits for math.MinInt64.
 	// don't pull out the prefix here, avoids some tiny amount of stack space by
 	// inlining like this. performance
 	if value >= 0 {
 		return s.submit(stat, "+", value, "|g", rate, tags)
 	}
+	return s.submit(stat, "-", uint64(-value), "|g", rate, tags)
+}
+
+// GaugeBool submits/updates a statsd gauge type, as 1 for true and 0 for
+// false.
+// stat is a string name for the metric.
+// value is the bool value.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) GaugeBool(stat string, value bool, rate float32, tags ...Tag) error {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	v := "0"
+	if value {
+		v = "1"
+	}
+	if s.gaugeDedup != nil && s.gaugeDedup.suppress(s.prefix, stat, v, tags) {
+		return nil
+	}
+	if err := s.flushHeldGauge(stat, tags); err != nil {
+		return err
+	}
+	return s.submit(stat, "", v, "|g", rate, tags)
+}
+
+// GaugeDiff submits/updates a statsd gauge type, as the difference a-b (eg.
+// free space as total minus used).
+// A negative difference is still submitted as an absolute value, by first
+// setting the gauge to 0, as a leading - is otherwise treated as a delta.
+// stat is a string name for the metric.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) GaugeDiff(stat string, a, b int64, rate float32, tags ...Tag) error {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	value := a - b
+	if s.gaugeDedup != nil && s.gaugeDedup.suppress(s.prefix, stat, value, tags) {
+		return nil
+	}
+	if err := s.flushHeldGauge(stat, tags); err != nil {
+		return err
+	}
+	// a negative value is a delta on the wire, so reset the gauge first.
+	// metrics pushed onto a channel are never mistaken for deltas.
+	if value < 0 && s.metrics == nil {
+		if err := s.submit(stat, "", int64(0), "|g", rate, tags); err != nil {
+			return err
+		}
+	}
 	return s.submit(stat, "", value, "|g", rate, tags)
 }
 
@@ -148,10 +491,23 @@ func (s *Client) GaugeDelta(stat string, value int64, rate float32, tags ...Tag)
 // value is the float64 value.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) GaugeFloat(stat string, value float64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+	if s.clamps != nil {
+		value = s.clampFloat(stat, value)
+	}
+	if s.gaugeDedup != nil && s.gaugeDedup.suppress(s.prefix, stat, value, tags) {
 		return nil
 	}
 
+	if s.aggregator != nil && canAggregate(rate, tags) {
+		return s.aggregator.setGauge(s, stat, value, tags)
+	}
+	if err := s.flushHeldGauge(stat, tags); err != nil {
+		return err
+	}
 	return s.submit(stat, "", value, "|g", rate, tags)
 }
 
@@ -161,16 +517,31 @@ func (s *Client) GaugeFloat(stat string, value float64, rate float32, tags ...Ta
 // value is the (positive or negative) change.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) GaugeFloatDelta(stat string, value float64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
 	}
 
-	// if negative, the submit formatter will prefix with a - already
-	// so only special case the positive value
+	if err := s.flushHeldGauge(stat, tags); err != nil {
+		return err
+	}
+
+	// deltas always have a sign prefix, so a channel Statter can tell them
+	// from absolute gauges. negative zero is >= 0, and formatted as zero, so
+	// gets a + too
 	if value >= 0 {
 		return s.submit(stat, "+", value, "|g", rate, tags)
 	}
-	return s.submit(stat, "", value, "|g", rate, tags)
+	return s.submit(stat, "-", -value, "|g", rate, tags)
+}
+
+// flushHeldGauge submits any value held by the aggregator for the gauge
+// series, ahead of an update to it that is submitted straight away.
+func (s *Client) flushHeldGauge(stat string, tags []Tag) error {
+	if s.aggregator == nil {
+		return nil
+	}
+	return s.aggregator.flushGauge(s, stat, tags)
 }
 
 // Timing submits a statsd timing type.
@@ -178,8 +549,12 @@ func (s *Client) GaugeFloatDelta(stat string, value float64, rate float32, tags
 // delta is the time duration value in milliseconds
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) Timing(stat string, delta int64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeSampled(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+	if s.clamps != nil {
+		delta = s.clampInt(stat, delta)
 	}
 
 	return s.submit(stat, "", delta, "|ms", rate, tags)
@@ -187,15 +562,94 @@ func (s *Client) Timing(stat string, delta int64, rate float32, tags ...Tag) err
 
 // TimingDuration submits a statsd timing type.
 // stat is a string name for the metric.
-// delta is the timing value as time.Duration
+// delta is the timing value as time.Duration. It is submitted in
+// milliseconds, unless configured otherwise (see ClientConfig.TimingUnit),
+// always with the standard "|ms" type.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) TimingDuration(stat string, delta time.Duration, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeSampled(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	unit := time.Millisecond
+	if s.timingUnit != 0 {
+		unit = s.timingUnit
+	}
+	v := float64(delta) / float64(unit)
+	if s.clamps != nil {
+		v = s.clampFloat(stat, v)
+	}
+	return s.submit(stat, "", v, "|ms", rate, tags)
+}
+
+// TimingDurationIn submits a statsd timing type, with the value in unit (eg.
+// time.Second for 1.5 from a 1500ms delta), for backends that expect timings
+// in a unit other than milliseconds.
+// The standard "|ms" type is still used, as it is the only timing type
+// servers understand, but the value is not milliseconds. Servers only
+// aggregating timings (eg. percentiles) are unaffected, but the backend must
+// be set up to expect values in unit, or they will be mislabelled.
+// Ignores ClientConfig.TimingUnit.
+// stat is a string name for the metric.
+// unit must be positive, or errInvalidTimingUnit is returned.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) TimingDurationIn(stat string, delta, unit time.Duration, rate float32, tags ...Tag) error {
+	if unit <= 0 {
+		return errInvalidTimingUnit
+	}
+
+	rate, ok := s.includeSampled(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	v := float64(delta) / float64(unit)
+	if s.clamps != nil {
+		v = s.clampFloat(stat, v)
+	}
+	return s.submit(stat, "", v, "|ms", rate, tags)
+}
+
+// TimingSince submits the time elapsed since start as a statsd timing type.
+// stat is a string name for the metric.
+// start is the time the timed operation started, eg. from time.Now().
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) TimingSince(stat string, start time.Time, rate float32, tags ...Tag) error {
+	return s.TimingDuration(stat, time.Since(start), rate, tags...)
+}
+
+// TimeResult runs fn, and submits how long it took as a statsd timing type,
+// tagged outcome:success or outcome:error depending on whether fn returned an
+// error.
+// stat is a string name for the metric.
+// rate is the sample rate (0.0 to 1.0).
+// fn is always run, even for a nil client. Its error is returned, or if it
+// succeeded, any error submitting the timing.
+func (s *Client) TimeResult(stat string, rate float32, fn func() error, tags ...Tag) error {
+	start := time.Now()
+	err := fn()
+	delta := time.Since(start)
+
+	outcome := Tag{"outcome", "success"}
+	if err != nil {
+		outcome[1] = "error"
 	}
+	// cap the slice, so the caller's backing array is never appended to
+	tags = append(tags[:len(tags):len(tags)], outcome)
+
+	serr := s.TimingDuration(stat, delta, rate, tags...)
+	if err != nil {
+		return err
+	}
+	return serr
+}
 
-	ms := float64(delta) / float64(time.Millisecond)
-	return s.submit(stat, "", ms, "|ms", rate, tags)
+// timingUnits are the supported TimingUnit values
+var timingUnits = map[time.Duration]bool{
+	time.Millisecond: true,
+	time.Microsecond: true,
+	time.Nanosecond:  true,
 }
 
 // Histogram submits a statsd histogram type.
@@ -203,11 +657,32 @@ func (s *Client) TimingDuration(stat string, delta time.Duration, rate float32,
 // value is the value you wnt to record
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) Histogram(stat string, value float64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+	if s.clamps != nil {
+		value = s.clampFloat(stat, value)
+	}
+
+	return s.submit(stat, "", value, s.typeSuffix(TypeHistogram, "|h"), rate, tags)
+}
+
+// Distribution submits a DogStatsD distribution type, which is aggregated
+// globally by the server, rather than per host as a histogram is.
+// stat is a string name for the metric.
+// value is the value you want to record
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) Distribution(stat string, value float64, rate float32, tags ...Tag) error {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+	if s.clamps != nil {
+		value = s.clampFloat(stat, value)
 	}
 
-	return s.submit(stat, "", value, "|h", rate, tags)
+	return s.submit(stat, "", value, s.typeSuffix(TypeDistribution, "|d"), rate, tags)
 }
 
 // Set submits a stats set type
@@ -215,8 +690,58 @@ func (s *Client) Histogram(stat string, value float64, rate float32, tags ...Tag
 // value is the string value
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) Set(stat string, value string, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	return s.submit(stat, "", value, "|s", rate, tags)
+}
+
+// SetMulti submits several members of a stats set type, as one stat per
+// distinct value (sets may not be joined into one value on the wire).
+// Duplicate values are submitted once. The values are sampled together, so
+// either all or none are submitted.
+// stat is a string name for the metric.
+// values are the string values.
+// rate is the sample rate (0.0 to 1.0).
+// All values are submitted even if one fails, and the first error returned.
+func (s *Client) SetMulti(stat string, values []string, rate float32, tags ...Tag) error {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	seen := make(map[string]struct{}, len(values))
+	var err error
+	for _, value := range values {
+		if _, dup := seen[value]; dup {
+			continue
+		}
+		seen[value] = struct{}{}
+
+		if serr := s.submit(stat, "", value, "|s", rate, tags); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// SetBytes submits a stats set type, taking the value as a []byte.
+// This avoids a string conversion when set members are already held as a
+// []byte (hashes, ids, etc).
+// stat is a string name for the metric.
+// value is the []byte value. It may not contain the reserved ':', '|' or
+// newline characters.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) SetBytes(stat string, value []byte, rate float32, tags ...Tag) error {
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	if bytes.IndexAny(value, reservedValueChars) != -1 {
+		return errReservedChars
 	}
 
 	return s.submit(stat, "", value, "|s", rate, tags)
@@ -227,8 +752,9 @@ func (s *Client) Set(stat string, value string, rate float32, tags ...Tag) error
 // value is the integer value
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) SetInt(stat string, value int64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
 	}
 
 	return s.submit(stat, "", value, "|s", rate, tags)
@@ -240,8 +766,9 @@ func (s *Client) SetInt(stat string, value int64, rate float32, tags ...Tag) err
 // value is the integer value
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) SetFloat(stat string, value float64, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
 	}
 
 	return s.submit(stat, "", value, "|s", rate, tags)
@@ -252,8 +779,9 @@ func (s *Client) SetFloat(stat string, value float64, rate float32, tags ...Tag)
 // value is a preformatted "raw" value string.
 // rate is the sample rate (0.0 to 1.0).
 func (s *Client) Raw(stat string, value string, rate float32, tags ...Tag) error {
-	if !s.includeStat(rate) {
-		return nil
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
 	}
 
 	return s.submit(stat, "", value, "", rate, tags)
@@ -263,15 +791,98 @@ func (s *Client) Raw(stat string, value string, rate float32, tags ...Tag) error
 // sampler is a function that determines whether the metric is
 // to be accepted, or discarded.
 // An example use case is for submitted pre-sampled metrics.
+// The sampler is called for every rate, including 1 or more, and 0 or less.
 func (s *Client) SetSamplerFunc(sampler SamplerFunc) {
 	s.sampler = sampler
 }
 
+// SetEnabled enables or disables submitting stats, eg. to shed load during an
+// incident, without recreating the client. While disabled, stats are
+// discarded before any formatting or sending. The setting is shared between
+// a Client and its SubStatters, and is safe to change while stats are being
+// submitted; stats already being submitted when the client is disabled may
+// still be sent. Clients are enabled when created.
+func (s *Client) SetEnabled(enabled bool) {
+	if s == nil || s.disabled == nil {
+		return
+	}
+
+	var v int32
+	if !enabled {
+		v = 1
+	}
+	atomic.StoreInt32(s.disabled, v)
+}
+
+// Enabled reports whether the client submits stats (see SetEnabled). A nil
+// client is never enabled.
+func (s *Client) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	return s.disabled == nil || atomic.LoadInt32(s.disabled) == 0
+}
+
 // submit an already sampled raw stat
 func (s *Client) submit(stat, vprefix string, value interface{}, suffix string, rate float32, tags []Tag) error {
-	skiptags := false
-	if len(tags) == 0 {
-		skiptags = true
+	// pull any per-call options out from amongst the tags
+	callTags := tags
+	var opts callOptions
+	if hasOptions(tags) {
+		var optbuf [8]Tag
+		var err error
+		tags, err = splitOptions(optbuf[:0], tags, &opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// merge in default and context tags, if any, ahead of the per-call tags.
+	// small enough sets are merged on the stack.
+	if len(s.tags) > 0 || len(s.ctxTags) > 0 {
+		var tagbuf [8]Tag
+		merged := append(tagbuf[:0], s.tags...)
+		merged = append(merged, s.ctxTags...)
+		tags = append(merged, tags...)
+	}
+
+	if s.duplicateTags != DuplicateTagKeepBoth && len(tags) > 1 {
+		var dedupbuf [8]Tag
+		var err error
+		tags, err = dedupTags(dedupbuf[:0], tags, s.duplicateTags)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.tracer != nil {
+		tags = s.tracer.tag(tags, s.ctxTraceID)
+	}
+
+	if s.tagFilter != nil && len(tags) > 0 {
+		var filterbuf [8]Tag
+		var dropped int
+		tags, dropped = s.tagFilter.filter(filterbuf[:0], tags)
+		if dropped > 0 {
+			atomic.AddInt64(&s.counters.droppedTags, int64(dropped))
+		}
+	}
+
+	if s.emptyTags == EmptyTagDrop {
+		var emptybuf [8]Tag
+		tags = dropEmptyTags(emptybuf[:0], tags)
+	}
+
+	if s.nameTransform != nil {
+		stat = s.nameTransform(stat)
+	}
+
+	if s.catalog != nil {
+		s.seeStat(stat, &opts)
+	}
+
+	if s.metrics != nil {
+		return s.pushMetric(stat, vprefix, value, suffix, rate, tags, &opts)
 	}
 
 	buf := bufPool.Get()
@@ -279,18 +890,70 @@ func (s *Client) submit(stat, vprefix string, value interface{}, suffix string,
 	// sadly, no way to jam this back into the bytes.Buffer without
 	// doing a few allocations... avoiding those is the whole point here...
 	// so from here on out just use it as a raw []byte
-	data := buf.Bytes()
+	data, err := s.format(buf.Bytes(), s.tagFormat, stat, vprefix, value, suffix, rate, tags, callTags, &opts)
+	if err != nil {
+		return err
+	}
+	if s.maxPacket > 0 && len(data) > s.maxPacket {
+		atomic.AddInt64(&s.counters.droppedStats, 1)
+		return ErrPacketTooLarge
+	}
+
+	_, err = s.sender.Send(data)
+
+	// mirrors get the same stat, in their own tag format. a stat one mirror
+	// can't format still goes to the rest.
+	var formatErr error
+	for _, m := range s.mirrors {
+		data, ferr := s.format(data[:0], m.tagFormat, stat, vprefix, value, suffix, rate, tags, callTags, &opts)
+		if ferr != nil {
+			if formatErr == nil {
+				formatErr = ferr
+			}
+			continue
+		}
+		if _, merr := m.sender.Send(data); merr != nil && err == nil {
+			err = merr
+		}
+	}
+	if err != nil {
+		return s.sendError(stat, suffix, &opts, err)
+	}
+	return formatErr
+}
+
+// format appends the stat line to data, with tags in the tag format tf
+func (s *Client) format(data []byte, tf TagFormat, stat, vprefix string, value interface{}, suffix string, rate float32, tags, callTags []Tag, opts *callOptions) ([]byte, error) {
+	// units are only representable as DogStatsD (suffix) tags
+	if opts.unit != "" && tf&AllInfix == 0 {
+		tags = append(tags[:len(tags):len(tags)], Tag{"unit", opts.unit})
+	}
+
+	// infix (graphite style) tags require values
+	if s.emptyTags == EmptyTagKeyOnly && tf&AllInfix != 0 {
+		var emptybuf [8]Tag
+		tags = dropEmptyTags(emptybuf[:0], tags)
+	}
+
+	skiptags := false
+	if len(tags) == 0 {
+		skiptags = true
+	}
 
-	if s.prefix != "" {
-		data = append(data, s.prefix...)
+	prefix := s.prefix
+	if opts.hasPrefix {
+		prefix = opts.prefix
+	}
+	if prefix != "" {
+		data = tf.appendName(data, prefix)
 		data = append(data, '.')
 	}
 
-	data = append(data, stat...)
+	data = tf.appendName(data, stat)
 
 	// infix tags, if present
-	if !skiptags && s.tagFormat&AllInfix != 0 {
-		data = s.tagFormat.WriteInfix(data, tags)
+	if !skiptags && tf&AllInfix != 0 {
+		data = tf.WriteInfix(data, tags)
 		// if we did infix already, no suffix also.
 		skiptags = true
 	}
@@ -304,44 +967,131 @@ func (s *Client) submit(stat, vprefix string, value interface{}, suffix string,
 	switch v := value.(type) {
 	case string:
 		data = append(data, v...)
+	case []byte:
+		data = append(data, v...)
 	case int64:
 		data = strconv.AppendInt(data, v, 10)
+	case uint64:
+		data = strconv.AppendUint(data, v, 10)
 	case float64:
+		// negative zero would format as "-0", which is a gauge delta
+		if v == 0 {
+			v = 0
+		}
 		data = strconv.AppendFloat(data, v, 'f', -1, 64)
 	default:
-		return fmt.Errorf("No matching type format")
+		return nil, errNoFormat
 	}
 
 	if suffix != "" {
 		data = append(data, suffix...)
 	}
 
-	if rate < 1 {
+	if rate < 1 && !s.omitSampleRate {
 		data = append(data, "|@"...)
 		data = strconv.AppendFloat(data, float64(rate), 'f', 6, 32)
 	}
 
 	// suffix tags if present
-	if !skiptags && s.tagFormat&AllSuffix != 0 {
-		data = s.tagFormat.WriteSuffix(data, tags)
+	if !skiptags && tf&AllSuffix != 0 {
+		data = tf.writeSuffix(data, tags, s.emptyTags == EmptyTagKeyOnly)
 	}
 
-	_, err := s.sender.Send(data)
-	return err
+	// exemplars are only representable as DogStatsD fields, on histograms
+	if opts.exemplar != "" && suffix == "|h" && tf&AllSuffix != 0 {
+		data = append(data, "|trace_id:"...)
+		data = append(data, opts.exemplar...)
+	}
+
+	// extension fields come last
+	if opts.fields {
+		data = appendFields(data, callTags)
+	}
+	return data, nil
+}
+
+// submit an already sampled count, applying the configured CounterScaling
+func (s *Client) submitCount(stat string, value int64, rate float32, tags []Tag) error {
+	if s.adjuster != nil && rate < 1 {
+		// carry the sampled out values, so the count is already adjusted
+		value += s.adjuster.take(adjustKey(s.prefix, stat, tags))
+		rate = 1
+	}
+
+	if s.monotonic != nil {
+		var guarded bool
+		var err error
+		value, guarded, err = s.monotonic.guard(stat, value)
+		if guarded {
+			atomic.AddInt64(&s.counters.negativeCounts, 1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.scales != nil {
+		if factor, ok := s.scales[stat]; ok {
+			return s.submitScaledCount(stat, float64(value)*factor, rate, tags)
+		}
+	}
+
+	if rate < 1 {
+		switch s.counterScaling {
+		case CounterScaleClient:
+			scaled := int64(math.Round(float64(value) / float64(rate)))
+			return s.submit(stat, "", scaled, "|c", 1, tags)
+		case CounterScaleNone:
+			return s.submit(stat, "", value, "|c", 1, tags)
+		}
+	}
+	return s.submit(stat, "", value, "|c", rate, tags)
 }
 
-// check for nil client, and perform sampling calculation
-func (s *Client) includeStat(rate float32) bool {
+// hold the value of a sampled out count, if sample adjustment is enabled
+func (s *Client) holdCount(stat string, value int64, tags []Tag) {
+	if s == nil || s.adjuster == nil || !s.Enabled() {
+		return
+	}
+	s.adjuster.hold(adjustKey(s.prefix, stat, tags), value)
+}
+
+// check for nil client, and perform sampling calculation.
+// returns the rate to submit the stat with, and whether to submit it at all.
+func (s *Client) includeStat(stat string, rate float32) (float32, bool) {
 	if s == nil {
-		return false
+		return rate, false
+	}
+	if s.disabled != nil && atomic.LoadInt32(s.disabled) != 0 {
+		return rate, false
+	}
+
+	if s.minRates != nil {
+		if floor, ok := s.minRates[stat]; ok && rate < floor {
+			rate = floor
+		}
+	}
+
+	// primed stats bypass sampling, so they are sent unscaled
+	if rate < 1 && s.primer != nil && s.primer.prime(s.prefix, stat) {
+		return 1, true
 	}
 
 	// test for nil in case someone builds their own
 	// client without calling new (result is nil sampler)
 	if s.sampler != nil {
-		return s.sampler(rate)
+		return rate, s.sampler(rate)
 	}
-	return DefaultSampler(rate)
+	return rate, DefaultSampler(rate)
+}
+
+// includeSampled is includeStat for counters and timings, the stats that
+// ClientConfig.SampleEvery applies to.
+func (s *Client) includeSampled(stat string, rate float32) (float32, bool) {
+	if s != nil && rate >= 1 && s.defaultRate > 0 {
+		rate = s.defaultRate
+	}
+	return s.includeStat(stat, rate)
 }
 
 // SetPrefix sets/updates the statsd client prefix.
@@ -354,6 +1104,25 @@ func (s *Client) SetPrefix(prefix string) {
 	s.prefix = prefix
 }
 
+// Prefix returns the statsd client prefix, including any changes made by
+// SetPrefix.
+func (s *Client) Prefix() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.prefix
+}
+
+// TagFormat returns the TagFormat the client submits tags in.
+func (s *Client) TagFormat() TagFormat {
+	if s == nil {
+		return 0
+	}
+
+	return s.tagFormat
+}
+
 // NewSubStatter returns a SubStatter with appended prefix
 func (s *Client) NewSubStatter(prefix string) SubStatter {
 	var c *Client
@@ -363,6 +1132,41 @@ func (s *Client) NewSubStatter(prefix string) SubStatter {
 			sender:    s.sender,
 			sampler:   s.sampler,
 			tagFormat: s.tagFormat,
+			primer:    s.primer,
+			tags:      s.tags,
+			ctxTags:   s.ctxTags,
+
+			counterScaling:    s.counterScaling,
+			tagFilter:         s.tagFilter,
+			timingUnit:        s.timingUnit,
+			monotonic:         s.monotonic,
+			omitSampleRate:    s.omitSampleRate,
+			emptyTags:         s.emptyTags,
+			adjuster:          s.adjuster,
+			mirrors:           s.mirrors,
+			counters:          s.counters,
+			catalog:           s.catalog,
+			defaultRate:       s.defaultRate,
+			minRates:          s.minRates,
+			clamps:            s.clamps,
+			scales:            s.scales,
+			duplicateTags:     s.duplicateTags,
+			strict:            s.strict,
+			maxPacket:         s.maxPacket,
+			aggregator:        s.aggregator,
+			local:             s.local,
+			gaugeDedup:        s.gaugeDedup,
+			tracer:            s.tracer,
+			ctxTraceID:        s.ctxTraceID,
+			server:            s.server,
+			histogramFallback: s.histogramFallback,
+			disabled:          s.disabled,
+			memory:            s.memory,
+			batchSeparator:    s.batchSeparator,
+			batchFlushBytes:   s.batchFlushBytes,
+			nameTransform:     s.nameTransform,
+			signals:           s.signals,
+			metrics:           s.metrics,
 		}
 	}
 	return c
@@ -5,15 +5,100 @@
 package statsd
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"time"
 )
 
+// buffered sender defaults, for a FlushBytes or FlushInterval of 0
+const (
+	// ref:
+	// github.com/etsy/statsd/blob/master/docs/metric_types.md#multi-metric-packets
+	defaultFlushBytes    = 1432
+	defaultFlushInterval = 300 * time.Millisecond
+)
+
+var errSingleWriterBackground = errors.New("SingleWriter can't be used with FlushOnSignal, IdleFlush, Aggregate or LocalCounters")
+
+// osHostname looks up the hostname for ClientConfig.HostnameTag. Replaced in
+// tests.
+var osHostname = os.Hostname
+
 type ClientConfig struct {
 	// addr is a string of the format "hostname:port", and must be something
-	// validly parsable by net.ResolveUDPAddr.
+	// validly parsable by net.ResolveUDPAddr. IPv6 literals must be
+	// bracketed, eg. "[2001:db8::1]:8125" (see net.JoinHostPort).
+	// For stream networks (see Network), it is the address to dial, eg. a
+	// socket path for "unix". For "http", it is the url to POST stats to.
 	Address string
 
+	// Destinations is a list of servers to submit every stat to, each in its
+	// own tag format, eg. to feed both an old and a new backend while
+	// migrating between them. Each destination gets its own sender,
+	// configured as for Address (so Network, UseBuffered, etc apply to
+	// all). The first destination is the primary one, whose tag format is
+	// reported by Client.TagFormat. If set, Address, Addresses, Sender and
+	// TagFormat are ignored.
+	Destinations []Destination
+
+	// TypeRoutes maps stat types, as written on the wire (eg. TypeTiming),
+	// to the address of the server to submit them to, eg. to send timings
+	// to a different aggregator than counters and gauges. Each distinct
+	// address gets its own sender, configured as for Address (so Network,
+	// UseBuffered, etc apply to all). Stats of types without a route are
+	// submitted as usual, to Address (or Sender, or Addresses). Ignored if
+	// Destinations is set.
+	TypeRoutes map[string]string
+
+	// Sender is an existing Sender to submit stats through, instead of
+	// dialing Address, so that many clients (eg. with different prefixes or
+	// tags) can share one connection. The Sender must be safe for concurrent
+	// use, as all the Senders in this package are. Closing a client does not
+	// close a shared Sender; its owner must close it once all the clients
+	// are done with it. If set, Address, Addresses, Network and ResInterval
+	// are ignored.
+	Sender Sender
+
+	// Addresses is a list of server addresses to spread stats over, by
+	// consistent hashing of the stat name (see ShardingSender). If set,
+	// Address and ResInterval are ignored. Only supported for udp.
+	Addresses []string
+
+	// ShardHash is the HashFunc used to choose a server from Addresses for
+	// each stat. If nil, FNVHash is used.
+	ShardHash HashFunc
+
+	// Network is the network used to reach the server. One of "udp"
+	// (the default), "tcp", "unix" or "http" (see HTTPSender).
+	// ResInterval is ignored for stream networks (tcp, unix), as they are
+	// re-dialed after any send error, and for http.
+	Network string
+
+	// HTTPGzip enables gzip compression of HTTPSender request bodies of at
+	// least HTTPGzipMinBytes, sent with "Content-Encoding: gzip". Only
+	// enable it if the endpoint supports compressed bodies.
+	HTTPGzip bool
+
+	// HTTPGzipMinBytes is the smallest request body compressed when HTTPGzip
+	// is enabled, as compressing small bodies costs more than it saves.
+	// Defaults to 1024 bytes.
+	HTTPGzipMinBytes int
+
+	// Framing controls how stats are delimited on stream networks
+	// (tcp, unix). It is ignored for udp, where each packet is self
+	// delimiting. Default is FramingNewline. With FramingLengthPrefixed or
+	// FramingNone, buffered stats are split on the BufferSeparator, and each
+	// framed individually.
+	Framing Framing
+
+	// DialTimeout bounds how long dialing a stream network (tcp, unix)
+	// connection may take, both when the client is created and when
+	// re-dialing after a send error, so an unreachable server can't block
+	// startup. Combine with RetryInitialDial to start degraded instead of
+	// failing. Defaults to 5 seconds.
+	DialTimeout time.Duration
+
 	// prefix is the statsd client prefix. Can be "" if no prefix is desired.
 	Prefix string
 
@@ -42,63 +127,675 @@ type ClientConfig struct {
 	// the recommended value.
 	FlushBytes int
 
+	// FlushCount forces a flush once this many stats are buffered, even if
+	// FlushBytes has not been reached, for lower latency with small stats.
+	// Whichever of FlushBytes and FlushCount is reached first triggers the
+	// flush. If FlushCount is 0, only FlushBytes and FlushInterval apply.
+	FlushCount int
+
+	// SingleWriter is a promise that the client is only ever used from a
+	// single goroutine, as in many command line tools. The buffer is then
+	// used without any locking, and there is no background flushing: all
+	// flushes (including those due to FlushInterval passing, which is only
+	// checked when a stat is sent) happen synchronously, as part of sending
+	// a stat. Stats may therefore wait in the buffer indefinitely if no more
+	// are sent, until Flush or Close is called.
+	//
+	// Using a SingleWriter client from multiple goroutines is unsafe, and
+	// will corrupt or lose stats. That includes Flush, Close and
+	// BufferStats, which must be called from the sending goroutine too, and
+	// rules out helpers that submit from their own goroutine
+	// (Client.WatchGauge, NewResettingGauge and NewGCStats). Config that
+	// flushes or submits in the background (FlushOnSignal, IdleFlush,
+	// Aggregate and LocalCounters) is rejected by NewClientWithConfig.
+	// Only applies when UseBuffered is set.
+	SingleWriter bool
+
+	// BufferShards splits the buffer into that many shards, each with its
+	// own lock, flushed independently on their own FlushBytes, FlushCount,
+	// FlushInterval and IdleFlush triggers, and all flushed on Flush and
+	// Close. It cuts lock contention when many goroutines send stats at
+	// once; runtime.GOMAXPROCS(0) is a good choice. Stats sent from
+	// different shards are sent in no particular order.
+	// Only applies when UseBuffered is set, and not SingleWriter. Default
+	// is 0, for a single buffer.
+	BufferShards int
+
+	// IdleFlush flushes the buffer once no stats have been sent for this
+	// long, so that the last stats of a burst are sent promptly, rather than
+	// waiting for FlushInterval. It complements FlushInterval, which still
+	// applies while stats are being sent. If 0, there is no idle flush.
+	// Rejected with SingleWriter, which never flushes in the background.
+	IdleFlush time.Duration
+
+	// MaxBufferAge drops buffered stats that have waited longer than this to
+	// be sent (eg. behind a slow or stalled server), rather than sending
+	// stale data. Dropped stats are counted in BufferStats.Expired. If 0,
+	// buffered stats never expire.
+	MaxBufferAge time.Duration
+
+	// FlushStats submits a "statsd.flush" counter (under the client prefix)
+	// for each flush of the buffer, tagged with what triggered it:
+	// reason:full, reason:count, reason:interval, reason:idle (see
+	// IdleFlush) or reason:explicit. The counter is buffered in turn, so is
+	// sent with the following flush.
+	// Flushes on close are counted in BufferStats, but not submitted.
+	// Only applies when UseBuffered is set.
+	FlushStats bool
+
+	// SortBuffered sorts the buffered stats within each flush by name, so
+	// that packets have reproducible contents for a given set of stats, for
+	// diffing captured packets. Stats with the same name are kept in the
+	// order they were sent. This is a debugging
+	// aid, that costs some performance, so is off by default. It has no
+	// effect with an empty BufferSeparator.
+	SortBuffered bool
+
+	// BufferSeparator is written between stats buffered into the same
+	// packet. If nil, defaults to a newline, which is what almost all
+	// servers expect. A non-nil empty separator ([]byte{}) writes stats with
+	// no separator at all, for relays that rely on some other framing.
+	// A separator is never written after the last stat in a packet.
+	BufferSeparator []byte
+
 	// The desired tag format to use for tags (note: statsd tag support varies)
 	// Supported formats are one of: statsd.DataDog, statsd.Grahpite, statsd.Influx
 	TagFormat TagFormat
+
+	// Tags are default tags added to every stat submitted by the client.
+	// They are written ahead of any context or per-call tags.
+	Tags []Tag
+
+	// HostnameTag adds a "host" default tag, with the hostname from
+	// os.Hostname (or Hostname, if set). If the hostname can not be
+	// determined, no tag is added.
+	HostnameTag bool
+
+	// Hostname overrides the detected hostname used by HostnameTag.
+	Hostname string
+
+	// AllowedTagKeys restricts the tag keys that are submitted. Tags with
+	// keys not in the list are dropped, and counted in ClientStats.
+	// If empty, all tag keys are allowed.
+	AllowedTagKeys []string
+
+	// DeniedTagKeys lists tag keys that are never submitted. Tags with keys
+	// in the list are dropped, and counted in ClientStats. A key in both
+	// lists is denied.
+	DeniedTagKeys []string
+
+	// PrimeCount is the number of initial occurrences of each stat name that
+	// bypass sampling and are always sent, so that rarely hit stats still show
+	// up at least once. Primed stats are sent without a sample rate.
+	// The number of distinct stat names tracked is bounded; once the bound is
+	// reached, stats with previously unseen names are sampled as usual.
+	// If PrimeCount is 0, priming is disabled.
+	PrimeCount int
+
+	// CounterScaling controls how counters (Inc/Dec) sent with a sample rate
+	// below 1 are scaled. Default is CounterScaleServer, which sends the
+	// count as-is with the sample rate, leaving the server to scale it.
+	CounterScaling CounterScaling
+
+	// TimingUnit is the unit TimingDuration submits values in. One of
+	// time.Millisecond (the default), time.Microsecond or time.Nanosecond,
+	// for sub-millisecond timings without a long fraction (eg. 3 rather
+	// than 0.003 for 3µs in microseconds).
+	//
+	// The standard "|ms" type is always used, as it is the only timing type
+	// servers understand, so the value is scaled, not relabelled. Servers
+	// only aggregating timings (eg. percentiles) are unaffected, but the
+	// backend must be set up to expect values in TimingUnit, or they will
+	// be mislabelled.
+	// Timing (with an integer millisecond value) is not affected. For a
+	// per-call unit, see Client.TimingDurationIn.
+	TimingUnit time.Duration
+
+	// EmptyTagValues controls how tags with an empty value are submitted.
+	// Default is EmptyTagKeep, which submits them as "key:" (or "key=" for
+	// infix formats). Some servers reject these, so they can be dropped
+	// (EmptyTagDrop) or submitted as just the key (EmptyTagKeyOnly).
+	EmptyTagValues EmptyTagValues
+
+	// OmitSampleRate keeps client side sampling, but leaves the "|@rate"
+	// sample rate off the submitted stats, for servers that do not
+	// understand it. The server can then not scale sampled stats back up, so
+	// sampled counts will read low (by a factor of the sample rate), unless
+	// CounterScaling is CounterScaleClient.
+	OmitSampleRate bool
+
+	// SampleEvery samples counters and timings submitted with a rate of 1
+	// (ie. unsampled) to send only 1 of every SampleEvery of them, with the
+	// matching sample rate (eg. "|@0.001000" for 1000). Stats submitted with
+	// a rate below 1 keep their own rate. Gauges, sets and other stats are
+	// not sampled by it, as a sampled out gauge or set member is lost, not
+	// scaled back up. For one in N sampling of individual stats, see
+	// SampleEvery (the function). If 0 or 1, stats are not sampled by
+	// default.
+	SampleEvery int
+
+	// MinRate sets a floor on the sample rate of the named stats, so rare
+	// but important stats (eg. errors) are never sampled more aggressively
+	// than their floor, whatever rate they are submitted with, or
+	// SampleEvery. The submitted sample rate is the effective one. A floor
+	// of 1.0 means the stat is never sampled. Names are matched against the
+	// stat name as passed to Inc etc., without any prefix.
+	MinRate map[string]float32
+
+	// ClampRange limits the values of the named stats to a [min, max]
+	// range, to protect downstream systems from garbage values (eg. a
+	// glitching sensor). It applies to Gauge, GaugeFloat, Timing,
+	// TimingDuration, TimingDurationIn, Histogram and Distribution values,
+	// in the unit they are submitted in. Out of range values are clamped
+	// to the nearest bound, and counted (see ClientStats.ClampedValues).
+	// Names are matched against the stat name as passed to Gauge etc.,
+	// without any prefix.
+	ClampRange map[string][2]float64
+
+	// ScaleFactor multiplies the counts (Inc, Dec and the like) of the
+	// named stats by a factor before they are submitted, eg. 1.0/1024 to
+	// count bytes as kilobytes. Scaled counts that are not whole numbers
+	// are submitted as floats. Monotonic counter checks (see
+	// MonotonicCounters) apply to the unscaled count. Names are matched
+	// against the stat name as passed to Inc etc., without any prefix.
+	ScaleFactor map[string]float64
+
+	// SampleAdjust makes sampled counters (Inc and Dec) hold on to the values
+	// of sampled out submissions, and add them to the next sampled in
+	// submission of the same counter (name and tags), which is then sent
+	// without a sample rate. This keeps sums correct for servers that do not
+	// scale sampled counts, while still reducing the number of packets.
+	// Values held when the client is closed are lost. Takes precedence over
+	// CounterScaling.
+	SampleAdjust bool
+
+	// MonotonicCounters lists counters that must never go down. A negative
+	// delta for one of them (Inc with a negative value, or Dec with a
+	// positive one) is handled according to MonotonicGuard, and counted
+	// (see ClientStats.NegativeCounts). Names are matched against the stat
+	// name as passed to Inc or Dec, without any prefix.
+	MonotonicCounters []string
+
+	// MonotonicGuard controls how negative deltas for MonotonicCounters are
+	// handled. Default is MonotonicReject.
+	MonotonicGuard MonotonicGuard
+
+	// RetryInitialDial makes NewClientWithConfig return a usable client even
+	// if the server can not be resolved or dialed yet. Creating the sender is
+	// retried every RetryInterval in the background, and the client starts
+	// sending once it succeeds. Until then, stats are dropped and counted
+	// (see ClientStats.DroppedStats). With Destinations, each destination
+	// that can't be created yet is retried on its own.
+	RetryInitialDial bool
+
+	// RetryInterval is the interval between dial attempts when
+	// RetryInitialDial is set. Defaults to 5 seconds.
+	RetryInterval time.Duration
+
+	// WhenDisconnected controls what happens to stats sent while the server
+	// is not yet reachable, with RetryInitialDial. They can be dropped with
+	// an error (the default), dropped silently, or held for delivery once
+	// the server is reachable. Dropped stats are counted (see
+	// ClientStats.DroppedStats).
+	WhenDisconnected DisconnectedPolicy
+
+	// DisconnectedBufferBytes bounds the data held while the server is not
+	// yet reachable, when WhenDisconnected is DisconnectedBuffer. Stats past
+	// the bound, and any still held when the client is closed, are dropped.
+	// Defaults to 64KiB.
+	DisconnectedBufferBytes int
+
+	// SendRetries is the number of times a failed send is retried, with a
+	// short and increasing backoff, before the stat is dropped and counted
+	// (see ClientStats.DroppedStats). This is for networks where a udp send
+	// transiently fails (eg. with EAGAIN or ENOBUFS) and an immediate retry
+	// succeeds. Sends to a closed sender are never retried. If buffered,
+	// whole buffers are retried. Default is 0, no retries.
+	SendRetries int
+
+	// BreakerFailures enables a circuit breaker around the sender, which
+	// opens after this many consecutive failed sends (after any
+	// SendRetries), within BreakerWindow. While open, stats are dropped
+	// without trying to send them, and counted (see
+	// ClientStats.DroppedStats), rather than adding the latency of a
+	// failing send to every stat. After BreakerCooldown, a single send is
+	// tried as a probe, closing the breaker if it succeeds, or opening it
+	// again if not. The state is reported by Client.BreakerState. If
+	// buffered, whole buffers are sent or dropped. Default is 0, no breaker.
+	BreakerFailures int
+
+	// BreakerWindow bounds how long a run of consecutive failures may span
+	// to open the breaker. Failures further apart start a new run. If 0,
+	// consecutive failures open the breaker however long they span.
+	BreakerWindow time.Duration
+
+	// BreakerCooldown is how long the breaker stays open before probing.
+	// Defaults to 10 seconds.
+	BreakerCooldown time.Duration
+
+	// SeenStatsLimit enables tracking of the distinct stat names submitted,
+	// for Client.SeenStats, up to this many names. Once the limit is
+	// reached, stats with previously unseen names are still submitted, but
+	// not tracked, and are counted (see ClientStats.UntrackedStats).
+	// Tracking adds a little overhead to every submission. If 0, stat names
+	// are not tracked.
+	SeenStatsLimit int
+
+	// Aggregate enables client side aggregation of counters (Inc and Dec) and
+	// gauges (Gauge and GaugeFloat), to cut the number of packets sent for
+	// hot stats. Within each AggregateInterval, counters with the same name
+	// and tags are summed, and gauges keep their last value, then one stat
+	// is submitted per series. Stats from SubStatters (eg. from WithContext)
+	// with the same prefix, name and tags are the same series. Sampled stats, and stats with per-call
+	// options, are submitted as usual. Aggregated stats are held until the
+	// end of the window, or until the client is flushed or closed. A gauge
+	// update that is not aggregated (eg. GaugeDelta) first submits any held
+	// value of its series, so that it applies on top of it.
+	Aggregate bool
+
+	// AggregateInterval is the aggregation window when Aggregate is set.
+	// Defaults to 1 second.
+	AggregateInterval time.Duration
+
+	// LocalCounters enables Client.AddLocal, which accumulates counters in
+	// memory with atomic adds, and submits the sums every
+	// LocalCounterInterval. It is for counters incremented millions of times
+	// a second. Counters with nothing added for a whole interval are dropped
+	// from memory. The number of distinct counters accumulated is bounded;
+	// once reached, AddLocal for previously unseen counters submits them as
+	// usual.
+	LocalCounters bool
+
+	// LocalCounterInterval is the interval sums from AddLocal are submitted
+	// at, when LocalCounters is set. Defaults to 1 second.
+	LocalCounterInterval time.Duration
+
+	// ChangedGauges lists gauges that are only sent when their value
+	// changes, for gauges set by a polling loop. A Gauge, GaugeFloat,
+	// GaugeBool or GaugeDiff with the same value and tags as the last one
+	// sent for that name is suppressed, unless ChangedGaugeHeartbeat has
+	// passed since. Do not also use GaugeDelta for these gauges, as it would
+	// make the last sent value stale. Names are matched against the stat
+	// name as passed to Gauge, without any prefix. The number of distinct
+	// series (name and tags) remembered is bounded; once reached, gauges for
+	// previously unseen series are always sent.
+	ChangedGauges []string
+
+	// ChangedGaugeHeartbeat is how often an unchanged gauge in ChangedGauges
+	// is still sent, to keep the series alive. Defaults to 1 minute.
+	ChangedGaugeHeartbeat time.Duration
+
+	// TraceIDFraction is the fraction (0.0 to 1.0) of submitted stats tagged
+	// with the current trace ID, as "trace_id", to correlate metrics with
+	// traces. The trace ID is taken from the context of a client returned by
+	// WithContext (see ContextWithTraceID), or else from TraceIDFunc. Stats
+	// without a trace ID are not tagged. The choice of stats to tag is
+	// independent of sampling: only stats that are sent can be tagged. The
+	// tag follows any default, context and per-call tags, and is subject to
+	// AllowedTagKeys and DeniedTagKeys. Trace IDs are high cardinality, so
+	// keep the fraction small. If 0, stats are not tagged.
+	TraceIDFraction float32
+
+	// TraceIDFunc returns the current trace ID (eg. from a tracing library),
+	// for stats submitted without one from a context. May return "" if
+	// there is none.
+	TraceIDFunc func() string
+
+	// Server is the kind of server stats are submitted to, which determines
+	// the stat types reported as supported by Client.Supports. It does not
+	// change what is submitted. Default is ServerUnknown, which reports
+	// every type as supported.
+	Server ServerFlavor
+
+	// HistogramFallback submits histograms (Histogram, and HistogramBuckets
+	// with BucketValues) and distributions as timings ("|ms") when Server
+	// does not support them (eg. ServerStatsd), as such servers drop them.
+	// Values are submitted as-is, so should be in milliseconds for the
+	// server to label them correctly. Has no effect for ServerUnknown, as
+	// every type is assumed to be supported.
+	HistogramFallback bool
+
+	// MaxBufferMemory is a soft cap, in bytes, on the memory held by the
+	// client's aggregated stats (see Aggregate) and request batches (see
+	// Client.NewRequestBatch), which otherwise grows with the number of distinct
+	// stats. Once reached, the aggregated stats (at most once per
+	// AggregateInterval) or batch being added to are flushed to make room, and
+	// if there is still none, new stats are dropped and counted in
+	// ClientStats.DroppedOverMemory until memory is freed. Stats dropped from a
+	// batch return ErrOverMemory. Memory use is estimated, not measured. The
+	// send buffer is already bounded by FlushBytes. Default is 0, for no limit.
+	MaxBufferMemory int
+
+	// NameTransform, if set, rewrites every stat name before it is
+	// submitted, eg. to enforce a naming convention rather than reject
+	// stats that don't follow it. It is applied to the stat name only: the
+	// prefix (including any SubStatter prefixes) and tags are never
+	// transformed. Stat names elsewhere in the configuration (eg. MinRate
+	// and ClampRange keys) are the names before transformation.
+	NameTransform func(string) string
+
+	// DuplicateTags controls how tags with the same key are submitted, eg.
+	// a per-call tag with the same key as a default tag. Default is
+	// DuplicateTagKeepBoth, submitting them all.
+	DuplicateTags DuplicateTagPolicy
+
+	// FlushOnSignal flushes the client when the process receives any of
+	// the signals (eg. os.Interrupt), so short lived programs that exit on
+	// a signal don't lose their last buffered or aggregated stats. Once
+	// flushed, the client stops watching for signals, and re-raises the
+	// signal, so it has its usual effect (eg. exiting the process). Watching
+	// stops on Close, or Client.StopFlushOnSignal.
+	// Handlers are not chained: programs that handle the signals themselves
+	// (with signal.Notify) see them twice, and don't exit on the re-raised
+	// signal, so should rather flush or close the client from their own
+	// handler.
+	FlushOnSignal []os.Signal
+
+	// CloseOnSignal closes, rather than just flushes, the client on the
+	// FlushOnSignal signals.
+	CloseOnSignal bool
+
+	// Strict returns an error for every stat the client would otherwise
+	// drop silently, each with its own sentinel error, eg. for smoke tests
+	// that should catch instrumentation problems. Stats are still dropped,
+	// and counted where they usually are. The conditions are:
+	//   - sampled out by the sample rate: ErrSampledOut
+	//   - submitted to a disabled client: ErrDisabled
+	//   - over FlushBytes (or its default) as a single stat, for udp (not
+	//     a shared Sender): ErrPacketTooLarge, rather than sending a packet
+	//     that may be lost
+	//   - sent while the server is not yet reachable: ErrNotConnected
+	//   - sent while the circuit breaker is open: ErrCircuitOpen, unless
+	//     UseBuffered is set, as buffered stats are sent by the flush
+	//     goroutine, which gets the error instead
+	//   - over MaxBufferMemory: ErrOverMemory
+	// Errors from senders are wrapped in a SendError. Default is false, for
+	// production use.
+	Strict bool
 }
 
 // NewClientWithConfig returns a new BufferedClient
 //
 // config is a ClientConfig, which holds various configuration values.
 func NewClientWithConfig(config *ClientConfig) (Statter, error) {
-	var sender Sender
-	var err error
-
 	// guard against nil config
 	if config == nil {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	// Use a re-resolving simple sender iff:
+	// a single writer buffer must only ever be used by the sending
+	// goroutine
+	if config.UseBuffered && config.SingleWriter && (len(config.FlushOnSignal) > 0 ||
+		config.IdleFlush > 0 || config.Aggregate || config.LocalCounters) {
+		return nil, errSingleWriterBackground
+	}
+
+	if len(config.Destinations) > 0 {
+		return newDestinationsClient(config)
+	}
+
+	// counters are shared between the client and any senders that count
+	// dropped stats
+	counters := &clientCounters{}
+
+	sender, err := newConfigSender(config, counters)
+	if err != nil {
+		if !config.RetryInitialDial {
+			return nil, err
+		}
+
+		// degrade to dropping stats until the sender can be created
+		rs := newRetryingConfigSender(config, counters)
+		client, err := newCountedClient(rs, config, counters)
+		if err != nil {
+			rs.Close()
+			return nil, err
+		}
+		return client, nil
+	}
+
+	client, err := newCountedClient(sender, config, counters)
+	if err != nil {
+		// the sender is already started
+		sender.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// newConfigSender returns the Sender described by config. Any stats it drops
+// are counted in counters.
+func newConfigSender(config *ClientConfig, counters *clientCounters) (Sender, error) {
+	if len(config.TypeRoutes) > 0 {
+		return newTypeRoutingSender(config, counters)
+	}
+
+	var sender Sender
+	var err error
+
+	// Use any shared sender as-is, a sharding sender for multiple
+	// addresses, an http sender for http, and a stream sender for stream
+	// networks (tcp, unix).
+	// Otherwise, use a re-resolving simple sender iff:
 	// *  The time duration greater than 0
 	// *  The Address is not an ip (eg. {ip}:{port}).
 	// Otherwise, re-resolution is not required.
-	if config.ResInterval > 0 && !mustBeIP(config.Address) {
+	switch {
+	case config.Sender != nil:
+		sender = sharedSender{config.Sender}
+	case len(config.Addresses) > 0:
+		sender, err = NewShardingSenderWithSeparator(config.Addresses, config.ShardHash, config.BufferSeparator)
+	case config.Network == "http":
+		sender, err = newConfigHTTPSender(config)
+	case config.Network != "" && config.Network != "udp":
+		var separator []byte
+		if config.UseBuffered {
+			separator = config.BufferSeparator
+		}
+		sender, err = newStreamSender(config.Network, config.Address, config.Framing, separator, config.DialTimeout)
+	case config.ResInterval > 0 && !mustBeIP(config.Address):
 		sender, err = NewResolvingSimpleSender(config.Address, config.ResInterval)
-	} else {
+	default:
 		sender, err = NewSimpleSender(config.Address)
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	if config.SendRetries > 0 {
+		sender = &resendingSender{sender, config.SendRetries, counters}
+	}
+
+	if config.BreakerFailures > 0 {
+		sender = newBreakerSender(sender, config, counters)
+	}
+
 	if config.UseBuffered {
-		return newBufferedC(sender, config)
-	} else {
-		return NewClientWithSender(sender, config.Prefix, config.TagFormat)
+		bufSender, err := newBufferedSender(sender, config)
+		if err != nil {
+			sender.Close()
+			return nil, err
+		}
+		sender = bufSender
+	}
+	return sender, nil
+}
+
+// newRetryingConfigSender returns a retryingSender that retries creating the
+// Sender described by config in the background, for RetryInitialDial.
+func newRetryingConfigSender(config *ClientConfig, counters *clientCounters) *retryingSender {
+	// copy the config, in case the caller reuses it
+	retryConfig := *config
+	rs := newRetryingSender(func() (Sender, error) {
+		return newConfigSender(&retryConfig, counters)
+	}, config.RetryInterval, config.WhenDisconnected, config.DisconnectedBufferBytes, counters)
+	if config.Strict {
+		rs.dropErr = ErrNotConnected
+	}
+	return rs
+}
+
+func newConfigHTTPSender(config *ClientConfig) (Sender, error) {
+	sender, err := newHTTPSender(config.Address)
+	if err != nil {
+		return nil, err
 	}
+
+	if config.HTTPGzip {
+		sender.gzipMin = config.HTTPGzipMinBytes
+		if sender.gzipMin <= 0 {
+			sender.gzipMin = defaultGzipMinBytes
+		}
+	}
+	return sender, nil
 }
 
-func newBufferedC(baseSender Sender, config *ClientConfig) (Statter, error) {
+func newBufferedSender(baseSender Sender, config *ClientConfig) (Sender, error) {
+	if config.BufferShards > 1 && !config.SingleWriter {
+		// the shards share the base sender, which is closed once they are
+		shards := make([]*BufferedSender, config.BufferShards)
+		for i := range shards {
+			shards[i] = newConfigBufferedSender(sharedSender{baseSender}, config)
+		}
+		return newShardedBufferedSender(baseSender, shards), nil
+	}
+	return newConfigBufferedSender(baseSender, config), nil
+}
 
+// newConfigBufferedSender returns a started BufferedSender, configured by
+// config.
+func newConfigBufferedSender(baseSender Sender, config *ClientConfig) *BufferedSender {
 	flushBytes := config.FlushBytes
 	if flushBytes <= 0 {
-		// ref:
-		// github.com/etsy/statsd/blob/master/docs/metric_types.md#multi-metric-packets
-		flushBytes = 1432
+		flushBytes = defaultFlushBytes
 	}
 
 	flushInterval := config.FlushInterval
 	if flushInterval <= time.Duration(0) {
-		flushInterval = 300 * time.Millisecond
+		flushInterval = defaultFlushInterval
+	}
+
+	bufSender := newBufferedSenderWithSender(baseSender, flushInterval, flushBytes)
+	bufSender.flushCount = config.FlushCount
+	bufSender.sortStats = config.SortBuffered
+	bufSender.maxAge = config.MaxBufferAge
+	bufSender.single = config.SingleWriter
+	bufSender.idleFlush = config.IdleFlush
+	if config.BufferSeparator != nil {
+		bufSender.separator = append([]byte{}, config.BufferSeparator...)
 	}
+	bufSender.Start()
+	return bufSender
+}
 
-	bufsender, err := NewBufferedSenderWithSender(baseSender, flushInterval, flushBytes)
+// newClientWithConfig returns a Client for the supplied sender, with any
+// non-sender related config values applied.
+func newClientWithConfig(sender Sender, config *ClientConfig) (Statter, error) {
+	return newCountedClient(sender, config, &clientCounters{})
+}
+
+// newCountedClient is newClientWithConfig, for a client sharing counters with
+// its senders.
+func newCountedClient(sender Sender, config *ClientConfig, counters *clientCounters) (Statter, error) {
+	client, err := newClient(sender, config.Prefix, config.TagFormat)
 	if err != nil {
 		return nil, err
 	}
+	client.counters = counters
+
+	if len(config.Tags) > 0 {
+		client.tags = append([]Tag(nil), config.Tags...)
+	}
+
+	if config.HostnameTag {
+		host := config.Hostname
+		if host == "" {
+			// no tag is better than failing to create the client
+			host, _ = osHostname()
+		}
+		if host != "" {
+			client.tags = append(client.tags, Tag{"host", host})
+		}
+	}
+
+	client.counterScaling = config.CounterScaling
+	client.tagFilter = newTagFilter(config.AllowedTagKeys, config.DeniedTagKeys)
+
+	if config.TimingUnit != 0 {
+		if !timingUnits[config.TimingUnit] {
+			return nil, fmt.Errorf("Invalid TimingUnit %s", config.TimingUnit)
+		}
+		client.timingUnit = config.TimingUnit
+	}
 
-	return NewClientWithSender(bufsender, config.Prefix, config.TagFormat)
+	client.omitSampleRate = config.OmitSampleRate
+	if config.SampleEvery > 1 {
+		client.defaultRate = SampleEvery(config.SampleEvery)
+	}
+	client.clamps = newValueClamps(config.ClampRange)
+	client.scales = newValueScales(config.ScaleFactor)
+	if len(config.MinRate) > 0 {
+		client.minRates = make(map[string]float32, len(config.MinRate))
+		for stat, rate := range config.MinRate {
+			client.minRates[stat] = rate
+		}
+	}
+	client.emptyTags = config.EmptyTagValues
+	if config.SampleAdjust {
+		client.adjuster = newSampleAdjuster()
+	}
+	client.monotonic = newMonotonicGuard(config.MonotonicCounters, config.MonotonicGuard)
+	client.gaugeDedup = newGaugeDeduper(config.ChangedGauges, config.ChangedGaugeHeartbeat)
+	client.tracer = newTraceTagger(config.TraceIDFraction, config.TraceIDFunc)
+	client.server = config.Server
+	client.histogramFallback = config.HistogramFallback
+
+	if config.PrimeCount > 0 {
+		client.primer = newPrimer(config.PrimeCount)
+	}
+
+	if config.SeenStatsLimit > 0 {
+		client.catalog = newStatCatalog(config.SeenStatsLimit)
+	}
+
+	client.memory = newMemoryBudget(config.MaxBufferMemory)
+	if config.BufferSeparator != nil {
+		client.batchSeparator = append([]byte{}, config.BufferSeparator...)
+	}
+	client.batchFlushBytes = config.FlushBytes
+	client.nameTransform = config.NameTransform
+	client.duplicateTags = config.DuplicateTags
+	if config.Strict {
+		client.strict = true
+		client.maxPacket = strictMaxPacket(config)
+	}
+
+	if config.Aggregate {
+		client.aggregator = newAggregator(config.AggregateInterval, client.memory, client.counters)
+	}
+
+	if config.LocalCounters {
+		client.local = newLocalCounters(config.LocalCounterInterval)
+	}
+
+	if config.FlushStats {
+		for _, bs := range bufferedShards(sender) {
+			// submitted without triggering flushes, so they can't cascade
+			fc := client.NewSubStatter("").(*Client)
+			fc.sender = noFlushSender{bs}
+			bs.setOnFlush(func(reason string) {
+				fc.Inc("statsd.flush", 1, 1.0, Tag{"reason", reason})
+			})
+		}
+	}
+
+	// last, as signals may be handled right away
+	client.signals = newSignalFlusher(client, config.FlushOnSignal, config.CloseOnSignal)
+	if client.signals != nil {
+		client.signals.start()
+	}
+
+	return client, nil
 }
 
 // NewClientWithSender returns a pointer to a new Client and an error.
@@ -110,6 +807,14 @@ func newBufferedC(baseSender Sender, config *ClientConfig) (Statter, error) {
 // tagFormat is the desired tag format, if any. If you don't plan on using
 // tags, use 0 to use the default.
 func NewClientWithSender(sender Sender, prefix string, tagFormat TagFormat) (Statter, error) {
+	client, err := newClient(sender, prefix, tagFormat)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func newClient(sender Sender, prefix string, tagFormat TagFormat) (*Client, error) {
 	if sender == nil {
 		return nil, fmt.Errorf("Client sender may not be nil")
 	}
@@ -127,6 +832,8 @@ func NewClientWithSender(sender Sender, prefix string, tagFormat TagFormat) (Sta
 		prefix:    prefix,
 		sender:    sender,
 		tagFormat: tagFormat,
+		counters:  &clientCounters{},
+		disabled:  new(int32),
 	}
 	return client, nil
 }
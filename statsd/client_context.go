@@ -0,0 +1,59 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// NewClientWithContext returns a new client, like NewClientWithConfig, but
+// if the server address can not be resolved, or a stream network (tcp, unix)
+// dialed, it keeps trying every config.RetryInterval (defaulting to 5
+// seconds) until it can, or ctx is done. This resolves startup ordering
+// races, eg. when the server's DNS name is not yet resolvable as a service
+// starts. Any other error is returned as-is, without retrying.
+//
+// If ctx is done first, the last resolution or dial error is returned,
+// wrapping the context error. Unlike with ClientConfig.RetryInitialDial, the
+// returned client is always connected.
+func NewClientWithContext(ctx context.Context, config *ClientConfig) (Statter, error) {
+	interval := defaultRetryInterval
+	if config != nil && config.RetryInterval > 0 {
+		interval = config.RetryInterval
+	}
+
+	var timer *time.Timer
+	for {
+		client, err := NewClientWithConfig(config)
+		if err == nil || !isDialError(err) {
+			return client, err
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(interval)
+			defer timer.Stop()
+		} else {
+			timer.Reset(interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// isDialError reports whether err is a failure to resolve or reach an
+// address, that may succeed later.
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) || errors.As(err, &opErr)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNewClientWithContextRetries(t *testing.T) {
+	// reserve an address, with nothing listening on it yet
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			close(received)
+			return
+		}
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			close(received)
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := NewClientWithContext(ctx, &ClientConfig{
+		Address:       addr,
+		Network:       "tcp",
+		Prefix:        "test",
+		RetryInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "test.count:1|c\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestNewClientWithContextDone(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c, err := NewClientWithContext(ctx, &ClientConfig{
+		Address:       addr,
+		Network:       "tcp",
+		RetryInterval: 10 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	if c != nil {
+		t.Fatalf("expected no client, got %v", c)
+	}
+}
+
+func TestNewClientWithContextConfigError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// config errors are not retried
+	start := time.Now()
+	_, err := NewClientWithContext(ctx, &ClientConfig{Address: "127.0.0.1:8125", TagFormat: 1 << 7})
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a config error, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("expected no retries, took %s", d)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "sync/atomic"
+
+// ClientStats holds counts of the things a Client drops or alters, rather
+// than submitting as-is. Counts are shared between a Client and its
+// SubStatters.
+type ClientStats struct {
+	// DroppedTags is the number of tags dropped by the tag key filter
+	// (see ClientConfig.AllowedTagKeys and ClientConfig.DeniedTagKeys).
+	DroppedTags int64
+
+	// DroppedStats is the number of stats dropped because the server was
+	// not yet reachable (see ClientConfig.RetryInitialDial and
+	// ClientConfig.WhenDisconnected), or because sending still failed after
+	// retrying (see ClientConfig.SendRetries), or while the circuit breaker
+	// was open (see ClientConfig.BreakerFailures), or because a strict
+	// client found them too large for a packet (see ClientConfig.Strict).
+	// A buffer dropped by the circuit breaker counts each stat it held;
+	// other dropped buffers count once, however many stats they held.
+	DroppedStats int64
+
+	// NegativeCounts is the number of negative deltas for monotonic counters
+	// that were rejected or clamped (see ClientConfig.MonotonicCounters).
+	NegativeCounts int64
+
+	// UntrackedStats is the number of submissions of stats whose names were
+	// not tracked for SeenStats, as ClientConfig.SeenStatsLimit had been
+	// reached.
+	UntrackedStats int64
+
+	// ClampedValues is the number of values that were out of range, and
+	// clamped (see ClientConfig.ClampRange).
+	ClampedValues int64
+
+	// DroppedOverMemory is the number of stats dropped because aggregated
+	// and batched stats held ClientConfig.MaxBufferMemory.
+	DroppedOverMemory int64
+}
+
+// clientCounters is the live, concurrency safe, version of ClientStats
+type clientCounters struct {
+	droppedTags    int64
+	droppedStats   int64
+	negativeCounts int64
+	untrackedStats int64
+	clampedValues  int64
+
+	droppedOverMemory int64
+}
+
+// Stats returns a snapshot of the client stats.
+func (s *Client) Stats() ClientStats {
+	if s == nil || s.counters == nil {
+		return ClientStats{}
+	}
+
+	return ClientStats{
+		DroppedTags:    atomic.LoadInt64(&s.counters.droppedTags),
+		DroppedStats:   atomic.LoadInt64(&s.counters.droppedStats),
+		NegativeCounts: atomic.LoadInt64(&s.counters.negativeCounts),
+		UntrackedStats: atomic.LoadInt64(&s.counters.untrackedStats),
+		ClampedValues:  atomic.LoadInt64(&s.counters.clampedValues),
+
+		DroppedOverMemory: atomic.LoadInt64(&s.counters.droppedOverMemory),
+	}
+}
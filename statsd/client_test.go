@@ -6,9 +6,15 @@ package statsd
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"log"
+	"math"
 	"net"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -146,6 +152,49 @@ func TestClientTags(t *testing.T) {
 			[]Tag{{"tag1", "val1"}, {"tag2", "val2"}},
 			"test.count;tag1=val1;tag2=val2:1|c",
 		},
+		{
+			InfixDotted,
+			"test", "Inc", "count", int64(1), 1.0,
+			[]Tag{{"tag1", "val1"}, {"tag2", "val2"}},
+			"test.count.tag1.val1.tag2.val2:1|c",
+		},
+		{
+			InfixDotted,
+			"test", "Inc", "count", int64(1), 1.0,
+			[]Tag{{"tag.1", "val.1"}},
+			"test.count.tag_1.val_1:1|c",
+		},
+		// tag separators in infix names are escaped, other formats keep them
+		{
+			InfixComma,
+			"test", "Inc", "count,by;host", int64(1), 1.0,
+			[]Tag{{"tag1", "val1"}},
+			"test.count_by;host,tag1=val1:1|c",
+		},
+		{
+			InfixComma,
+			"test", "Inc", "count,by;host", int64(1), 1.0,
+			nil,
+			"test.count_by;host:1|c",
+		},
+		{
+			InfixSemicolon,
+			"test", "Inc", "count,by;host", int64(1), 1.0,
+			[]Tag{{"tag1", "val1"}},
+			"test.count,by_host;tag1=val1:1|c",
+		},
+		{
+			SuffixOctothorpe,
+			"test", "Inc", "count,by;host", int64(1), 1.0,
+			[]Tag{{"tag1", "val1"}},
+			"test.count,by;host:1|c|#tag1:val1",
+		},
+		{
+			InfixDotted,
+			"test", "Inc", "count.by.host", int64(1), 1.0,
+			[]Tag{{"tag1", "val1"}},
+			"test.count.by.host.tag1.val1:1|c",
+		},
 	}
 
 	l, err := newUDPListener("127.0.0.1:0")
@@ -237,6 +286,34 @@ func newUDPListener(addr string) (*net.UDPConn, error) {
 	return l.(*net.UDPConn), nil
 }
 
+// recordingSender keeps a copy of every packet sent, for tests that need to
+// inspect output without reading from a udp listener.
+type recordingSender struct {
+	mx      sync.Mutex
+	packets []string
+	closed  bool
+}
+
+func (r *recordingSender) Send(data []byte) (int, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.packets = append(r.packets, string(data))
+	return len(data), nil
+}
+
+func (r *recordingSender) Close() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *recordingSender) sent() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]string(nil), r.packets...)
+}
+
 func ExampleClient() {
 	// First create a client config. Here is a simple config that sends one
 	// stat per packet (for compatibility).
@@ -310,3 +387,733 @@ func ExampleClient_nil() {
 	// Sicne client is nil, this is a noop.
 	err = client.Inc("stat1", 42, 1.0)
 }
+
+func TestSetBytes(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte{'a', 0x01, 0xfe, 'z'}
+	if err := c.(*Client).SetBytes("bytes", value, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	expected := "test.bytes:a\x01\xfez|s"
+	if got := rs.sent(); len(got) != 1 || got[0] != expected {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	for _, bad := range []string{"a:b", "a|b", "a\nb"} {
+		if err := c.(*Client).SetBytes("bytes", []byte(bad), 1.0); err == nil {
+			t.Fatalf("expected error for value %q", bad)
+		}
+	}
+	if got := rs.sent(); len(got) != 1 {
+		t.Fatalf("invalid values should not be sent, got %q", got)
+	}
+}
+
+func TestCounterScaling(t *testing.T) {
+	tests := []struct {
+		CounterScaling CounterScaling
+		Expected       []string
+	}{
+		{CounterScaleServer, []string{"test.count:3|c|@0.250000", "test.count:-3|c|@0.250000", "test.count:1|c|@0.300000", "test.count:3|c"}},
+		{CounterScaleClient, []string{"test.count:12|c", "test.count:-12|c", "test.count:3|c", "test.count:3|c"}},
+		{CounterScaleNone, []string{"test.count:3|c", "test.count:-3|c", "test.count:1|c", "test.count:3|c"}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			Prefix:         "test",
+			CounterScaling: tt.CounterScaling,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// sample everything in, so the output is deterministic
+		c.(*Client).SetSamplerFunc(func(float32) bool { return true })
+
+		c.Inc("count", 3, 0.25)
+		c.Dec("count", 3, 0.25)
+		// a rate that doesn't divide the count evenly
+		c.Inc("count", 1, 0.3)
+		// unsampled counters are unaffected by the scaling mode
+		c.Inc("count", 3, 1.0)
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Fatalf("CounterScaling %d: got %q expected %q", tt.CounterScaling, got, tt.Expected)
+		}
+	}
+}
+
+func TestAbsoluteStat(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "app", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Inc("shared.requests", 1, 1.0, Absolute())
+	// a leading '/' is not special
+	c.Inc("/shared.requests", 1, 1.0)
+	c.Inc("requests", 1, 1.0)
+	c.NewSubStatter("sub").Inc("shared.requests", 1, 1.0, Absolute())
+	c.NewSubStatter("sub").Inc("requests", 1, 1.0)
+
+	expected := []string{
+		"shared.requests:1|c",
+		"app./shared.requests:1|c",
+		"app.requests:1|c",
+		"shared.requests:1|c",
+		"app.sub.requests:1|c",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestTimingUnit(t *testing.T) {
+	tests := []struct {
+		TimingUnit time.Duration
+		Expected   string
+	}{
+		{0, "test.timing:0.003|ms"},
+		{time.Millisecond, "test.timing:0.003|ms"},
+		{time.Microsecond, "test.timing:3|ms"},
+		{time.Nanosecond, "test.timing:3000|ms"},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{Prefix: "test", TimingUnit: tt.TimingUnit})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.TimingDuration("timing", 3*time.Microsecond, 1.0)
+		// substatters share the unit
+		c.NewSubStatter("sub").TimingDuration("timing", 3*time.Microsecond, 1.0)
+
+		expected := []string{tt.Expected, strings.Replace(tt.Expected, "test.", "test.sub.", 1)}
+		if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("TimingUnit %s: got %q expected %q", tt.TimingUnit, got, expected)
+		}
+	}
+
+	_, err := newClientWithConfig(&recordingSender{}, &ClientConfig{TimingUnit: time.Second})
+	if err == nil {
+		t.Fatal("expected error for unsupported TimingUnit")
+	}
+}
+
+func TestNewClientWithConfigClosesSender(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, err = NewClientWithConfig(&ClientConfig{
+		Address:     l.Addr().String(),
+		Network:     "tcp",
+		UseBuffered: true,
+		TimingUnit:  time.Second,
+	})
+	if err == nil {
+		t.Fatal("expected error for unsupported TimingUnit")
+	}
+
+	// the sender dialed before the config was rejected is closed, not leaked
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestClientIntrospection(t *testing.T) {
+	c, err := NewClientWithConfig(&ClientConfig{
+		Address:   "127.0.0.1:8125",
+		Prefix:    "test",
+		TagFormat: InfixComma,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	i, ok := c.(Introspector)
+	if !ok {
+		t.Fatal("Client does not implement Introspector")
+	}
+	if got := i.Prefix(); got != "test" {
+		t.Errorf("Prefix: got %q expected %q", got, "test")
+	}
+	if got := i.TagFormat(); got != InfixComma {
+		t.Errorf("TagFormat: got %v expected %v", got, InfixComma)
+	}
+
+	c.SetPrefix("changed")
+	if got := i.Prefix(); got != "changed" {
+		t.Errorf("Prefix after SetPrefix: got %q expected %q", got, "changed")
+	}
+
+	s := c.NewSubStatter("sub").(Introspector)
+	if got := s.Prefix(); got != "changed.sub" {
+		t.Errorf("SubStatter Prefix: got %q expected %q", got, "changed.sub")
+	}
+	if got := s.TagFormat(); got != InfixComma {
+		t.Errorf("SubStatter TagFormat: got %v expected %v", got, InfixComma)
+	}
+
+	var nc *Client
+	if nc.Prefix() != "" || nc.TagFormat() != 0 {
+		t.Error("nil Client accessors should return zero values")
+	}
+}
+
+func TestOmitSampleRate(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:         "test",
+		OmitSampleRate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sampled out stats are still dropped
+	c.(*Client).SetSamplerFunc(func(float32) bool { return false })
+	c.Inc("count", 1, 0.5)
+	c.Timing("timing", 5, 0.5)
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("sampled out stats should not be sent, got %q", got)
+	}
+
+	// sampled in stats are sent without the sample rate
+	c.(*Client).SetSamplerFunc(func(float32) bool { return true })
+	c.Inc("count", 1, 0.5)
+	c.Timing("timing", 5, 0.5, Tag{"tag1", "val1"})
+
+	expected := []string{"test.count:1|c", "test.timing:5|ms|#tag1:val1"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestTimingDurationIn(t *testing.T) {
+	tests := []struct {
+		Unit     time.Duration
+		Expected string
+	}{
+		{time.Second, "test.timing:0.0015|ms"},
+		{time.Millisecond, "test.timing:1.5|ms"},
+		{time.Microsecond, "test.timing:1500|ms"},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		// TimingUnit does not apply
+		c, err := newClientWithConfig(rs, &ClientConfig{Prefix: "test", TimingUnit: time.Nanosecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.(*Client).TimingDurationIn("timing", 1500*time.Microsecond, tt.Unit, 1.0); err != nil {
+			t.Fatal(err)
+		}
+		if got := rs.sent(); len(got) != 1 || got[0] != tt.Expected {
+			t.Errorf("unit %s: got %q expected %q", tt.Unit, got, tt.Expected)
+		}
+	}
+
+	c, err := NewClientWithSender(&recordingSender{}, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(*Client).TimingDurationIn("timing", time.Second, 0, 1.0); err != errInvalidTimingUnit {
+		t.Fatalf("expected errInvalidTimingUnit, got %v", err)
+	}
+}
+
+func TestSampleEvery(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:      "test",
+		SampleEvery: 1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	var rates []float32
+	client.SetSamplerFunc(func(rate float32) bool {
+		rates = append(rates, rate)
+		return true
+	})
+
+	// unsampled stats get the configured rate, others keep their own
+	client.Inc("count", 1, 1.0)
+	client.Inc("count", 1, 0.001)
+	client.Inc("count", 1, SampleEvery(1000))
+	client.Timing("timing", 5, SampleEvery(10))
+	client.NewSubStatter("sub").Inc("count", 1, 1.0)
+	// gauges and sets are not sampled
+	client.Gauge("gauge", 1, 1.0)
+	client.GaugeBool("up", true, 1.0)
+	client.Set("set", "a", 1.0)
+
+	expected := []string{
+		"test.count:1|c|@0.001000",
+		"test.count:1|c|@0.001000",
+		"test.count:1|c|@0.001000",
+		"test.timing:5|ms|@0.100000",
+		"test.sub.count:1|c|@0.001000",
+		"test.gauge:1|g",
+		"test.up:1|g",
+		"test.set:a|s",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	// the sampler sees the translated rate
+	if rates[0] != 0.001 || rates[3] != 0.1 {
+		t.Fatalf("unexpected sampler rates %v", rates)
+	}
+
+	for n, expected := range map[int]float32{-1: 1, 0: 1, 1: 1, 2: 0.5, 4: 0.25} {
+		if got := SampleEvery(n); got != expected {
+			t.Errorf("SampleEvery(%d): got %v expected %v", n, got, expected)
+		}
+	}
+}
+
+func TestFloatFormatting(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// floats are never in scientific notation, which not all servers parse
+	client.GaugeFloat("gauge", 1000000.0, 1.0)
+	client.GaugeFloat("gauge", 1e21, 1.0)
+	client.GaugeFloatDelta("gauge", -0.0000015, 1.0)
+	client.SetFloat("set", 2.5e6, 1.0)
+	client.Histogram("histogram", 1e7, 1.0)
+	client.HistogramBuckets("buckets", []float64{1e6}, []int64{1}, BucketGauges, 1.0)
+
+	expected := []string{
+		"test.gauge:1000000|g",
+		"test.gauge:1000000000000000000000|g",
+		"test.gauge:-0.0000015|g",
+		"test.set:2500000|s",
+		"test.histogram:10000000|h",
+		"test.buckets.bucket:1|g|#le:1000000",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestNegativeZero(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	negZero := math.Copysign(0, -1)
+	client.GaugeFloatDelta("gauge", negZero, 1.0)
+	client.GaugeFloat("gauge", negZero, 1.0)
+
+	// "-0" would be read as a delta, so always format as zero
+	expected := []string{"test.gauge:+0|g", "test.gauge:0|g"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestNameTransform(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix: "My App",
+		NameTransform: func(stat string) string {
+			return strings.ReplaceAll(strings.ToLower(stat), " ", "_")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Inc("Request Count", 1, 1.0, Tag{"Tag Key", "Tag Value"})
+	c.NewSubStatter("Sub Stats").Gauge("Queue Depth", 1, 1.0)
+	c.Timing("Absolute Timing", 5, 1.0, Absolute())
+
+	// the prefix and tags are left as-is
+	expected := []string{
+		"My App.request_count:1|c|#Tag Key:Tag Value",
+		"My App.Sub Stats.queue_depth:1|g",
+		"absolute_timing:5|ms",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestSampledTypesRate(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", SuffixOctothorpe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	client.SetSamplerFunc(func(float32) bool { return true })
+
+	// every type carries the sample rate when sampled, ahead of the tags
+	tag := Tag{"tag1", "val1"}
+	client.Inc("count", 1, 0.5, tag)
+	client.Gauge("gauge", 1, 0.5, tag)
+	client.GaugeFloat("fgauge", 1.5, 0.5, tag)
+	client.Timing("timing", 5, 0.5, tag)
+	client.TimingDuration("duration", 5*time.Millisecond, 0.5, tag)
+	client.Histogram("histogram", 2, 0.5, tag)
+	client.Distribution("distribution", 2, 0.5, tag)
+	client.Set("set", "member", 0.5, tag)
+	client.SetInt("iset", 3, 0.5, tag)
+
+	expected := []string{
+		"test.count:1|c|@0.500000|#tag1:val1",
+		"test.gauge:1|g|@0.500000|#tag1:val1",
+		"test.fgauge:1.5|g|@0.500000|#tag1:val1",
+		"test.timing:5|ms|@0.500000|#tag1:val1",
+		"test.duration:5|ms|@0.500000|#tag1:val1",
+		"test.histogram:2|h|@0.500000|#tag1:val1",
+		"test.distribution:2|d|@0.500000|#tag1:val1",
+		"test.set:member|s|@0.500000|#tag1:val1",
+		"test.iset:3|s|@0.500000|#tag1:val1",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestSampleRateBounds(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// the default sampler always sends rates of 1 or more, and never 0 or
+	// less, and only rates below 1 carry a suffix
+	for _, rate := range []float32{1.0, 2.0, 0, -1} {
+		client.Inc("count", 1, rate)
+	}
+	expected := []string{
+		"test.count:1|c",
+		"test.count:1|c",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	// a custom sampler is consulted for every rate
+	var rates []float32
+	client.SetSamplerFunc(func(rate float32) bool {
+		rates = append(rates, rate)
+		return rate < 1
+	})
+	for _, rate := range []float32{1.0, 2.0, 0.999999, 0} {
+		client.Inc("count", 1, rate)
+	}
+	expected = append(expected, "test.count:1|c|@0.999999", "test.count:1|c|@0.000000")
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if !reflect.DeepEqual(rates, []float32{1.0, 2.0, 0.999999, 0}) {
+		t.Fatalf("unexpected sampler rates %v", rates)
+	}
+}
+
+func TestCountOutcome(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	if err := client.CountOutcome("ops", nil, 1.0, Tag{"tag1", "val1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CountOutcome("ops", errors.New("failed"), 1.0, Tag{"tag1", "val1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// sampled out outcomes send neither count
+	client.SetSamplerFunc(func(float32) bool { return false })
+	client.CountOutcome("ops", errors.New("failed"), 0.5)
+
+	expected := []string{
+		"test.ops.total:1|c|#tag1:val1",
+		"test.ops.total:1|c|#tag1:val1",
+		"test.ops.errors:1|c|#tag1:val1",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	sub := client.NewSubStatter("sub").(*Client)
+
+	if !client.Enabled() {
+		t.Fatal("expected a new client to be enabled")
+	}
+	client.Inc("count", 1, 1.0)
+
+	client.SetEnabled(false)
+	if client.Enabled() || sub.Enabled() {
+		t.Fatal("expected the client and substatter to be disabled")
+	}
+	client.Inc("count", 1, 1.0)
+	client.Gauge("gauge", 1, 1.0)
+	sub.Timing("timing", 1, 1.0)
+
+	// re-enabled via the substatter, as the setting is shared
+	sub.SetEnabled(true)
+	client.Inc("count", 2, 1.0)
+
+	expected := []string{"test.count:1|c", "test.count:2|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	// toggling while submitting is safe
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				client.Inc("count", 1, 1.0)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		client.SetEnabled(i%2 == 0)
+	}
+	wg.Wait()
+
+	var nilClient *Client
+	nilClient.SetEnabled(true)
+	if nilClient.Enabled() {
+		t.Fatal("expected a nil client not to be enabled")
+	}
+}
+
+func TestMinRate(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:      "test",
+		SampleEvery: 100,
+		MinRate:     map[string]float32{"errors": 1.0, "retries": 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// a floor of 1 is always sent, despite the global rate
+	for i := 0; i < 1000; i++ {
+		client.Inc("errors", 1, 1.0)
+		client.Inc("errors", 1, 0.01)
+	}
+	if n := len(rs.sent()); n != 2000 {
+		t.Fatalf("expected all 2000 errors sent, got %d", n)
+	}
+	for _, stat := range rs.sent() {
+		if stat != "test.errors:1|c" {
+			t.Fatalf("unexpected stat %q", stat)
+		}
+	}
+
+	// the effective rate is submitted
+	rs = &recordingSender{}
+	client.sender = rs
+	client.SetSamplerFunc(func(float32) bool { return true })
+	client.Inc("retries", 1, 1.0)
+	client.Inc("retries", 1, 0.01)
+	client.Inc("retries", 1, 0.75)
+	client.Inc("other", 1, 1.0)
+	client.NewSubStatter("sub").Inc("retries", 1, 0.01)
+
+	expected := []string{
+		"test.retries:1|c|@0.500000",
+		"test.retries:1|c|@0.500000",
+		"test.retries:1|c|@0.750000",
+		"test.other:1|c|@0.010000",
+		"test.sub.retries:1|c|@0.500000",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestTimeResult(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	if err := client.TimeResult("op", 1.0, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	opErr := errors.New("op failed")
+	tags := make([]Tag, 1, 2)
+	tags[0] = Tag{"tag1", "val1"}
+	if err := client.TimeResult("op", 1.0, func() error { return opErr }, tags...); err != opErr {
+		t.Fatalf("expected the fn error to propagate, got %v", err)
+	}
+	if extra := tags[:2][1]; extra != (Tag{}) {
+		t.Fatalf("caller tags were appended to: %v", extra)
+	}
+
+	sent := rs.sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 timings, got %q", sent)
+	}
+	if !strings.HasPrefix(sent[0], "test.op:") || !strings.HasSuffix(sent[0], "|ms|#outcome:success") {
+		t.Errorf("unexpected success timing %q", sent[0])
+	}
+	if !strings.HasPrefix(sent[1], "test.op:") || !strings.HasSuffix(sent[1], "|ms|#tag1:val1,outcome:error") {
+		t.Errorf("unexpected error timing %q", sent[1])
+	}
+
+	// fn is run, and its error returned, even for a nil client
+	var nc *Client
+	ran := false
+	if err := nc.TimeResult("op", 1.0, func() error { ran = true; return opErr }); err != opErr || !ran {
+		t.Fatal("nil client should run fn and return its error")
+	}
+}
+
+func TestGaugeBool(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	client.GaugeBool("flag", true, 1.0)
+	client.GaugeBool("flag", false, 1.0, Tag{"tag1", "val1"})
+
+	// sampled out gauges are not sent
+	client.SetSamplerFunc(func(float32) bool { return false })
+	client.GaugeBool("flag", true, 0.5)
+
+	expected := []string{"test.flag:1|g", "test.flag:0|g|#tag1:val1"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestSetMulti(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	err = client.SetMulti("codes", []string{"404", "500", "404", "503", "500"}, 1.0, Tag{"tag1", "val1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sampled out sets send nothing
+	client.SetSamplerFunc(func(float32) bool { return false })
+	client.SetMulti("codes", []string{"404", "500"}, 0.5)
+
+	expected := []string{
+		"test.codes:404|s|#tag1:val1",
+		"test.codes:500|s|#tag1:val1",
+		"test.codes:503|s|#tag1:val1",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestGaugeDiff(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	client.GaugeDiff("free", 100, 30, 1.0)
+	client.GaugeDiff("free", 30, 100, 1.0, Tag{"tag1", "val1"})
+	client.GaugeDiff("free", 30, 30, 1.0)
+
+	// negative differences are reset to 0 first, so are not deltas
+	expected := []string{
+		"test.free:70|g",
+		"test.free:0|g|#tag1:val1",
+		"test.free:-70|g|#tag1:val1",
+		"test.free:0|g",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestTimingSince(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-50 * time.Millisecond)
+	if err := c.(*Client).TimingSince("op", start, 1.0, Tag{"tag1", "val1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := rs.sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 timing, got %q", sent)
+	}
+	v := strings.TrimPrefix(sent[0], "test.op:")
+	v = strings.TrimSuffix(v, "|ms|#tag1:val1")
+	ms, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		t.Fatalf("unexpected timing %q", sent[0])
+	}
+	if ms < 50 || ms > 1000 {
+		t.Fatalf("timing %vms outside tolerance of 50ms", ms)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errNoAddress        = errors.New("address cannot be empty")
+	errInvalidTagFormat = errors.New("invalid tag format")
+	errNegativeFlush    = errors.New("flush interval and bytes cannot be negative")
+	errNegativeResolve  = errors.New("resolve interval cannot be negative")
+)
+
+// A ConfigBuilder builds a ClientConfig step by step, as an alternative to
+// filling in the struct directly. Unlike the struct, where a zero value may
+// mean either "use the default" or "disabled", the built config has every
+// default filled in explicitly, and is validated. eg.
+//
+//	client, err := statsd.NewConfigBuilder("127.0.0.1:8125").
+//		WithPrefix("myapp").
+//		WithTagFormat(statsd.InfixComma).
+//		Buffered(time.Second, 512).
+//		Build()
+type ConfigBuilder struct {
+	config ClientConfig
+}
+
+// NewConfigBuilder returns a ConfigBuilder for a client sending to address.
+func NewConfigBuilder(address string) *ConfigBuilder {
+	return &ConfigBuilder{config: ClientConfig{Address: address}}
+}
+
+// WithPrefix sets the prefix of every stat (see ClientConfig.Prefix).
+func (b *ConfigBuilder) WithPrefix(prefix string) *ConfigBuilder {
+	b.config.Prefix = prefix
+	return b
+}
+
+// WithTagFormat sets the tag format (see ClientConfig.TagFormat). Defaults to
+// SuffixOctothorpe.
+func (b *ConfigBuilder) WithTagFormat(tagFormat TagFormat) *ConfigBuilder {
+	b.config.TagFormat = tagFormat
+	return b
+}
+
+// WithTags adds tags to every stat (see ClientConfig.Tags).
+func (b *ConfigBuilder) WithTags(tags ...Tag) *ConfigBuilder {
+	b.config.Tags = append(b.config.Tags, tags...)
+	return b
+}
+
+// WithNetwork sets the network used to reach the server (see
+// ClientConfig.Network). Defaults to "udp".
+func (b *ConfigBuilder) WithNetwork(network string) *ConfigBuilder {
+	b.config.Network = network
+	return b
+}
+
+// WithResolveInterval re-resolves the address every interval (see
+// ClientConfig.ResInterval). Defaults to 0, never re-resolved.
+func (b *ConfigBuilder) WithResolveInterval(interval time.Duration) *ConfigBuilder {
+	b.config.ResInterval = interval
+	return b
+}
+
+// Buffered buffers stats, and sends them once every flushInterval, or once
+// flushBytes have been buffered, whichever comes first (see
+// ClientConfig.UseBuffered). A flushInterval or flushBytes of 0 uses the
+// default (300ms and 1432 bytes respectively).
+func (b *ConfigBuilder) Buffered(flushInterval time.Duration, flushBytes int) *ConfigBuilder {
+	b.config.UseBuffered = true
+	b.config.FlushInterval = flushInterval
+	b.config.FlushBytes = flushBytes
+	return b
+}
+
+// Config returns the built ClientConfig, with all defaults filled in, or an
+// error if it is invalid.
+func (b *ConfigBuilder) Config() (*ClientConfig, error) {
+	config := b.config
+	config.Tags = append([]Tag(nil), b.config.Tags...)
+
+	if config.Address == "" {
+		return nil, errNoAddress
+	}
+
+	if config.Network == "" {
+		config.Network = "udp"
+	}
+
+	if config.TagFormat == 0 {
+		config.TagFormat = SuffixOctothorpe
+	}
+	if config.TagFormat&(AllInfix|AllSuffix) == 0 {
+		return nil, errInvalidTagFormat
+	}
+
+	if config.ResInterval < 0 {
+		return nil, errNegativeResolve
+	}
+
+	if config.UseBuffered {
+		if config.FlushInterval < 0 || config.FlushBytes < 0 {
+			return nil, errNegativeFlush
+		}
+		if config.FlushInterval == 0 {
+			config.FlushInterval = defaultFlushInterval
+		}
+		if config.FlushBytes == 0 {
+			config.FlushBytes = defaultFlushBytes
+		}
+	}
+	return &config, nil
+}
+
+// Build returns a new client for the built config, as NewClientWithConfig.
+func (b *ConfigBuilder) Build() (Statter, error) {
+	config, err := b.Config()
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithConfig(config)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfigBuilder(t *testing.T) {
+	// the same stats, from a struct configured and a builder configured client
+	var received [][]byte
+	for _, build := range []func(addr string) (Statter, error){
+		func(addr string) (Statter, error) {
+			return NewClientWithConfig(&ClientConfig{
+				Address:       addr,
+				Prefix:        "test",
+				TagFormat:     InfixComma,
+				Tags:          []Tag{{"env", "prod"}},
+				UseBuffered:   true,
+				FlushInterval: time.Hour,
+			})
+		},
+		func(addr string) (Statter, error) {
+			return NewConfigBuilder(addr).
+				WithPrefix("test").
+				WithTagFormat(InfixComma).
+				WithTags(Tag{"env", "prod"}).
+				Buffered(time.Hour, 0).
+				Build()
+		},
+	} {
+		l, err := newUDPListener("127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		c, err := build(l.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Inc("count", 1, 1.0)
+		c.Gauge("gauge", 2, 1.0, Tag{"tag1", "val1"})
+		c.Close()
+
+		data := make([]byte, 1024)
+		n, _, err := l.ReadFrom(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, data[:n])
+	}
+
+	expected := []byte("test.count,env=prod:1|c\ntest.gauge,env=prod,tag1=val1:2|g")
+	for i, data := range received {
+		if !bytes.Equal(data, expected) {
+			t.Errorf("client %d: got %q expected %q", i, data, expected)
+		}
+	}
+}
+
+func TestConfigBuilderDefaults(t *testing.T) {
+	config, err := NewConfigBuilder("127.0.0.1:8125").Buffered(0, 0).Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &ClientConfig{
+		Address:       "127.0.0.1:8125",
+		Network:       "udp",
+		TagFormat:     SuffixOctothorpe,
+		UseBuffered:   true,
+		FlushInterval: 300 * time.Millisecond,
+		FlushBytes:    1432,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("got %+v expected %+v", config, expected)
+	}
+}
+
+func TestConfigBuilderInvalid(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Builder *ConfigBuilder
+		Err     error
+	}{
+		{"no address", NewConfigBuilder(""), errNoAddress},
+		{"tag format", NewConfigBuilder("127.0.0.1:8125").WithTagFormat(TagFormat(1 << 7)), errInvalidTagFormat},
+		{"flush bytes", NewConfigBuilder("127.0.0.1:8125").Buffered(0, -1), errNegativeFlush},
+		{"flush interval", NewConfigBuilder("127.0.0.1:8125").Buffered(-time.Second, 0), errNegativeFlush},
+		{"resolve interval", NewConfigBuilder("127.0.0.1:8125").WithResolveInterval(-time.Second), errNegativeResolve},
+	}
+
+	for _, tt := range tests {
+		if _, err := tt.Builder.Build(); err != tt.Err {
+			t.Errorf("%s: got err %v expected %v", tt.Name, err, tt.Err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "sync"
+
+// DeltaReset controls what a DeltaGauge emits when the tracked value goes
+// backwards, as happens when an external counter is reset.
+type DeltaReset uint8
+
+const (
+	// DeltaResetZero emits 0 when the tracked value goes backwards.
+	DeltaResetZero DeltaReset = iota
+	// DeltaResetRaw emits the new value as-is when the tracked value goes
+	// backwards, treating it as the count since the reset.
+	DeltaResetRaw
+)
+
+// A DeltaGauge tracks a monotonically increasing value, such as a counter
+// from an external system, and reports the change between updates as a
+// gauge.
+type DeltaGauge struct {
+	// OnReset controls the value emitted when a counter reset is detected.
+	// Default is DeltaResetZero.
+	OnReset DeltaReset
+
+	client *Client
+	stat   string
+	mx     sync.Mutex
+	last   int64
+	primed bool
+}
+
+// NewDeltaGauge returns a DeltaGauge that submits to stat.
+func (s *Client) NewDeltaGauge(stat string) *DeltaGauge {
+	return &DeltaGauge{
+		client: s,
+		stat:   stat,
+	}
+}
+
+// Update records the current value, and submits the difference from the
+// previously recorded value as a gauge. The first call only records the
+// value, as there is nothing to compare against yet.
+// value is the current integer value.
+// rate is the sample rate (0.0 to 1.0).
+func (d *DeltaGauge) Update(value int64, rate float32, tags ...Tag) error {
+	d.mx.Lock()
+	last, primed := d.last, d.primed
+	d.last, d.primed = value, true
+	d.mx.Unlock()
+
+	if !primed {
+		return nil
+	}
+
+	delta := value - last
+	if delta < 0 {
+		switch d.OnReset {
+		case DeltaResetRaw:
+			delta = value
+		default:
+			delta = 0
+		}
+	}
+
+	return d.client.Gauge(d.stat, delta, rate, tags...)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeltaGauge(t *testing.T) {
+	tests := []struct {
+		OnReset  DeltaReset
+		Expected []string
+	}{
+		{DeltaResetZero, []string{
+			"test.delta:5|g", "test.delta:0|g", "test.delta:15|g",
+			"test.delta:0|g", "test.delta:7|g",
+		}},
+		{DeltaResetRaw, []string{
+			"test.delta:5|g", "test.delta:0|g", "test.delta:15|g",
+			"test.delta:3|g", "test.delta:7|g",
+		}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, "test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := c.(*Client).NewDeltaGauge("delta")
+		d.OnReset = tt.OnReset
+		// first update only records; last update follows a reset
+		for _, v := range []int64{10, 15, 15, 30, 3, 10} {
+			if err := d.Update(v, 1.0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Fatalf("OnReset %d: got %q expected %q", tt.OnReset, got, tt.Expected)
+		}
+	}
+}
+
+func TestDeltaGaugeNilClient(t *testing.T) {
+	var c *Client
+	d := c.NewDeltaGauge("delta")
+	d.Update(1, 1.0)
+	if err := d.Update(2, 1.0); err != nil {
+		t.Fatal(err)
+	}
+}
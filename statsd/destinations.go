@@ -0,0 +1,68 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// A Destination is a server to submit stats to, with the tag format that
+// server expects (see ClientConfig.Destinations).
+type Destination struct {
+	// Address is the server address, as for ClientConfig.Address.
+	Address string
+	// TagFormat is the tag format stats are submitted to this server in.
+	TagFormat TagFormat
+}
+
+// mirror is an additional destination of a Client, sent every stat in its
+// own tag format.
+type mirror struct {
+	sender    Sender
+	tagFormat TagFormat
+}
+
+// newDestinationsClient returns a Client submitting each stat to every one of
+// config.Destinations. The first destination is the primary one (its sender
+// and tag format are the client's own); the rest are mirrors.
+func newDestinationsClient(config *ClientConfig) (Statter, error) {
+	senders := make([]Sender, 0, len(config.Destinations))
+	counters := &clientCounters{}
+	closeAll := func() {
+		for _, sender := range senders {
+			sender.Close()
+		}
+	}
+
+	for _, d := range config.Destinations {
+		dconfig := *config
+		dconfig.Address = d.Address
+		dconfig.Sender = nil
+		dconfig.Addresses = nil
+		dconfig.Destinations = nil
+		dconfig.TypeRoutes = nil
+		sender, err := newConfigSender(&dconfig, counters)
+		if err != nil {
+			if !config.RetryInitialDial {
+				closeAll()
+				return nil, err
+			}
+			// degrade to dropping this destination's stats until its
+			// sender can be created
+			sender = newRetryingConfigSender(&dconfig, counters)
+		}
+		senders = append(senders, sender)
+	}
+
+	pconfig := *config
+	pconfig.TagFormat = config.Destinations[0].TagFormat
+	c, err := newCountedClient(senders[0], &pconfig, counters)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	client := c.(*Client)
+	for i, d := range config.Destinations[1:] {
+		client.mirrors = append(client.mirrors, mirror{senders[i+1], d.TagFormat})
+	}
+	return client, nil
+}
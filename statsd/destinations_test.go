@@ -0,0 +1,161 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDestinations(t *testing.T) {
+	graphite, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer graphite.Close()
+	datadog, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datadog.Close()
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Prefix: "test",
+		Destinations: []Destination{
+			{graphite.LocalAddr().String(), InfixSemicolon},
+			{datadog.LocalAddr().String(), SuffixOctothorpe},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got := c.(*Client).TagFormat(); got != InfixSemicolon {
+		t.Fatalf("expected the primary tag format, got %d", got)
+	}
+
+	if err := c.Inc("count", 1, 1.0, Tag{"tag1", "val1"}, WithUnit("requests")); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 128)
+	n, _, err := graphite.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "test.count;tag1=val1:1|c"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Errorf("graphite got '%s' expected '%s'", data[:n], expected)
+	}
+
+	n, _, err = datadog.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "test.count:1|c|#tag1:val1,unit:requests"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Errorf("datadog got '%s' expected '%s'", data[:n], expected)
+	}
+}
+
+func TestDestinationsIgnoreSender(t *testing.T) {
+	l, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	shared := &recordingSender{}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Prefix:    "test",
+		Sender:    shared,
+		Addresses: []string{"127.0.0.1:1", "127.0.0.1:2"},
+		Destinations: []Destination{
+			{l.LocalAddr().String(), SuffixOctothorpe},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 128)
+	n, _, err := l.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "test.count:1|c"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Errorf("got '%s' expected '%s'", data[:n], expected)
+	}
+	if sent := shared.sent(); len(sent) != 0 {
+		t.Errorf("expected nothing sent to the shared sender, got %q", sent)
+	}
+}
+
+func TestDestinationsRetryInitialDial(t *testing.T) {
+	addr := unusedTCPAddr(t)
+	mirror, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mirror.Close()
+	mirrorReceived := readStream(mirror)
+
+	config := &ClientConfig{
+		Network: "tcp",
+		Prefix:  "test",
+		Destinations: []Destination{
+			{addr, SuffixOctothorpe},
+			{mirror.Addr().String(), InfixComma},
+		},
+	}
+	if _, err := NewClientWithConfig(config); err == nil {
+		t.Fatal("expected an error without RetryInitialDial")
+	}
+
+	// the unreachable destination is retried in the background
+	config.RetryInitialDial = true
+	config.RetryInterval = 10 * time.Millisecond
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatalf("expected a degraded client, got error: %s", err)
+	}
+	defer c.Close()
+
+	primary, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	primaryReceived := readStream(primary)
+
+	deadline := time.Now().Add(time.Second)
+	for c.(*Client).Ping() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("destination did not upgrade once the server was reachable")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.Inc("count", 1, 1.0, Tag{"tag1", "val1"})
+	c.Close()
+
+	expected := []byte("test.count:1|c|#tag1:val1\n")
+	if data := <-primaryReceived; !bytes.Equal(data, expected) {
+		t.Errorf("primary got %q expected %q", data, expected)
+	}
+	expected = []byte("test.count,tag1=val1:1|c\n")
+	if data := <-mirrorReceived; !bytes.Equal(data, expected) {
+		t.Errorf("mirror got %q expected %q", data, expected)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "errors"
+
+var errDuplicateTag = errors.New("duplicate tag key")
+
+// DuplicateTagPolicy controls how tags with the same key are submitted, eg.
+// when a per-call tag has the same key as a default tag (see
+// ClientConfig.DuplicateTags).
+type DuplicateTagPolicy uint8
+
+const (
+	// DuplicateTagKeepBoth submits every tag, whatever its key. This is the
+	// default.
+	DuplicateTagKeepBoth DuplicateTagPolicy = iota
+	// DuplicateTagLastWins submits only the last tag with each key. Per-call
+	// tags come after default and context tags, so override them.
+	DuplicateTagLastWins
+	// DuplicateTagFirstWins submits only the first tag with each key.
+	// Default and context tags come before per-call tags, so can not be
+	// overridden.
+	DuplicateTagFirstWins
+	// DuplicateTagError submits nothing, and returns an error, if more than
+	// one tag has the same key.
+	DuplicateTagError
+)
+
+// dedupTags appends the tags to keep under policy to dst, returning the
+// result, or an error for DuplicateTagError. If no keys are duplicated, tags
+// is returned as-is.
+func dedupTags(dst []Tag, tags []Tag, policy DuplicateTagPolicy) ([]Tag, error) {
+	if !hasDuplicateKeys(tags) {
+		return tags, nil
+	}
+
+	switch policy {
+	case DuplicateTagLastWins:
+		for i, t := range tags {
+			if indexTagKey(tags[i+1:], t[0]) == -1 {
+				dst = append(dst, t)
+			}
+		}
+	case DuplicateTagFirstWins:
+		for i, t := range tags {
+			if indexTagKey(tags[:i], t[0]) == -1 {
+				dst = append(dst, t)
+			}
+		}
+	case DuplicateTagError:
+		return nil, errDuplicateTag
+	default:
+		return tags, nil
+	}
+	return dst, nil
+}
+
+// hasDuplicateKeys reports whether more than one of tags has the same key.
+func hasDuplicateKeys(tags []Tag) bool {
+	for i, t := range tags {
+		if indexTagKey(tags[:i], t[0]) != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// indexTagKey returns the index of the first of tags with key, or -1.
+func indexTagKey(tags []Tag, key string) int {
+	for i, t := range tags {
+		if t[0] == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateTags(t *testing.T) {
+	tests := []struct {
+		Policy   DuplicateTagPolicy
+		Err      error
+		Expected []string
+	}{
+		{DuplicateTagKeepBoth, nil, []string{
+			"test.count:1|c|#region:us,env:prod,region:eu",
+			"test.count:1|c|#region:us,env:prod,host:a",
+		}},
+		{DuplicateTagLastWins, nil, []string{
+			"test.count:1|c|#env:prod,region:eu",
+			"test.count:1|c|#region:us,env:prod,host:a",
+		}},
+		{DuplicateTagFirstWins, nil, []string{
+			"test.count:1|c|#region:us,env:prod",
+			"test.count:1|c|#region:us,env:prod,host:a",
+		}},
+		{DuplicateTagError, errDuplicateTag, []string{
+			"test.count:1|c|#region:us,env:prod,host:a",
+		}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			Prefix:        "test",
+			TagFormat:     SuffixOctothorpe,
+			Tags:          []Tag{{"region", "us"}, {"env", "prod"}},
+			DuplicateTags: tt.Policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Inc("count", 1, 1.0, Tag{"region", "eu"}); err != tt.Err {
+			t.Errorf("policy %d: got error %v expected %v", tt.Policy, err, tt.Err)
+		}
+		// no conflict
+		if err := c.Inc("count", 1, 1.0, Tag{"host", "a"}); err != nil {
+			t.Errorf("policy %d: %s", tt.Policy, err)
+		}
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("policy %d: got %q expected %q", tt.Policy, got, tt.Expected)
+		}
+	}
+}
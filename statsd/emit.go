@@ -0,0 +1,95 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"time"
+)
+
+var errUnsupportedMetric = errors.New("unsupported metric type or value")
+
+// Metric is a stat, for callers that choose the stat type at runtime (see
+// Client.Emit), or that process stats in-process (see NewChannelStatter).
+type Metric struct {
+	// Type is the stat type, one of TypeCount, TypeGauge, TypeTiming,
+	// TypeSet, TypeHistogram or TypeDistribution.
+	Type string
+	// Name is the stat name, without the client prefix.
+	Name string
+	// Value is the stat value. Counts take an int64, gauges an int64 or
+	// float64, timings an int64 (in milliseconds) or time.Duration, sets a
+	// string, []byte, int64 or float64, and histograms and distributions a
+	// float64 or int64.
+	Value interface{}
+	// Rate is the sample rate (0.0 to 1.0).
+	Rate float32
+	// Tags are the stat tags, if any.
+	Tags []Tag
+	// Delta marks a TypeGauge value as a change to the gauge, rather than
+	// its new value.
+	Delta bool
+}
+
+// Emit submits metric, with the Client method for its type and value (eg.
+// Inc for a TypeCount, or GaugeFloat for a TypeGauge with a float64 value).
+// It is a typed, reflection free, entry point for generic callers.
+// Unsupported combinations of type and value return an error.
+func (s *Client) Emit(metric Metric) error {
+	name, rate, tags := metric.Name, metric.Rate, metric.Tags
+
+	switch metric.Type {
+	case TypeCount:
+		if v, ok := metric.Value.(int64); ok {
+			return s.Inc(name, v, rate, tags...)
+		}
+	case TypeGauge:
+		switch v := metric.Value.(type) {
+		case int64:
+			if metric.Delta {
+				return s.GaugeDelta(name, v, rate, tags...)
+			}
+			return s.Gauge(name, v, rate, tags...)
+		case float64:
+			if metric.Delta {
+				return s.GaugeFloatDelta(name, v, rate, tags...)
+			}
+			return s.GaugeFloat(name, v, rate, tags...)
+		}
+	case TypeTiming:
+		switch v := metric.Value.(type) {
+		case int64:
+			return s.Timing(name, v, rate, tags...)
+		case time.Duration:
+			return s.TimingDuration(name, v, rate, tags...)
+		}
+	case TypeSet:
+		switch v := metric.Value.(type) {
+		case string:
+			return s.Set(name, v, rate, tags...)
+		case []byte:
+			return s.SetBytes(name, v, rate, tags...)
+		case int64:
+			return s.SetInt(name, v, rate, tags...)
+		case float64:
+			return s.SetFloat(name, v, rate, tags...)
+		}
+	case TypeHistogram:
+		switch v := metric.Value.(type) {
+		case float64:
+			return s.Histogram(name, v, rate, tags...)
+		case int64:
+			return s.Histogram(name, float64(v), rate, tags...)
+		}
+	case TypeDistribution:
+		switch v := metric.Value.(type) {
+		case float64:
+			return s.Distribution(name, v, rate, tags...)
+		case int64:
+			return s.Distribution(name, float64(v), rate, tags...)
+		}
+	}
+	return errUnsupportedMetric
+}
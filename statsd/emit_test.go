@@ -0,0 +1,73 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEmit(t *testing.T) {
+	tests := []struct {
+		Metric   Metric
+		Expected string
+	}{
+		{Metric{TypeCount, "count", int64(2), 1.0, nil, false}, "test.count:2|c"},
+		{Metric{TypeGauge, "gauge", int64(-2), 1.0, nil, false}, "test.gauge:-2|g"},
+		{Metric{TypeGauge, "gauge", 1.5, 1.0, nil, false}, "test.gauge:1.5|g"},
+		{Metric{TypeGauge, "gauge", int64(-2), 1.0, nil, true}, "test.gauge:-2|g"},
+		{Metric{TypeGauge, "gauge", int64(2), 1.0, nil, true}, "test.gauge:+2|g"},
+		{Metric{TypeGauge, "gauge", 1.5, 1.0, nil, true}, "test.gauge:+1.5|g"},
+		{Metric{TypeTiming, "timing", int64(5), 1.0, nil, false}, "test.timing:5|ms"},
+		{Metric{TypeTiming, "timing", 5 * time.Millisecond, 1.0, nil, false}, "test.timing:5|ms"},
+		{Metric{TypeSet, "set", "member", 1.0, nil, false}, "test.set:member|s"},
+		{Metric{TypeSet, "set", []byte("member"), 1.0, nil, false}, "test.set:member|s"},
+		{Metric{TypeSet, "set", int64(3), 1.0, nil, false}, "test.set:3|s"},
+		{Metric{TypeSet, "set", 3.5, 1.0, nil, false}, "test.set:3.5|s"},
+		{Metric{TypeHistogram, "histogram", 1.5, 1.0, nil, false}, "test.histogram:1.5|h"},
+		{Metric{TypeHistogram, "histogram", int64(2), 1.0, nil, false}, "test.histogram:2|h"},
+		{Metric{TypeDistribution, "distribution", 1.5, 1.0, nil, false}, "test.distribution:1.5|d"},
+		{Metric{TypeDistribution, "distribution", int64(2), 1.0, nil, false}, "test.distribution:2|d"},
+		{Metric{TypeCount, "count", int64(1), 1.0, []Tag{{"tag1", "val1"}}, false}, "test.count:1|c|#tag1:val1"},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, "test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.(*Client).Emit(tt.Metric); err != nil {
+			t.Fatalf("%+v: %s", tt.Metric, err)
+		}
+		if got := rs.sent(); !reflect.DeepEqual(got, []string{tt.Expected}) {
+			t.Fatalf("%+v: got %q expected %q", tt.Metric, got, tt.Expected)
+		}
+	}
+}
+
+func TestEmitUnsupported(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range []Metric{
+		{TypeCount, "count", 1.5, 1.0, nil, false},
+		{TypeCount, "count", 1, 1.0, nil, false},
+		{TypeTiming, "timing", "5", 1.0, nil, false},
+		{"x", "unknown", int64(1), 1.0, nil, false},
+	} {
+		if err := c.(*Client).Emit(m); err != errUnsupportedMetric {
+			t.Errorf("%+v: expected errUnsupportedMetric, got %v", m, err)
+		}
+	}
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected nothing sent, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// EmptyTagValues controls how tags with an empty value are submitted.
+type EmptyTagValues uint8
+
+const (
+	// EmptyTagKeep submits empty valued tags with the key and separator, but
+	// no value ("key:" for SuffixOctothorpe, "key=" for infix formats).
+	// This is the default.
+	EmptyTagKeep EmptyTagValues = iota
+	// EmptyTagDrop drops empty valued tags.
+	EmptyTagDrop
+	// EmptyTagKeyOnly submits just the key of empty valued tags, without a
+	// separator ("key"), for SuffixOctothorpe. Infix formats (graphite
+	// style) require every tag to have a value, so for those empty valued
+	// tags are dropped instead.
+	EmptyTagKeyOnly
+)
+
+// dropEmptyTags appends the tags with non-empty values to dst, returning the
+// result. If there are no empty valued tags, tags is returned as-is.
+func dropEmptyTags(dst []Tag, tags []Tag) []Tag {
+	empty := false
+	for _, t := range tags {
+		if t[1] == "" {
+			empty = true
+			break
+		}
+	}
+	if !empty {
+		return tags
+	}
+
+	for _, t := range tags {
+		if t[1] != "" {
+			dst = append(dst, t)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEmptyTagValues(t *testing.T) {
+	tests := []struct {
+		TagFormat      TagFormat
+		EmptyTagValues EmptyTagValues
+		Expected       string
+	}{
+		{SuffixOctothorpe, EmptyTagKeep, "test.count:1|c|#tag1:val1,empty:"},
+		{SuffixOctothorpe, EmptyTagDrop, "test.count:1|c|#tag1:val1"},
+		{SuffixOctothorpe, EmptyTagKeyOnly, "test.count:1|c|#tag1:val1,empty"},
+		{InfixComma, EmptyTagKeep, "test.count,tag1=val1,empty=:1|c"},
+		{InfixComma, EmptyTagDrop, "test.count,tag1=val1:1|c"},
+		{InfixComma, EmptyTagKeyOnly, "test.count,tag1=val1:1|c"},
+		{InfixSemicolon, EmptyTagKeyOnly, "test.count;tag1=val1:1|c"},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			Prefix:         "test",
+			TagFormat:      tt.TagFormat,
+			EmptyTagValues: tt.EmptyTagValues,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Inc("count", 1, 1.0, Tag{"tag1", "val1"}, Tag{"empty", ""})
+
+		expected := []string{tt.Expected}
+		if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("format %d, empty %d: got %q expected %q",
+				tt.TagFormat, tt.EmptyTagValues, got, expected)
+		}
+	}
+}
+
+func TestEmptyTagValuesAllDropped(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		EmptyTagValues: EmptyTagDrop,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Inc("count", 1, 1.0, Tag{"empty", ""})
+
+	expected := []string{"count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
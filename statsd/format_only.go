@@ -0,0 +1,147 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "time"
+
+// formatSender keeps a copy of the last data sent to it, for the Format
+// methods.
+type formatSender struct {
+	data []byte
+}
+
+func (f *formatSender) Send(data []byte) (int, error) {
+	f.data = append(f.data[:0], data...)
+	return len(data), nil
+}
+
+func (f *formatSender) Close() error {
+	return nil
+}
+
+// formatOnly returns the stat line submitted by fn, called with a copy of the
+// client that captures it instead of sending it. The copy never samples
+// stats out, and has no side effects on the client: stats are not mirrored,
+// aggregated, deduplicated, tracked or counted, nor tagged with trace IDs.
+func (s *Client) formatOnly(fn func(c *Client) error) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	fs := &formatSender{}
+	c := s.NewSubStatter("").(*Client)
+	c.sender = fs
+	c.sampler = func(float32) bool { return true }
+	c.primer = nil
+	c.disabled = nil
+	c.mirrors = nil
+	c.aggregator = nil
+	c.local = nil
+	c.gaugeDedup = nil
+	c.adjuster = nil
+	c.catalog = nil
+	c.tracer = nil
+	c.counters = &clientCounters{}
+
+	if err := fn(c); err != nil {
+		return nil, err
+	}
+	return fs.data, nil
+}
+
+// FormatInc returns the stat line Client.Inc would send, without sending it,
+// eg. for golden file tests of instrumentation. Sampling is bypassed, so the
+// line is always returned, with the sample rate if it is below 1. Any error
+// Client.Inc would return (eg. for a rejected monotonic counter delta) is
+// returned instead of the line.
+func (s *Client) FormatInc(stat string, value int64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Inc(stat, value, rate, tags...)
+	})
+}
+
+// FormatDec is FormatInc, for Client.Dec.
+func (s *Client) FormatDec(stat string, value int64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Dec(stat, value, rate, tags...)
+	})
+}
+
+// FormatGauge is FormatInc, for Client.Gauge.
+func (s *Client) FormatGauge(stat string, value int64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Gauge(stat, value, rate, tags...)
+	})
+}
+
+// FormatGaugeDelta is FormatInc, for Client.GaugeDelta.
+func (s *Client) FormatGaugeDelta(stat string, value int64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.GaugeDelta(stat, value, rate, tags...)
+	})
+}
+
+// FormatGaugeFloat is FormatInc, for Client.GaugeFloat.
+func (s *Client) FormatGaugeFloat(stat string, value float64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.GaugeFloat(stat, value, rate, tags...)
+	})
+}
+
+// FormatGaugeFloatDelta is FormatInc, for Client.GaugeFloatDelta.
+func (s *Client) FormatGaugeFloatDelta(stat string, value float64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.GaugeFloatDelta(stat, value, rate, tags...)
+	})
+}
+
+// FormatTiming is FormatInc, for Client.Timing.
+func (s *Client) FormatTiming(stat string, delta int64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Timing(stat, delta, rate, tags...)
+	})
+}
+
+// FormatTimingDuration is FormatInc, for Client.TimingDuration.
+func (s *Client) FormatTimingDuration(stat string, delta time.Duration, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.TimingDuration(stat, delta, rate, tags...)
+	})
+}
+
+// FormatHistogram is FormatInc, for Client.Histogram.
+func (s *Client) FormatHistogram(stat string, value float64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Histogram(stat, value, rate, tags...)
+	})
+}
+
+// FormatSet is FormatInc, for Client.Set.
+func (s *Client) FormatSet(stat string, value string, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Set(stat, value, rate, tags...)
+	})
+}
+
+// FormatSetInt is FormatInc, for Client.SetInt.
+func (s *Client) FormatSetInt(stat string, value int64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.SetInt(stat, value, rate, tags...)
+	})
+}
+
+// FormatSetFloat is FormatInc, for Client.SetFloat.
+func (s *Client) FormatSetFloat(stat string, value float64, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.SetFloat(stat, value, rate, tags...)
+	})
+}
+
+// FormatRaw is FormatInc, for Client.Raw.
+func (s *Client) FormatRaw(stat string, value string, rate float32, tags ...Tag) ([]byte, error) {
+	return s.formatOnly(func(c *Client) error {
+		return c.Raw(stat, value, rate, tags...)
+	})
+}
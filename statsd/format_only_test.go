@@ -0,0 +1,66 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatOnly(t *testing.T) {
+	for _, tt := range statsdPacketTests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, tt.Prefix, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// sampling is bypassed
+		c.(*Client).SetSamplerFunc(func(float32) bool { return false })
+
+		method := reflect.ValueOf(c).MethodByName("Format" + tt.Method)
+		out := method.Call([]reflect.Value{
+			reflect.ValueOf(tt.Stat),
+			reflect.ValueOf(tt.Value),
+			reflect.ValueOf(tt.Rate),
+		})
+		if err, _ := out[1].Interface().(error); err != nil {
+			t.Fatal(err)
+		}
+
+		if data := out[0].Bytes(); string(data) != tt.Expected {
+			t.Fatalf("Format%s got '%s' expected '%s'", tt.Method, data, tt.Expected)
+		}
+		if sent := rs.sent(); len(sent) != 0 {
+			t.Fatalf("Format%s sent %q", tt.Method, sent)
+		}
+	}
+}
+
+func TestFormatOnlyTags(t *testing.T) {
+	c, err := newClientWithConfig(&recordingSender{}, &ClientConfig{
+		Prefix:            "test",
+		Tags:              []Tag{{"env", "prod"}},
+		MonotonicCounters: []string{"total"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	data, err := client.FormatInc("count", 1, 1.0, Tag{"tag1", "val1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "test.count:1|c|#env:prod,tag1:val1"; string(data) != expected {
+		t.Fatalf("got '%s' expected '%s'", data, expected)
+	}
+
+	if _, err := client.FormatInc("total", -1, 1.0); err != errNegativeCount {
+		t.Fatalf("expected errNegativeCount, got %v", err)
+	}
+	if n := client.Stats().NegativeCounts; n != 0 {
+		t.Fatalf("expected formatting not to be counted, got %d", n)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultGaugeHeartbeat is the interval unchanged gauges are resent at, if
+// ClientConfig.ChangedGauges is set without a ChangedGaugeHeartbeat.
+const defaultGaugeHeartbeat = time.Minute
+
+// maxDedupGauges bounds the number of distinct gauge series a gaugeDeduper
+// will remember the last value of. Once reached, gauges for previously unseen
+// series are always sent.
+const maxDedupGauges = 10000
+
+// lastGauge is the last value sent for a gauge series, and when
+type lastGauge struct {
+	value interface{}
+	at    time.Time
+}
+
+// gaugeDeduper suppresses gauges that are resubmitted with an unchanged value
+// (see ClientConfig.ChangedGauges), other than a heartbeat to keep the series
+// alive.
+type gaugeDeduper struct {
+	names     map[string]struct{}
+	heartbeat time.Duration
+	now       func() time.Time
+
+	mx   sync.Mutex
+	last map[string]lastGauge
+}
+
+// newGaugeDeduper returns a gaugeDeduper for the named gauges, or nil if
+// there are none, so deduplication can be skipped entirely.
+func newGaugeDeduper(names []string, heartbeat time.Duration) *gaugeDeduper {
+	if len(names) == 0 {
+		return nil
+	}
+	if heartbeat <= 0 {
+		heartbeat = defaultGaugeHeartbeat
+	}
+
+	d := &gaugeDeduper{
+		names:     make(map[string]struct{}, len(names)),
+		heartbeat: heartbeat,
+		now:       time.Now,
+		last:      make(map[string]lastGauge),
+	}
+	for _, name := range names {
+		d.names[name] = struct{}{}
+	}
+	return d
+}
+
+// suppress reports whether a gauge should not be sent, as the same value was
+// sent for the series (name and tags) within the heartbeat interval.
+// Otherwise, the value is recorded as sent.
+func (d *gaugeDeduper) suppress(prefix, stat string, value interface{}, tags []Tag) bool {
+	if _, ok := d.names[stat]; !ok {
+		return false
+	}
+
+	key := adjustKey(prefix, stat, tags)
+	now := d.now()
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	last, ok := d.last[key]
+	if ok && last.value == value && now.Sub(last.at) < d.heartbeat {
+		return true
+	}
+	if ok || len(d.last) < maxDedupGauges {
+		d.last[key] = lastGauge{value, now}
+	}
+	return false
+}
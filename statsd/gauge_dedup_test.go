@@ -0,0 +1,76 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestChangedGauges(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:                "test",
+		ChangedGauges:         []string{"polled", "flag"},
+		ChangedGaugeHeartbeat: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	now := time.Unix(1656581400, 0)
+	client.gaugeDedup.now = func() time.Time { return now }
+
+	client.Gauge("polled", 5, 1.0)
+	client.Gauge("polled", 5, 1.0)
+	client.Gauge("polled", 5, 1.0, Tag{"tag1", "val1"}) // another series
+	client.Gauge("polled", 6, 1.0)
+	client.Gauge("polled", 6, 1.0)
+	client.GaugeBool("flag", true, 1.0)
+	client.GaugeBool("flag", true, 1.0)
+	// other gauges are always sent
+	client.Gauge("other", 1, 1.0)
+	client.Gauge("other", 1, 1.0)
+
+	// unchanged gauges are resent after the heartbeat
+	now = now.Add(time.Minute)
+	client.Gauge("polled", 6, 1.0)
+	client.Gauge("polled", 6, 1.0)
+
+	expected := []string{
+		"test.polled:5|g",
+		"test.polled:5|g|#tag1:val1",
+		"test.polled:6|g",
+		"test.flag:1|g",
+		"test.other:1|g",
+		"test.other:1|g",
+		"test.polled:6|g",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestChangedGaugesBounded(t *testing.T) {
+	d := newGaugeDeduper([]string{"polled"}, time.Minute)
+	for i := 0; i < maxDedupGauges+10; i++ {
+		d.suppress("test", "polled", int64(1), []Tag{{"id", strconv.Itoa(i)}})
+	}
+	if len(d.last) != maxDedupGauges {
+		t.Fatalf("expected %d remembered series, got %d", maxDedupGauges, len(d.last))
+	}
+
+	// unseen series past the bound are always sent
+	overflow := []Tag{{"id", strconv.Itoa(maxDedupGauges + 1)}}
+	if d.suppress("test", "polled", int64(1), overflow) {
+		t.Fatal("expected a gauge past the bound not to be suppressed")
+	}
+	if !d.suppress("test", "polled", int64(1), []Tag{{"id", "0"}}) {
+		t.Fatal("expected an unchanged remembered gauge to be suppressed")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// defaultGCStatsInterval is the period of a GCStats created with an interval
+// <= 0.
+const defaultGCStatsInterval = time.Second
+
+// GCStats periodically submits Go runtime garbage collection stats:
+//
+//	gc.count      counter, the number of GCs since the last period
+//	gc.pause.max  timing, the longest GC pause since the last period
+//	gc.pause.avg  timing, the average GC pause since the last period
+//
+// Each GC pause is only counted once. The pause timings are not submitted for
+// periods without any GCs. The runtime only keeps the most recent 256 pauses,
+// so if more GCs than that happen in a period, only the most recent 256 are
+// included in the pause timings (all are still included in gc.count).
+type GCStats struct {
+	client       *Client
+	count        string
+	pauseMax     string
+	pauseAvg     string
+	tags         []Tag
+	readMemStats func(*runtime.MemStats)
+
+	mx     sync.Mutex
+	ms     runtime.MemStats
+	lastGC uint32
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewGCStats returns a GCStats that submits GC stats under prefix, once every
+// interval (1 second if <= 0), until stopped. Only GCs after the GCStats is
+// created are counted.
+func (s *Client) NewGCStats(prefix string, interval time.Duration, tags ...Tag) *GCStats {
+	return newGCStats(s, prefix, interval, runtime.ReadMemStats, tags)
+}
+
+func newGCStats(s *Client, prefix string, interval time.Duration, readMemStats func(*runtime.MemStats), tags []Tag) *GCStats {
+	if interval <= 0 {
+		interval = defaultGCStatsInterval
+	}
+
+	g := &GCStats{
+		client:       s,
+		count:        joinPathComp(prefix, "gc.count"),
+		pauseMax:     joinPathComp(prefix, "gc.pause.max"),
+		pauseAvg:     joinPathComp(prefix, "gc.pause.avg"),
+		tags:         tags,
+		readMemStats: readMemStats,
+		done:         make(chan struct{}),
+	}
+
+	g.readMemStats(&g.ms)
+	g.lastGC = g.ms.NumGC
+
+	go g.run(interval)
+	return g
+}
+
+// Stop stops the periodic submission. It is safe to call more than once.
+func (g *GCStats) Stop() {
+	g.once.Do(func() {
+		close(g.done)
+	})
+}
+
+func (g *GCStats) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+			g.tick()
+		}
+	}
+}
+
+// tick reads the current mem stats, and submits the GC stats for the GCs
+// since the last tick.
+func (g *GCStats) tick() error {
+	g.mx.Lock()
+	g.readMemStats(&g.ms)
+	numGC := g.ms.NumGC - g.lastGC
+	g.lastGC = g.ms.NumGC
+
+	// PauseNs is a circular buffer, with the most recent pause at
+	// (NumGC+255)%256.
+	n := numGC
+	if n > uint32(len(g.ms.PauseNs)) {
+		n = uint32(len(g.ms.PauseNs))
+	}
+	var max, total uint64
+	for i := uint32(0); i < n; i++ {
+		pause := g.ms.PauseNs[(g.ms.NumGC-i+255)%uint32(len(g.ms.PauseNs))]
+		total += pause
+		if pause > max {
+			max = pause
+		}
+	}
+	g.mx.Unlock()
+
+	if err := g.client.Inc(g.count, int64(numGC), 1.0, g.tags...); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	if err := g.client.TimingDuration(g.pauseMax, time.Duration(max), 1.0, g.tags...); err != nil {
+		return err
+	}
+	return g.client.TimingDuration(g.pauseAvg, time.Duration(total/uint64(n)), 1.0, g.tags...)
+}
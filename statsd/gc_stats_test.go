@@ -0,0 +1,135 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeMemStats is a fake runtime.ReadMemStats, recording GC pauses in the
+// same circular buffer layout as the runtime.
+type fakeMemStats struct {
+	ms runtime.MemStats
+}
+
+func (f *fakeMemStats) gc(pause time.Duration) {
+	f.ms.PauseNs[f.ms.NumGC%256] = uint64(pause)
+	f.ms.NumGC++
+}
+
+func (f *fakeMemStats) read(ms *runtime.MemStats) {
+	*ms = f.ms
+}
+
+func TestGCStats(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeMemStats{}
+	// pauses before creation are not counted
+	fake.gc(50 * time.Millisecond)
+
+	// long interval, so ticks are driven by hand
+	g := newGCStats(c.(*Client), "runtime", time.Hour, fake.read, nil)
+	defer g.Stop()
+
+	fake.gc(2 * time.Millisecond)
+	fake.gc(4 * time.Millisecond)
+	g.tick()
+	g.tick() // no GCs, so no pause timings
+	fake.gc(1 * time.Millisecond)
+	g.tick()
+
+	expected := []string{
+		"test.runtime.gc.count:2|c",
+		"test.runtime.gc.pause.max:4|ms",
+		"test.runtime.gc.pause.avg:3|ms",
+		"test.runtime.gc.count:0|c",
+		"test.runtime.gc.count:1|c",
+		"test.runtime.gc.pause.max:1|ms",
+		"test.runtime.gc.pause.avg:1|ms",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestGCStatsWraparound(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeMemStats{}
+	g := newGCStats(c.(*Client), "", time.Hour, fake.read, nil)
+	defer g.Stop()
+
+	// more GCs than the runtime buffer holds. the oldest (and longest)
+	// pause is overwritten, so only the most recent 256 are included.
+	fake.gc(time.Second)
+	for i := 0; i < 256; i++ {
+		fake.gc(2 * time.Millisecond)
+	}
+	g.tick()
+
+	expected := []string{
+		"gc.count:257|c",
+		"gc.pause.max:2|ms",
+		"gc.pause.avg:2|ms",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestGCStatsRuntime(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := c.(*Client).NewGCStats("", time.Hour)
+	defer g.Stop()
+
+	runtime.GC()
+	g.tick()
+
+	sent := rs.sent()
+	if len(sent) != 3 || sent[0] == "gc.count:0|c" {
+		t.Fatalf("expected a GC to be reported, got %q", sent)
+	}
+}
+
+func TestGCStatsDefaultInterval(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an interval <= 0 is defaulted, rather than panicking
+	fake := &fakeMemStats{}
+	g := newGCStats(c.(*Client), "runtime", 0, fake.read, nil)
+	defer g.Stop()
+
+	deadline := time.Now().Add(2 * defaultGCStatsInterval)
+	for len(rs.sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the gc stats")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if sent := rs.sent(); sent[0] != "test.runtime.gc.count:0|c" {
+		t.Fatalf("unexpected stats sent: %q", sent)
+	}
+}
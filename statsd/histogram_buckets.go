@@ -0,0 +1,105 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"strconv"
+)
+
+var errBucketCounts = errors.New("bucket counts must match bucket bounds, plus an optional overflow bucket")
+
+// maxBucketRepeat is the most observations a single BucketValues submission
+// stands for, at a sample rate of 1. The rate is written with 6 decimal
+// places, so lower rates would be rounded too coarsely.
+const maxBucketRepeat = 1000
+
+// BucketMode controls how HistogramBuckets submits pre-bucketed data.
+type BucketMode uint8
+
+const (
+	// BucketGauges submits a "stat.bucket" gauge per bucket, tagged with the
+	// bucket upper bound (le:<upper>, or le:+Inf for the overflow bucket).
+	// As for Prometheus histograms, each gauge holds the cumulative count of
+	// observations less than or equal to its upper bound.
+	BucketGauges BucketMode = iota
+	// BucketValues submits each bucket upper bound as a histogram value,
+	// with a sample rate of 1/count, so that the server counts it once per
+	// observation in the bucket and can aggregate them as usual. The overflow
+	// bucket has no upper bound, so its observations are submitted as the
+	// highest bound. Large counts are split over several submissions, so the
+	// sample rate keeps its precision on the wire (at most 1000 observations
+	// each). With ClientConfig.OmitSampleRate, the bound is
+	// submitted once per observation.
+	BucketValues
+)
+
+// HistogramBuckets submits pre-bucketed histogram data, without having to
+// submit every raw observation.
+// stat is a string name for the metric.
+// bounds are the bucket upper bounds, in increasing order.
+// counts are the number of observations in each bucket, ie. counts[i] is the
+// number of observations greater than bounds[i-1] and less than or equal to
+// bounds[i]. There may be one more count than bounds, for the observations
+// greater than the highest bound.
+// mode controls how the buckets are submitted.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) HistogramBuckets(stat string, bounds []float64, counts []int64, mode BucketMode, rate float32, tags ...Tag) error {
+	if len(counts) != len(bounds) && len(counts) != len(bounds)+1 {
+		return errBucketCounts
+	}
+	if len(bounds) == 0 {
+		return nil
+	}
+
+	rate, ok := s.includeStat(stat, rate)
+	if !ok {
+		return s.excluded(false)
+	}
+
+	if mode == BucketValues {
+		suffix := s.typeSuffix(TypeHistogram, "|h")
+		// observations per submission, as many as the sample rate precision
+		// allows
+		repeat := int64(rate * maxBucketRepeat)
+		if repeat < 1 || s.omitSampleRate {
+			repeat = 1
+		}
+		for i, count := range counts {
+			value := bounds[len(bounds)-1]
+			if i < len(bounds) {
+				value = bounds[i]
+			}
+			for count > 0 {
+				n := count
+				if n > repeat {
+					n = repeat
+				}
+				if err := s.submit(stat, "", value, suffix, rate/float32(n), tags); err != nil {
+					return err
+				}
+				count -= n
+			}
+		}
+		return nil
+	}
+
+	bucket := joinPathComp(stat, "bucket")
+	// le tag last, after the caller's tags
+	btags := append(tags[:len(tags):len(tags)], Tag{"le", ""})
+	var cumulative int64
+	for i, count := range counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(bounds) {
+			le = strconv.FormatFloat(bounds[i], 'f', -1, 64)
+		}
+		btags[len(btags)-1][1] = le
+		if err := s.submit(bucket, "", cumulative, "|g", rate, btags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	tests := []struct {
+		Mode     BucketMode
+		Counts   []int64
+		Expected []string
+	}{
+		{BucketGauges, []int64{2, 0, 1}, []string{
+			"test.latency.bucket:2|g|#tag1:val1,le:0.5",
+			"test.latency.bucket:2|g|#tag1:val1,le:1",
+			"test.latency.bucket:3|g|#tag1:val1,le:2.5",
+		}},
+		{BucketGauges, []int64{2, 0, 1, 4}, []string{
+			"test.latency.bucket:2|g|#tag1:val1,le:0.5",
+			"test.latency.bucket:2|g|#tag1:val1,le:1",
+			"test.latency.bucket:3|g|#tag1:val1,le:2.5",
+			"test.latency.bucket:7|g|#tag1:val1,le:+Inf",
+		}},
+		{BucketValues, []int64{2, 0, 1, 1}, []string{
+			"test.latency:0.5|h|@0.500000|#tag1:val1",
+			"test.latency:2.5|h|#tag1:val1",
+			"test.latency:2.5|h|#tag1:val1",
+		}},
+	}
+
+	bounds := []float64{0.5, 1, 2.5}
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, "test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tags := []Tag{{"tag1", "val1"}}
+		err = c.(*Client).HistogramBuckets("latency", bounds, tt.Counts, tt.Mode, 1.0, tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("mode %d, counts %v: got %q expected %q", tt.Mode, tt.Counts, got, tt.Expected)
+		}
+		if tags[0] != (Tag{"tag1", "val1"}) {
+			t.Errorf("caller tags modified: %v", tags)
+		}
+	}
+}
+
+func TestHistogramBucketsMismatch(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.(*Client).HistogramBuckets("latency", []float64{1, 2}, []int64{1}, BucketGauges, 1.0)
+	if err != errBucketCounts {
+		t.Fatalf("expected errBucketCounts, got %v", err)
+	}
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("nothing should be sent on error, got %q", got)
+	}
+}
+
+func TestHistogramBucketsLargeCounts(t *testing.T) {
+	tests := []struct {
+		Rate     float32
+		Omit     bool
+		Count    int64
+		Expected map[string]int
+	}{
+		// split so the rate keeps its precision
+		{1, false, 2500, map[string]int{
+			"test.latency:0.5|h|@0.001000": 2,
+			"test.latency:0.5|h|@0.002000": 1,
+		}},
+		{1, false, 1000000, map[string]int{"test.latency:0.5|h|@0.001000": 1000}},
+		{0.5, false, 1000, map[string]int{"test.latency:0.5|h|@0.001000": 2}},
+		// without a rate on the wire, each observation is submitted
+		{1, true, 3, map[string]int{"test.latency:0.5|h": 3}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{Prefix: "test", OmitSampleRate: tt.Omit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := c.(*Client)
+		client.SetSamplerFunc(func(float32) bool { return true })
+
+		err = client.HistogramBuckets("latency", []float64{0.5}, []int64{tt.Count}, BucketValues, tt.Rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make(map[string]int)
+		for _, stat := range rs.sent() {
+			got[stat]++
+		}
+		if !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("rate %v, count %d: got %v expected %v", tt.Rate, tt.Count, got, tt.Expected)
+		}
+	}
+}
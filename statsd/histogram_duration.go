@@ -0,0 +1,66 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "time"
+
+// durationUnits are the units HistogramDuration picks from, largest first
+var durationUnits = []struct {
+	unit time.Duration
+	name string
+}{
+	{time.Second, "seconds"},
+	{time.Millisecond, "milliseconds"},
+	{time.Microsecond, "microseconds"},
+	{time.Nanosecond, "nanoseconds"},
+}
+
+// HistogramDuration submits a duration as a statsd histogram type, in the
+// largest unit (of seconds, milliseconds, microseconds and nanoseconds) that
+// keeps the value at least 1, with the unit as a per-call WithUnit option.
+// eg. 500ns is submitted as 500 "nanoseconds", and 2s as 2 "seconds". This
+// keeps precision for short durations, and readability for long ones, for
+// latencies spanning several orders of magnitude.
+// Note: As the unit varies from one stat to the next, it is only usable with
+// DogStatsD (SuffixOctothorpe) tags, where it is a "unit" tag. Dashboards
+// should group by, or convert based on, the unit. Infix tag formats can't
+// carry the unit, so if the client (or any of its Destinations) uses one,
+// durations are always submitted in milliseconds, rather than mixing units
+// in one series.
+// stat is a string name for the metric.
+// delta is the duration to record. Durations under 1ns (including negative
+// ones) are submitted in nanoseconds.
+// rate is the sample rate (0.0 to 1.0).
+func (s *Client) HistogramDuration(stat string, delta time.Duration, rate float32, tags ...Tag) error {
+	// milliseconds, as for timings
+	unit := durationUnits[1]
+	if s.unitTags() {
+		unit = durationUnits[len(durationUnits)-1]
+		for _, u := range durationUnits {
+			if delta >= u.unit {
+				unit = u
+				break
+			}
+		}
+	}
+
+	// cap the slice, so the caller's backing array is never appended to
+	tags = append(tags[:len(tags):len(tags)], WithUnit(unit.name))
+	return s.Histogram(stat, float64(delta)/float64(unit.unit), rate, tags...)
+}
+
+// unitTags reports whether every stat the client submits can carry a unit
+// tag (see WithUnit), ie. all its tag formats are suffix ones.
+func (s *Client) unitTags() bool {
+	if s == nil || s.tagFormat&AllSuffix == 0 {
+		return false
+	}
+	for _, m := range s.mirrors {
+		if m.tagFormat&AllSuffix == 0 {
+			return false
+		}
+	}
+	return true
+}
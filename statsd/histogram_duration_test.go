@@ -0,0 +1,75 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistogramDuration(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	tags := make([]Tag, 1, 2)
+	tags[0] = Tag{"tag1", "val1"}
+	for _, d := range []time.Duration{
+		500 * time.Nanosecond,
+		1500 * time.Nanosecond,
+		250 * time.Millisecond,
+		2 * time.Second,
+		90 * time.Second,
+		0,
+	} {
+		if err := client.HistogramDuration("latency", d, 1.0, tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"test.latency:500|h|#tag1:val1,unit:nanoseconds",
+		"test.latency:1.5|h|#tag1:val1,unit:microseconds",
+		"test.latency:250|h|#tag1:val1,unit:milliseconds",
+		"test.latency:2|h|#tag1:val1,unit:seconds",
+		"test.latency:90|h|#tag1:val1,unit:seconds",
+		"test.latency:0|h|#tag1:val1,unit:nanoseconds",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	// the caller's tags are untouched
+	if tags[:2][1] != (Tag{}) {
+		t.Fatalf("caller tags were appended to: %q", tags[:2])
+	}
+}
+
+func TestHistogramDurationInfix(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", InfixSemicolon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// the unit can't be tagged, so is fixed
+	for _, d := range []time.Duration{500 * time.Nanosecond, 2 * time.Second} {
+		if err := client.HistogramDuration("latency", d, 1.0, Tag{"tag1", "val1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"test.latency;tag1=val1:0.0005|h",
+		"test.latency;tag1=val1:2000|h",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
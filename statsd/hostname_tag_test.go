@@ -0,0 +1,48 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHostnameTag(t *testing.T) {
+	defer func(orig func() (string, error)) { osHostname = orig }(osHostname)
+
+	tests := []struct {
+		Hostname  string
+		Lookup    func() (string, error)
+		TagFormat TagFormat
+		Expected  string
+	}{
+		{"", func() (string, error) { return "web1", nil }, 0, "count:1|c|#env:prod,host:web1"},
+		{"", func() (string, error) { return "web1", nil }, InfixSemicolon, "count;env=prod;host=web1:1|c"},
+		{"override", func() (string, error) { return "web1", nil }, 0, "count:1|c|#env:prod,host:override"},
+		{"", func() (string, error) { return "", errors.New("lookup failed") }, 0, "count:1|c|#env:prod"},
+	}
+
+	for _, tt := range tests {
+		osHostname = tt.Lookup
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			TagFormat:   tt.TagFormat,
+			Tags:        []Tag{{"env", "prod"}},
+			HostnameTag: true,
+			Hostname:    tt.Hostname,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Inc("count", 1, 1.0)
+
+		expected := []string{tt.Expected}
+		if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("got %q expected %q", got, expected)
+		}
+	}
+}
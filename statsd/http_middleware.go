@@ -0,0 +1,77 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusRecorder wraps a http.ResponseWriter, capturing the status code
+// written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before passing it along.
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write passes data along, recording an implicit 200 status if the handler
+// did not call WriteHeader first.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, so that an
+// http.ResponseController can reach any optional interfaces it implements
+// (eg. http.Flusher, http.Hijacker).
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// HTTPMiddleware returns a middleware that instruments an http.Handler.
+//
+// For each request handled, a "requests" count and a "latency" timing are
+// submitted under prefix. Both metrics are tagged with the response status
+// code (status:NNN) and the request method (method:GET, etc).
+//
+// c is the Statter to submit metrics to. A nil *Client is safe to use.
+//
+// prefix is prepended to the metric names. Can be "" if no prefix is desired.
+func HTTPMiddleware(c Statter, prefix string) func(http.Handler) http.Handler {
+	requests := joinPathComp(prefix, "requests")
+	latency := joinPathComp(prefix, "latency")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			// handler wrote nothing at all, so net/http will send a 200
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			tags := []Tag{
+				{"status", strconv.Itoa(rec.status)},
+				{"method", r.Method},
+			}
+			c.Inc(requests, 1, 1.0, tags...)
+			c.TimingDuration(latency, time.Since(start), 1.0, tags...)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	l, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := NewClient(l.LocalAddr().String(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	handler := HTTPMiddleware(c, "http")(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+	req := httptest.NewRequest("POST", "/missing", nil)
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("handler status not passed through: got %d", rw.Code)
+	}
+
+	data := make([]byte, 128)
+	n, _, err := l.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "test.http.requests:1|c|#status:404,method:POST"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Fatalf("got '%s' expected '%s'", data[:n], expected)
+	}
+
+	n, _, err = l.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = data[:n]
+	if !bytes.HasPrefix(data, []byte("test.http.latency:")) ||
+		!bytes.HasSuffix(data, []byte("|ms|#status:404,method:POST")) {
+		t.Fatalf("unexpected latency packet '%s'", data)
+	}
+}
+
+func TestHTTPMiddlewareImplicitStatus(t *testing.T) {
+	l, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := NewClient(l.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	handler := HTTPMiddleware(c, "")(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	data := make([]byte, 128)
+	n, _, err := l.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "requests:1|c|#status:200,method:GET"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Fatalf("got '%s' expected '%s'", data[:n], expected)
+	}
+}
+
+func TestHTTPMiddlewareUnwrap(t *testing.T) {
+	c, err := NewClientWithConfig(&ClientConfig{Sender: &recordingSender{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	w := httptest.NewRecorder()
+	var unwrapped http.ResponseWriter
+	handler := HTTPMiddleware(c, "")(http.HandlerFunc(
+		func(rw http.ResponseWriter, r *http.Request) {
+			if u, ok := rw.(interface{ Unwrap() http.ResponseWriter }); ok {
+				unwrapped = u.Unwrap()
+			}
+		}))
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if unwrapped != w {
+		t.Fatalf("expected the original ResponseWriter, got %v", unwrapped)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// newUDP6Listener returns a udp listener on the IPv6 loopback, or skips the
+// test if IPv6 is not available.
+func newUDP6Listener(t *testing.T) *net.UDPConn {
+	l, err := newUDPListener("[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %s", err)
+	}
+	return l
+}
+
+func TestIPv6Address(t *testing.T) {
+	l := newUDP6Listener(t)
+	defer l.Close()
+
+	addr := l.LocalAddr().String()
+	if addr[0] != '[' {
+		t.Fatalf("expected a bracketed IPv6 address, got %s", addr)
+	}
+
+	configs := map[string]*ClientConfig{
+		"simple":    {Address: addr, Prefix: "test"},
+		"resolving": {Address: addr, Prefix: "test", ResInterval: time.Minute},
+		"buffered":  {Address: addr, Prefix: "test", UseBuffered: true, FlushInterval: time.Hour},
+	}
+	for name, config := range configs {
+		c, err := NewClientWithConfig(config)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		c.Inc("count", 1, 1.0)
+		c.Close()
+
+		l.SetReadDeadline(time.Now().Add(time.Second))
+		data := make([]byte, 128)
+		n, _, err := l.ReadFrom(data)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if expected := []byte("test.count:1|c"); !bytes.Equal(data[:n], expected) {
+			t.Fatalf("%s: got %q expected %q", name, data[:n], expected)
+		}
+	}
+}
+
+func TestIPv6MustBeIP(t *testing.T) {
+	tests := map[string]bool{
+		"[2001:db8::1]:8125": true,
+		"[::1]:8125":         true,
+		"127.0.0.1:8125":     true,
+		"localhost:8125":     false,
+		"2001:db8::1:8125":   false, // ambiguous without brackets
+	}
+	for addr, expected := range tests {
+		if got := mustBeIP(addr); got != expected {
+			t.Errorf("%s: got %v expected %v", addr, got, expected)
+		}
+	}
+}
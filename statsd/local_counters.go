@@ -0,0 +1,162 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLocalCounterInterval is the flush interval, if
+// ClientConfig.LocalCounters is set without a LocalCounterInterval.
+const defaultLocalCounterInterval = time.Second
+
+// maxLocalCounters bounds the number of distinct counters a localCounters
+// accumulates. Once reached, AddLocal for previously unseen counters submits
+// them as usual.
+const maxLocalCounters = 10000
+
+// localKey identifies an accumulated counter. The client is part of the key,
+// as SubStatters may add their own prefix and context tags.
+type localKey struct {
+	client *Client
+	stat   string
+}
+
+// localCount is the sum of an accumulated counter since the last flush
+type localCount struct {
+	n int64
+	// set once the counter is evicted
+	evicted int32
+}
+
+// localCounters accumulates counters with atomic adds (see
+// ClientConfig.LocalCounters), and submits the sums periodically. Adding to a
+// counter already seen takes no locks. Counters with nothing added since the
+// last flush are evicted, so that those of short lived clients (eg. a
+// SubStatter per request) are not kept, but their number is still bounded.
+type localCounters struct {
+	// localKey -> *localCount
+	counters sync.Map
+	size     int64
+	// flushes are serialized, so only one evicts a counter
+	flushMx sync.Mutex
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newLocalCounters(interval time.Duration) *localCounters {
+	if interval <= 0 {
+		interval = defaultLocalCounterInterval
+	}
+
+	l := &localCounters{
+		done: make(chan struct{}),
+	}
+	go l.run(interval)
+	return l
+}
+
+// add adds n to the counter, and reports whether it could be accumulated.
+// It can not once the bound is reached.
+func (l *localCounters) add(s *Client, stat string, n int64) bool {
+	key := localKey{s, stat}
+	v, ok := l.counters.Load(key)
+	if !ok {
+		// reserve a slot, so concurrent adds can't exceed the bound
+		if atomic.AddInt64(&l.size, 1) > maxLocalCounters {
+			atomic.AddInt64(&l.size, -1)
+			return false
+		}
+		var loaded bool
+		v, loaded = l.counters.LoadOrStore(key, &localCount{})
+		if loaded {
+			atomic.AddInt64(&l.size, -1)
+		}
+	}
+
+	c := v.(*localCount)
+	atomic.AddInt64(&c.n, n)
+	if atomic.LoadInt32(&c.evicted) != 0 {
+		// evicted since it was loaded, so the flush may have missed the
+		// add. move whatever is left to a new counter.
+		if rest := atomic.SwapInt64(&c.n, 0); rest != 0 && !l.add(s, stat, rest) {
+			s.submitCount(stat, rest, 1, nil)
+		}
+	}
+	return true
+}
+
+// flush submits the sums so far, resetting them to 0. Counters with a sum of
+// 0 are evicted instead. Returns the first error.
+func (l *localCounters) flush() error {
+	l.flushMx.Lock()
+	defer l.flushMx.Unlock()
+
+	var err error
+	l.counters.Range(func(k, v interface{}) bool {
+		c := v.(*localCount)
+		sum := atomic.SwapInt64(&c.n, 0)
+		if sum == 0 {
+			l.counters.Delete(k)
+			atomic.AddInt64(&l.size, -1)
+			atomic.StoreInt32(&c.evicted, 1)
+			// anything added before the eviction is seen is sent now; adds
+			// that see it move to a new counter
+			if sum = atomic.SwapInt64(&c.n, 0); sum == 0 {
+				return true
+			}
+		}
+		key := k.(localKey)
+		if serr := key.client.submitCount(key.stat, sum, 1, nil); serr != nil && err == nil {
+			err = serr
+		}
+		return true
+	})
+	return err
+}
+
+// stop stops the periodic flushes. It is safe to call more than once.
+func (l *localCounters) stop() {
+	l.once.Do(func() {
+		close(l.done)
+	})
+}
+
+func (l *localCounters) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.flush()
+		}
+	}
+}
+
+// AddLocal adds n to a counter accumulated in memory, and submitted as one
+// summed count every ClientConfig.LocalCounterInterval, for extremely hot
+// counters where even a buffered send per increment is too costly. Adding
+// to a counter seen before is lock-free, close to the cost of an atomic
+// add. Counts added since the last flush are lost if the process exits
+// without closing the client.
+//
+// If ClientConfig.LocalCounters is not set, or the bound on the number of
+// accumulated counters has been reached, the count is submitted as usual,
+// as with Inc.
+func (s *Client) AddLocal(stat string, n int64) error {
+	if !s.Enabled() {
+		return nil
+	}
+	if s.local != nil && s.local.add(s, stat, n) {
+		return nil
+	}
+	return s.Inc(stat, n, 1.0)
+}
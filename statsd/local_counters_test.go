@@ -0,0 +1,233 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAddLocal(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:               "test",
+		LocalCounters:        true,
+		LocalCounterInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	sub := client.NewSubStatter("sub").(*Client)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				client.AddLocal("hot", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	client.AddLocal("other", 5)
+	client.AddLocal("other", -2)
+	sub.AddLocal("hot", 3)
+
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected nothing sent before a flush, got %q", got)
+	}
+
+	if err := client.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// nothing added since the last flush
+	client.Flush()
+
+	got := rs.sent()
+	sort.Strings(got)
+	expected := []string{
+		"test.hot:8000|c",
+		"test.other:3|c",
+		"test.sub.hot:3|c",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAddLocalDisabled(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.(*Client).AddLocal("hot", 2)
+	expected := []string{"test.hot:2|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAddLocalBounded(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	client.local = newLocalCounters(time.Hour)
+	defer client.local.stop()
+
+	for i := 0; i < maxLocalCounters; i++ {
+		client.AddLocal("hot"+strconv.Itoa(i), 1)
+	}
+	if n := atomic.LoadInt64(&client.local.size); n != maxLocalCounters {
+		t.Fatalf("expected %d accumulated counters, got %d", maxLocalCounters, n)
+	}
+
+	// unseen counters past the bound are sent as usual
+	client.AddLocal("overflow", 1)
+	// seen counters are still accumulated
+	client.AddLocal("hot0", 1)
+
+	expected := []string{"test.overflow:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAddLocalEvicted(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:               "test",
+		LocalCounters:        true,
+		LocalCounterInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// a SubStatter per request
+	for i := 0; i < 3; i++ {
+		client.NewSubStatter("req").(*Client).AddLocal("hot", 1)
+	}
+	client.AddLocal("hot", 1)
+	client.Flush()
+	if n := atomic.LoadInt64(&client.local.size); n != 4 {
+		t.Fatalf("expected 4 accumulated counters, got %d", n)
+	}
+
+	// counters with nothing added since the last flush are evicted
+	client.AddLocal("hot", 2)
+	client.Flush()
+	if n := atomic.LoadInt64(&client.local.size); n != 1 {
+		t.Fatalf("expected 1 accumulated counter, got %d", n)
+	}
+	client.Flush()
+	if n := atomic.LoadInt64(&client.local.size); n != 0 {
+		t.Fatalf("expected no accumulated counters, got %d", n)
+	}
+
+	// and accumulated again once used
+	client.AddLocal("hot", 3)
+	client.Flush()
+
+	got := rs.sent()
+	sort.Strings(got)
+	expected := []string{
+		"test.hot:1|c",
+		"test.hot:2|c",
+		"test.hot:3|c",
+		"test.req.hot:1|c",
+		"test.req.hot:1|c",
+		"test.req.hot:1|c",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestAddLocalEvictedConcurrent(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:               "test",
+		LocalCounters:        true,
+		LocalCounterInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// flushes evicting the counter between adds lose nothing
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			client.AddLocal("hot", 1)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			c.Close()
+			var total int64
+			for _, stat := range rs.sent() {
+				n, err := strconv.ParseInt(stat[len("test.hot:"):len(stat)-len("|c")], 10, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				total += n
+			}
+			if total != 1000 {
+				t.Fatalf("expected a total of 1000, got %d", total)
+			}
+			return
+		default:
+			client.Flush()
+		}
+	}
+}
+
+func BenchmarkAddLocal(b *testing.B) {
+	c, err := newClientWithConfig(discardSender{}, &ClientConfig{
+		Prefix:               "test",
+		LocalCounters:        true,
+		LocalCounterInterval: time.Hour,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	client := c.(*Client)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			client.AddLocal("benchinc", 1)
+		}
+	})
+}
+
+// BenchmarkAtomicAdd is the baseline for BenchmarkAddLocal
+func BenchmarkAtomicAdd(b *testing.B) {
+	var n int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			atomic.AddInt64(&n, 1)
+		}
+	})
+}
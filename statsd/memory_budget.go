@@ -0,0 +1,52 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "sync/atomic"
+
+// memoryBudget is a soft cap on the bytes held by a client's aggregator and
+// request batches (see ClientConfig.MaxBufferMemory), shared between them.
+// A nil *memoryBudget has no limit.
+type memoryBudget struct {
+	limit int64
+	used  int64
+}
+
+// newMemoryBudget returns a memoryBudget, or nil if limit is 0, so budget
+// checks can be skipped entirely.
+func newMemoryBudget(limit int) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryBudget{limit: int64(limit)}
+}
+
+// reserve reserves n bytes, and reports whether there was room for them.
+func (m *memoryBudget) reserve(n int) bool {
+	if m == nil {
+		return true
+	}
+	if atomic.AddInt64(&m.used, int64(n)) > m.limit {
+		atomic.AddInt64(&m.used, -int64(n))
+		return false
+	}
+	return true
+}
+
+// release returns n reserved bytes to the budget.
+func (m *memoryBudget) release(n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	atomic.AddInt64(&m.used, -int64(n))
+}
+
+// inUse returns the bytes currently reserved.
+func (m *memoryBudget) inUse() int64 {
+	if m == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&m.used)
+}
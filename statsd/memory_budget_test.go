@@ -0,0 +1,177 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxBufferMemoryAggregateFlush(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+		// room for two series
+		MaxBufferMemory: 2*aggregateOverhead + 40,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	c.Inc("c1", 1, 1.0)
+	c.Inc("c2", 1, 1.0)
+	c.Inc("c1", 1, 1.0)
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected aggregated stats to wait, got %q", got)
+	}
+
+	// a third series flushes the first two early, to make room
+	c.Inc("c3", 1, 1.0)
+	got := rs.sent()
+	if len(got) != 2 || got[0] == got[1] {
+		t.Fatalf("expected the first two series to be flushed, got %q", got)
+	}
+
+	client.Flush()
+	if got := rs.sent(); got[len(got)-1] != "test.c3:1|c" {
+		t.Fatalf("got %q", got)
+	}
+	if n := client.Stats().DroppedOverMemory; n != 0 {
+		t.Fatalf("expected no dropped stats, got %d", n)
+	}
+	if n := client.memory.inUse(); n != 0 {
+		t.Fatalf("expected no memory in use once flushed, got %d", n)
+	}
+}
+
+func TestMaxBufferMemoryAggregateFlushOnce(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+		// room for two series
+		MaxBufferMemory: 2*aggregateOverhead + 40,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// the first series over the budget flushes early, to make room
+	for _, stat := range []string{"c1", "c2", "c3", "c4"} {
+		c.Inc(stat, 1, 1.0)
+	}
+	if got := rs.sent(); len(got) != 2 {
+		t.Fatalf("expected the first two series to be flushed, got %q", got)
+	}
+
+	// but only once per window, later ones are dropped
+	c.Inc("c5", 1, 1.0)
+	c.Inc("c6", 1, 1.0)
+	if got := rs.sent(); len(got) != 2 {
+		t.Fatalf("expected no more early flushes, got %q", got)
+	}
+	if n := client.Stats().DroppedOverMemory; n != 2 {
+		t.Fatalf("expected 2 dropped stats, got %d", n)
+	}
+
+	// until the next window
+	client.aggregator.flushWindow()
+	for _, stat := range []string{"c1", "c2", "c3"} {
+		c.Inc(stat, 1, 1.0)
+	}
+	if got := rs.sent(); len(got) != 6 {
+		t.Fatalf("expected another early flush, got %q", got)
+	}
+	if n := client.Stats().DroppedOverMemory; n != 2 {
+		t.Fatalf("expected 2 dropped stats, got %d", n)
+	}
+}
+
+func TestMaxBufferMemoryDrops(t *testing.T) {
+	rs := &recordingSender{}
+	const limit = 1024
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+		MaxBufferMemory:   limit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// one batch holds most of the memory, so others can't make room
+	first := client.NewRequestBatch()
+	for i := 0; i < 10; i++ {
+		first.Inc(fmt.Sprintf("first.%d.%s", i, strings.Repeat("x", 80)), 1, 1.0)
+	}
+	held := client.memory.inUse()
+	if held > limit || held < limit-aggregateOverhead {
+		t.Fatalf("expected the first batch to hold most of the memory, got %d", held)
+	}
+
+	second := client.NewRequestBatch()
+	for i := 0; i < 10; i++ {
+		second.Inc(fmt.Sprintf("second.%d.%s", i, strings.Repeat("x", 200)), 1, 1.0)
+		c.Inc(fmt.Sprintf("aggregated.%d", i), 1, 1.0)
+		if n := client.memory.inUse(); n > limit {
+			t.Fatalf("memory use %d over the limit %d", n, limit)
+		}
+	}
+	if n := client.Stats().DroppedOverMemory; n != 20 {
+		t.Fatalf("expected 20 stats dropped and counted, got %d", n)
+	}
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected nothing sent, got %q", got)
+	}
+
+	// once memory is freed, stats are held again
+	first.Close()
+	second.Inc("second", 1, 1.0)
+	c.Inc("aggregated", 1, 1.0)
+	second.Close()
+	client.Flush()
+
+	expected := 10 + 2
+	if got := rs.sent(); countLines(got) != expected {
+		t.Fatalf("expected %d stats sent, got %q", expected, got)
+	}
+	if n := client.memory.inUse(); n != 0 {
+		t.Fatalf("expected no memory in use once flushed, got %d", n)
+	}
+}
+
+// countLines returns the number of stats in packets.
+func countLines(packets []string) int {
+	n := 0
+	for _, p := range packets {
+		n += len(strings.Split(p, "\n"))
+	}
+	return n
+}
+
+func TestMaxBufferMemoryUnset(t *testing.T) {
+	c, err := newClientWithConfig(&recordingSender{}, &ClientConfig{Aggregate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if m := c.(*Client).memory; m != nil {
+		t.Fatalf("expected no memory budget, got %+v", m)
+	}
+	if !reflect.DeepEqual(c.(*Client).Stats(), ClientStats{}) {
+		t.Fatal("expected no counts")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "errors"
+
+var errNegativeCount = errors.New("negative delta for monotonic counter")
+
+// MonotonicGuard controls what happens to a negative delta for a counter that
+// must never go down (see ClientConfig.MonotonicCounters).
+type MonotonicGuard uint8
+
+const (
+	// MonotonicReject drops the negative delta, and returns an error from
+	// Inc or Dec. This is the default.
+	MonotonicReject MonotonicGuard = iota
+	// MonotonicClamp submits the negative delta as zero, without an error.
+	MonotonicClamp
+)
+
+// monotonicGuard guards counters that must never go down against negative
+// deltas. It is read-only once created, so is safe for concurrent use.
+type monotonicGuard struct {
+	names map[string]struct{}
+	mode  MonotonicGuard
+}
+
+// newMonotonicGuard returns a monotonicGuard for the named counters, or nil
+// if there are none, so the guard can be skipped entirely.
+func newMonotonicGuard(names []string, mode MonotonicGuard) *monotonicGuard {
+	if len(names) == 0 {
+		return nil
+	}
+
+	g := &monotonicGuard{
+		names: make(map[string]struct{}, len(names)),
+		mode:  mode,
+	}
+	for _, name := range names {
+		g.names[name] = struct{}{}
+	}
+	return g
+}
+
+// guard returns the delta to submit for stat, and whether it was altered.
+// A rejected delta returns errNegativeCount.
+func (g *monotonicGuard) guard(stat string, value int64) (int64, bool, error) {
+	if value >= 0 {
+		return value, false, nil
+	}
+	if _, ok := g.names[stat]; !ok {
+		return value, false, nil
+	}
+	if g.mode == MonotonicClamp {
+		return 0, true, nil
+	}
+	return value, true, errNegativeCount
+}
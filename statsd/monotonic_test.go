@@ -0,0 +1,53 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMonotonicGuard(t *testing.T) {
+	tests := []struct {
+		Mode     MonotonicGuard
+		Expected []string
+		Err      bool
+	}{
+		{MonotonicReject, []string{"bytes:5|c", "other:-1|c"}, true},
+		{MonotonicClamp, []string{"bytes:5|c", "bytes:0|c", "bytes:0|c", "other:-1|c"}, false},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			MonotonicCounters: []string{"bytes"},
+			MonotonicGuard:    tt.Mode,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Inc("bytes", 5, 1.0); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Inc("bytes", -3, 1.0); (err != nil) != tt.Err {
+			t.Errorf("mode %d: negative Inc error %v, expected error %t", tt.Mode, err, tt.Err)
+		}
+		if err := c.Dec("bytes", 2, 1.0); (err != nil) != tt.Err {
+			t.Errorf("mode %d: Dec error %v, expected error %t", tt.Mode, err, tt.Err)
+		}
+		// unguarded counters are unaffected
+		if err := c.Inc("other", -1, 1.0); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("mode %d: got %q expected %q", tt.Mode, got, tt.Expected)
+		}
+		if n := c.(*Client).Stats().NegativeCounts; n != 2 {
+			t.Errorf("mode %d: expected 2 negative counts, got %d", tt.Mode, n)
+		}
+	}
+}
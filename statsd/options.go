@@ -0,0 +1,202 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Per-call options are passed to the metric methods alongside any tags, so
+// that they fit the existing method signatures. eg.
+//
+//	client.Inc("stat1", 42, 1.0, statsd.Tag{"env", "prod"}, statsd.WithTimestamp(t))
+//
+// An option is a Tag with a reserved key, built by one of the With functions
+// below. Reserved keys start with a NUL byte, which is never valid in a tag
+// key, and splitOptions separates options from tags before anything is
+// written out. Statter implementations that don't submit via a Client can
+// use IsOption to tell options apart from tags.
+
+// optionKind identifies a per-call option
+type optionKind uint8
+
+const (
+	optNone optionKind = iota
+	optField
+	optUnit
+	optExemplar
+	optPrefix
+)
+
+// the reserved keys of the per-call options
+const (
+	optionKeyBase     = "\x00statsd."
+	optionKeyField    = optionKeyBase + "field"
+	optionKeyUnit     = optionKeyBase + "unit"
+	optionKeyExemplar = optionKeyBase + "exemplar"
+	optionKeyPrefix   = optionKeyBase + "prefix"
+)
+
+// optionOf returns the kind of option t is, or optNone for a plain tag
+func optionOf(t Tag) optionKind {
+	if len(t[0]) == 0 || t[0][0] != 0 {
+		return optNone
+	}
+	switch t[0] {
+	case optionKeyField:
+		return optField
+	case optionKeyUnit:
+		return optUnit
+	case optionKeyExemplar:
+		return optExemplar
+	case optionKeyPrefix:
+		return optPrefix
+	}
+	return optNone
+}
+
+// IsOption reports whether t is a per-call option (see WithField), rather
+// than a tag.
+func IsOption(t Tag) bool {
+	return optionOf(t) != optNone
+}
+
+var (
+	errInvalidField    = errors.New("invalid extension field")
+	errInvalidExemplar = errors.New("invalid exemplar trace id")
+)
+
+// WithField returns a per-call option that appends an extension field to the
+// stat line, for server features without a dedicated option. Fields are
+// written after the sample rate and tags, in the order given, as is
+// required by DogStatsD. eg.
+//
+//	client.Gauge("stat1", 42, 1.0, statsd.WithField("c:container-id"))
+//
+// A field may not be empty, start with a '@' or '#' (reserved for the sample
+// rate and tags), or contain a '|' or newline. Submitting a stat with an
+// invalid field returns an error.
+func WithField(field string) Tag {
+	return Tag{optionKeyField, field}
+}
+
+// WithTimestamp returns a per-call option that sets the DogStatsD metric
+// timestamp (the "|T" extension field), for submitting late metrics.
+// Note: Only supported by DogStatsD.
+func WithTimestamp(ts time.Time) Tag {
+	return WithField("T" + strconv.FormatInt(ts.Unix(), 10))
+}
+
+// WithUnit returns a per-call option that annotates the stat with a unit
+// (eg. "bytes", "seconds", "requests"). It is submitted as a "unit" tag after
+// any other tags, for DogStatsD (SuffixOctothorpe) tags. Plain statsd has no
+// representation for units, so for infix tag formats it is ignored. eg.
+//
+//	client.Gauge("heap", 4096, 1.0, statsd.WithUnit("bytes"))
+func WithUnit(unit string) Tag {
+	return Tag{optionKeyUnit, unit}
+}
+
+// WithExemplar returns a per-call option that attaches an OpenMetrics style
+// exemplar (the trace ID of the request behind this particular observation)
+// to a histogram. It is submitted as a "trace_id:" extension field, before
+// any other fields, for DogStatsD (SuffixOctothorpe) tags, for bridges to
+// backends that support exemplars. It is ignored for infix tag formats, and
+// for any stat type other than a histogram. eg.
+//
+//	client.Histogram("latency", 0.25, 1.0, statsd.WithExemplar(traceID))
+//
+// A trace ID may not be empty, or contain a '|', ',' or newline. Submitting
+// a stat with an invalid trace ID returns an error.
+func WithExemplar(traceID string) Tag {
+	return Tag{optionKeyExemplar, traceID}
+}
+
+// WithPrefix returns a per-call option that submits the stat with prefix p
+// instead of the client prefix, for a one-off stat that doesn't warrant a
+// SubStatter. Any leading or trailing '.' in p is dropped, as the separator
+// is added as usual, and an empty p submits the stat without a prefix. eg.
+//
+//	client.Inc("stat1", 1, 1.0, statsd.WithPrefix("legacy.app"))
+func WithPrefix(p string) Tag {
+	return Tag{optionKeyPrefix, strings.Trim(p, ".")}
+}
+
+// Absolute returns a per-call option that submits the stat with an absolute
+// name, as-is, skipping any Client (or SubStatter) prefix. It is equivalent
+// to WithPrefix(""). eg.
+//
+//	client.Inc("shared.requests", 1, 1.0, statsd.Absolute())
+func Absolute() Tag {
+	return Tag{optionKeyPrefix, ""}
+}
+
+// callOptions holds the per-call options found amongst a stat's tags
+type callOptions struct {
+	fields   bool
+	unit     string
+	exemplar string
+	// prefix overrides the client prefix, if hasPrefix
+	prefix    string
+	hasPrefix bool
+}
+
+// hasOptions reports whether any of the tags are per-call options
+func hasOptions(tags []Tag) bool {
+	for _, t := range tags {
+		if optionOf(t) != optNone {
+			return true
+		}
+	}
+	return false
+}
+
+// splitOptions appends the plain tags to dst, and records the per-call
+// options in opts.
+func splitOptions(dst []Tag, tags []Tag, opts *callOptions) ([]Tag, error) {
+	for _, t := range tags {
+		switch optionOf(t) {
+		case optNone:
+			dst = append(dst, t)
+		case optField:
+			if !validField(t[1]) {
+				return nil, errInvalidField
+			}
+			opts.fields = true
+		case optUnit:
+			opts.unit = t[1]
+		case optExemplar:
+			if t[1] == "" || strings.ContainsAny(t[1], "|,\n") {
+				return nil, errInvalidExemplar
+			}
+			opts.exemplar = t[1]
+		case optPrefix:
+			opts.prefix = t[1]
+			opts.hasPrefix = true
+		}
+	}
+	return dst, nil
+}
+
+func validField(field string) bool {
+	if field == "" || field[0] == '@' || field[0] == '#' {
+		return false
+	}
+	return !strings.ContainsAny(field, "|\n")
+}
+
+// appendFields appends any extension field options amongst the tags
+func appendFields(data []byte, tags []Tag) []byte {
+	for _, t := range tags {
+		if optionOf(t) == optField {
+			data = append(data, '|')
+			data = append(data, t[1]...)
+		}
+	}
+	return data
+}
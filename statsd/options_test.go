@@ -0,0 +1,233 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithTimestamp(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sample everything in, so the output is deterministic
+	c.(*Client).SetSamplerFunc(func(float32) bool { return true })
+
+	ts := time.Unix(1656581400, 0)
+	c.Gauge("gauge", 1, 1.0, WithTimestamp(ts))
+	c.Inc("count", 1, 0.5, Tag{"tag1", "val1"}, WithTimestamp(ts), Tag{"tag2", "val2"})
+	c.Inc("count", 1, 1.0, WithTimestamp(ts), WithField("c:abc123"))
+
+	expected := []string{
+		"test.gauge:1|g|T1656581400",
+		"test.count:1|c|@0.500000|#tag1:val1,tag2:val2|T1656581400",
+		"test.count:1|c|T1656581400|c:abc123",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestWithFieldInfix(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", InfixComma)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Inc("count", 1, 1.0, Tag{"tag1", "val1"}, WithField("c:abc123"))
+
+	expected := []string{"test.count,tag1=val1:1|c|c:abc123"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestWithFieldInvalid(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"", "@0.5", "#tag:val", "a|b", "a\nb"} {
+		if err := c.Inc("count", 1, 1.0, WithField(field)); err != errInvalidField {
+			t.Errorf("field %q: expected errInvalidField, got %v", field, err)
+		}
+	}
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected nothing sent, got %q", got)
+	}
+}
+
+func TestOptionLookalikeTag(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", SuffixOctothorpe)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a tag with a key like an option's is still a tag
+	c.Inc("count", 1, 1.0, Tag{"\x00field", "c:abc123"})
+
+	expected := []string{"test.count:1|c|#\x00field:c:abc123"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestIsOption(t *testing.T) {
+	options := []Tag{
+		WithField("c:abc123"),
+		WithTimestamp(time.Unix(1656581400, 0)),
+		WithUnit("bytes"),
+		WithExemplar("4bf92f3577b34da6"),
+		WithPrefix("legacy"),
+		Absolute(),
+	}
+	for _, o := range options {
+		if !IsOption(o) {
+			t.Errorf("expected %q to be an option", o)
+		}
+		// options are told apart by value, so copies are still options
+		if c := (Tag{o[0], o[1]}); !IsOption(c) {
+			t.Errorf("expected a copy of %q to be an option", o)
+		}
+	}
+	for _, tag := range []Tag{{"field", "c:abc123"}, {"\x00field", "c:abc123"}, {"", ""}} {
+		if IsOption(tag) {
+			t.Errorf("expected %q to be a tag", tag)
+		}
+	}
+}
+
+func TestWithExemplar(t *testing.T) {
+	tests := []struct {
+		TagFormat TagFormat
+		Expected  []string
+	}{
+		{SuffixOctothorpe, []string{
+			"test.latency:0.25|h|#tag1:val1|trace_id:4bf92f3577b34da6",
+			"test.size:3|h|@0.500000|trace_id:4bf92f3577b34da6|T1656581400",
+			"test.requests:1|c",
+		}},
+		{InfixComma, []string{
+			"test.latency,tag1=val1:0.25|h",
+			"test.size:3|h|@0.500000|T1656581400",
+			"test.requests:1|c",
+		}},
+	}
+
+	traceID := "4bf92f3577b34da6"
+	ts := time.Unix(1656581400, 0)
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, "test", tt.TagFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.(*Client).SetSamplerFunc(func(float32) bool { return true })
+
+		c.Histogram("latency", 0.25, 1.0, Tag{"tag1", "val1"}, WithExemplar(traceID))
+		c.Histogram("size", 3, 0.5, WithTimestamp(ts), WithExemplar(traceID))
+		// only histograms carry exemplars
+		c.Inc("requests", 1, 1.0, WithExemplar(traceID))
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("format %d: got %q expected %q", tt.TagFormat, got, tt.Expected)
+		}
+
+		for _, id := range []string{"", "a|b", "a,b", "a\nb"} {
+			if err := c.Histogram("latency", 1, 1.0, WithExemplar(id)); err != errInvalidExemplar {
+				t.Errorf("exemplar %q: got err %v expected %v", id, err, errInvalidExemplar)
+			}
+		}
+	}
+}
+
+func TestWithUnit(t *testing.T) {
+	tests := []struct {
+		TagFormat TagFormat
+		Expected  []string
+	}{
+		{SuffixOctothorpe, []string{
+			"test.heap:4096|g|#unit:bytes",
+			"test.latency:5|ms|#tag1:val1,unit:seconds|T1656581400",
+		}},
+		{InfixComma, []string{
+			"test.heap:4096|g",
+			"test.latency,tag1=val1:5|ms|T1656581400",
+		}},
+	}
+
+	ts := time.Unix(1656581400, 0)
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, "test", tt.TagFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Gauge("heap", 4096, 1.0, WithUnit("bytes"))
+		c.Timing("latency", 5, 1.0, WithUnit("seconds"), Tag{"tag1", "val1"}, WithTimestamp(ts))
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("format %d: got %q expected %q", tt.TagFormat, got, tt.Expected)
+		}
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	tests := []struct {
+		Prefix    string
+		TagFormat TagFormat
+		Expected  []string
+	}{
+		{"test", SuffixOctothorpe, []string{
+			"other.count:1|c|#tag1:val1",
+			"test.count:1|c",
+			"dotted.count:1|c",
+			"count:1|c",
+			"abs:1|c",
+		}},
+		{"", SuffixOctothorpe, []string{
+			"other.count:1|c|#tag1:val1",
+			"count:1|c",
+			"dotted.count:1|c",
+			"count:1|c",
+			"abs:1|c",
+		}},
+		{"test", InfixComma, []string{
+			"other.count,tag1=val1:1|c",
+			"test.count:1|c",
+			"dotted.count:1|c",
+			"count:1|c",
+			"abs:1|c",
+		}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, tt.Prefix, tt.TagFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Inc("count", 1, 1.0, WithPrefix("other"), Tag{"tag1", "val1"})
+		// later calls revert to the client prefix
+		c.Inc("count", 1, 1.0)
+		c.Inc("count", 1, 1.0, WithPrefix(".dotted."))
+		c.Inc("count", 1, 1.0, WithPrefix(""))
+		c.Inc("abs", 1, 1.0, WithPrefix("other"), Absolute())
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("prefix %q, format %d: got %q expected %q", tt.Prefix, tt.TagFormat, got, tt.Expected)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// FlushOnPanic flushes any buffered stats if a panic is in progress, then
+// re-panics. It is intended to be deferred at the top of a goroutine, so
+// that the last stats before a crash are not lost:
+//
+//	defer statsd.FlushOnPanic(client)
+//
+// If c does not implement Flusher, the panic is passed along untouched.
+func FlushOnPanic(c Statter) {
+	if r := recover(); r != nil {
+		flushStatter(c)
+		panic(r)
+	}
+}
+
+// CountAndFlushOnPanic is like FlushOnPanic, but also increments the counter
+// stat before flushing, if a panic is in progress:
+//
+//	defer statsd.CountAndFlushOnPanic(client, "panics")
+func CountAndFlushOnPanic(c Statter, stat string) {
+	if r := recover(); r != nil {
+		c.Inc(stat, 1, 1.0)
+		flushStatter(c)
+		panic(r)
+	}
+}
+
+func flushStatter(c Statter) {
+	if f, ok := c.(Flusher); ok {
+		f.Flush()
+	}
+}
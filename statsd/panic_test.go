@@ -0,0 +1,99 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runPanicking runs fn, which is expected to panic, and returns the value
+// it panicked with.
+func runPanicking(fn func()) (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestFlushOnPanic(t *testing.T) {
+	rs := &recordingSender{}
+	// flush interval long enough that only an explicit flush sends anything
+	sender, err := NewBufferedSenderWithSender(rs, time.Hour, 1432)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithSender(sender, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r := runPanicking(func() {
+		defer FlushOnPanic(c)
+		c.Inc("count", 1, 1.0)
+		panic("boom")
+	})
+	if r != "boom" {
+		t.Fatalf("expected panic to propagate, got %v", r)
+	}
+
+	expected := []string{"test.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestCountAndFlushOnPanic(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := NewBufferedSenderWithSender(rs, time.Hour, 1432)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithSender(sender, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r := runPanicking(func() {
+		defer CountAndFlushOnPanic(c, "panics")
+		c.Inc("count", 1, 1.0)
+		panic("boom")
+	})
+	if r != "boom" {
+		t.Fatalf("expected panic to propagate, got %v", r)
+	}
+
+	expected := []string{"test.count:1|c\ntest.panics:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestFlushOnPanicNoPanic(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := NewBufferedSenderWithSender(rs, time.Hour, 1432)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithSender(sender, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	func() {
+		defer CountAndFlushOnPanic(c, "panics")
+		c.Inc("count", 1, 1.0)
+	}()
+
+	// no panic, so nothing is counted or flushed early
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected nothing sent, got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "sync"
+
+// maxPrimedStats bounds the number of distinct stat names a primer will
+// track. Once reached, stats with previously unseen names are sampled
+// normally.
+const maxPrimedStats = 10000
+
+type primeKey struct {
+	prefix string
+	stat   string
+}
+
+// primer tracks how many times each stat name has been seen, so that the
+// first occurrences of a stat can bypass sampling.
+type primer struct {
+	count int
+	mx    sync.Mutex
+	seen  map[primeKey]int
+}
+
+func newPrimer(count int) *primer {
+	return &primer{
+		count: count,
+		seen:  make(map[primeKey]int),
+	}
+}
+
+// prime reports whether this occurrence of the stat is within the first
+// count occurrences, and should therefore always be sent.
+func (p *primer) prime(prefix, stat string) bool {
+	key := primeKey{prefix, stat}
+
+	p.mx.Lock()
+	n, ok := p.seen[key]
+	if !ok && len(p.seen) >= maxPrimedStats {
+		p.mx.Unlock()
+		return false
+	}
+	if n >= p.count {
+		p.mx.Unlock()
+		return false
+	}
+	p.seen[key] = n + 1
+	p.mx.Unlock()
+	return true
+}
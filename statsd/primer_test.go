@@ -0,0 +1,83 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestPrimeFirstOccurrence(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{Prefix: "test", PrimeCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// never sample anything in, so only primed stats get through
+	c.(*Client).SetSamplerFunc(func(float32) bool { return false })
+
+	for i := 0; i < 100; i++ {
+		c.Inc("count", 1, 0.01)
+		c.Inc("other", 1, 0.01)
+	}
+
+	expected := []string{"test.count:1|c", "test.other:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestPrimeSubStatter(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{Prefix: "test", PrimeCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*Client).SetSamplerFunc(func(float32) bool { return false })
+	s := c.NewSubStatter("sub")
+
+	for i := 0; i < 5; i++ {
+		c.Inc("count", 1, 0.01)
+		s.Inc("count", 1, 0.01)
+	}
+
+	expected := []string{
+		"test.count:1|c", "test.sub.count:1|c",
+		"test.count:1|c", "test.sub.count:1|c",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestPrimeDisabled(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{Prefix: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*Client).SetSamplerFunc(func(float32) bool { return false })
+
+	c.Inc("count", 1, 0.01)
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected nothing sent, got %q", got)
+	}
+}
+
+func TestPrimeBounded(t *testing.T) {
+	p := newPrimer(1)
+	for i := 0; i < maxPrimedStats; i++ {
+		if !p.prime("", strconv.Itoa(i)) {
+			t.Fatalf("stat %d should have been primed", i)
+		}
+	}
+	if p.prime("", "one-too-many") {
+		t.Fatal("stat should not be primed once the bound is reached")
+	}
+	if len(p.seen) != maxPrimedStats {
+		t.Fatalf("expected %d tracked stats, got %d", maxPrimedStats, len(p.seen))
+	}
+}
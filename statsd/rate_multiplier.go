@@ -0,0 +1,199 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"time"
+)
+
+var errExtendedUnsupported = errors.New("statter does not support extended stats")
+
+// WithRateMultiplier returns a Statter that submits stats via base, with
+// every sample rate multiplied by factor, eg. a factor of 0.1 to sample
+// everything 10 times more aggressively in a staging environment, without
+// touching any call sites. SubStatters of the returned Statter apply the
+// factor too. factor is limited to 0.0 to 1.0, and a factor of 0 drops every
+// stat.
+//
+// The returned Statter also implements ExtendedStatSender, Flusher, Pinger,
+// Introspector and CapabilityReporter, by forwarding to base, so that eg.
+// FlushOnPanic works through it. The ExtendedStatSender methods return an
+// error if base doesn't implement ExtendedStatSender.
+func WithRateMultiplier(base Statter, factor float32) Statter {
+	return &rateMultiplier{newMultipliedSender(base, factor), base}
+}
+
+// multipliedSender wraps a StatSender, multiplying every sample rate by
+// factor.
+type multipliedSender struct {
+	sender StatSender
+	factor float32
+}
+
+// newMultipliedSender returns a multipliedSender for sender, with factor
+// limited to 0.0 to 1.0. With a factor of 0, stats are submitted to a nil
+// Client, which is a noop.
+func newMultipliedSender(sender StatSender, factor float32) multipliedSender {
+	switch {
+	case !(factor > 0):
+		return multipliedSender{(*Client)(nil), 0}
+	case factor > 1:
+		factor = 1
+	}
+	return multipliedSender{sender, factor}
+}
+
+// rate returns the rate to submit a stat with, limited to 0.0 to 1.0
+func (m multipliedSender) rate(rate float32) float32 {
+	rate *= m.factor
+	switch {
+	case rate < 0:
+		return 0
+	case rate > 1:
+		return 1
+	}
+	return rate
+}
+
+func (m multipliedSender) Inc(stat string, value int64, rate float32, tags ...Tag) error {
+	return m.sender.Inc(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) Dec(stat string, value int64, rate float32, tags ...Tag) error {
+	return m.sender.Dec(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) Gauge(stat string, value int64, rate float32, tags ...Tag) error {
+	return m.sender.Gauge(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) GaugeDelta(stat string, value int64, rate float32, tags ...Tag) error {
+	return m.sender.GaugeDelta(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) Timing(stat string, delta int64, rate float32, tags ...Tag) error {
+	return m.sender.Timing(stat, delta, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) TimingDuration(stat string, delta time.Duration, rate float32, tags ...Tag) error {
+	return m.sender.TimingDuration(stat, delta, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) Histogram(stat string, value float64, rate float32, tags ...Tag) error {
+	return m.sender.Histogram(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) Set(stat string, value string, rate float32, tags ...Tag) error {
+	return m.sender.Set(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) SetInt(stat string, value int64, rate float32, tags ...Tag) error {
+	return m.sender.SetInt(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) Raw(stat string, value string, rate float32, tags ...Tag) error {
+	return m.sender.Raw(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) GaugeFloat(stat string, value float64, rate float32, tags ...Tag) error {
+	e, ok := m.sender.(ExtendedStatSender)
+	if !ok {
+		return errExtendedUnsupported
+	}
+	return e.GaugeFloat(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) GaugeFloatDelta(stat string, value float64, rate float32, tags ...Tag) error {
+	e, ok := m.sender.(ExtendedStatSender)
+	if !ok {
+		return errExtendedUnsupported
+	}
+	return e.GaugeFloatDelta(stat, value, m.rate(rate), tags...)
+}
+
+func (m multipliedSender) SetFloat(stat string, value float64, rate float32, tags ...Tag) error {
+	e, ok := m.sender.(ExtendedStatSender)
+	if !ok {
+		return errExtendedUnsupported
+	}
+	return e.SetFloat(stat, value, m.rate(rate), tags...)
+}
+
+// rateMultiplier is the Statter returned by WithRateMultiplier
+type rateMultiplier struct {
+	multipliedSender
+	base Statter
+}
+
+func (m *rateMultiplier) NewSubStatter(prefix string) SubStatter {
+	sub := m.base.NewSubStatter(prefix)
+	return &subRateMultiplier{newMultipliedSender(sub, m.factor), sub}
+}
+
+func (m *rateMultiplier) SetPrefix(prefix string) {
+	m.base.SetPrefix(prefix)
+}
+
+func (m *rateMultiplier) Close() error {
+	return m.base.Close()
+}
+
+// Flush flushes base, if it supports it.
+func (m *rateMultiplier) Flush() error {
+	if f, ok := m.base.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Ping pings base, if it supports it. Otherwise, an error is returned.
+func (m *rateMultiplier) Ping() error {
+	p, ok := m.base.(Pinger)
+	if !ok {
+		return errPingUnsupported
+	}
+	return p.Ping()
+}
+
+// Prefix returns the prefix of base, if it reports it.
+func (m *rateMultiplier) Prefix() string {
+	if i, ok := m.base.(Introspector); ok {
+		return i.Prefix()
+	}
+	return ""
+}
+
+// TagFormat returns the tag format of base, if it reports it.
+func (m *rateMultiplier) TagFormat() TagFormat {
+	if i, ok := m.base.(Introspector); ok {
+		return i.TagFormat()
+	}
+	return 0
+}
+
+// Supports reports whether base supports a stat type, if it reports it.
+// Otherwise, every type is reported as supported.
+func (m *rateMultiplier) Supports(statType string) bool {
+	if c, ok := m.base.(CapabilityReporter); ok {
+		return c.Supports(statType)
+	}
+	return true
+}
+
+// subRateMultiplier is a SubStatter of a rateMultiplier
+type subRateMultiplier struct {
+	multipliedSender
+	base SubStatter
+}
+
+func (m *subRateMultiplier) SetSamplerFunc(sampler SamplerFunc) {
+	m.base.SetSamplerFunc(sampler)
+}
+
+func (m *subRateMultiplier) NewSubStatter(prefix string) SubStatter {
+	sub := m.base.NewSubStatter(prefix)
+	return &subRateMultiplier{newMultipliedSender(sub, m.factor), sub}
+}
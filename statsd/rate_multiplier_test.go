@@ -0,0 +1,110 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithRateMultiplier(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*Client).SetSamplerFunc(func(float32) bool { return true })
+
+	m := WithRateMultiplier(c, 0.1)
+	m.Inc("count", 1, 1.0)
+	m.Timing("timing", 5, 0.5)
+	m.NewSubStatter("sub").Gauge("gauge", 2, 1.0)
+	m.(ExtendedStatSender).GaugeFloat("fgauge", 1.5, 1.0)
+	// factors above 1 are limited to 1
+	m = WithRateMultiplier(c, 20)
+	m.Inc("count", 1, 0.5)
+
+	expected := []string{
+		"test.count:1|c|@0.100000",
+		"test.timing:5|ms|@0.050000",
+		"test.sub.gauge:2|g|@0.100000",
+		"test.fgauge:1.5|g|@0.100000",
+		"test.count:1|c|@0.500000",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestWithRateMultiplierSamples(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := WithRateMultiplier(c, 0.1)
+	const sends = 10000
+	for i := 0; i < sends; i++ {
+		m.Inc("count", 1, 1.0)
+	}
+
+	// well over 5 standard deviations either side
+	if fraction := float64(len(rs.sent())) / sends; fraction < 0.085 || fraction > 0.115 {
+		t.Fatalf("expected about 10%% of stats sent, got %.1f%%", fraction*100)
+	}
+}
+
+func TestWithRateMultiplierDropAll(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*Client).SetSamplerFunc(func(float32) bool { return true })
+
+	for _, factor := range []float32{0, -1, float32(math.NaN())} {
+		m := WithRateMultiplier(c, factor)
+		if err := m.Inc("count", 1, 1.0); err != nil {
+			t.Fatal(err)
+		}
+		m.NewSubStatter("sub").Gauge("gauge", 2, 1.0)
+		m.(ExtendedStatSender).GaugeFloat("fgauge", 1.5, 1.0)
+	}
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("expected every stat dropped, got %q", got)
+	}
+}
+
+func TestWithRateMultiplierForwards(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := NewBufferedSenderWithSender(rs, time.Hour, 1432)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithSender(sender, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := WithRateMultiplier(c, 1)
+	defer m.Close()
+
+	if p := m.(Introspector).Prefix(); p != "test" {
+		t.Fatalf("got prefix %q expected %q", p, "test")
+	}
+
+	// buffered stats are flushed through the wrapper on a panic
+	runPanicking(func() {
+		defer FlushOnPanic(m)
+		m.Inc("count", 1, 1.0)
+		panic("boom")
+	})
+	expected := []string{"test.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// batchSender holds the stats sent to it, until flushed to the target sender.
+type batchSender struct {
+	target Sender
+	// separator between stats, and the largest send when flushing
+	separator  []byte
+	flushBytes int
+
+	mx     sync.Mutex
+	data   []byte
+	closed bool
+	// memory reserved for data, when there is a budget
+	budget   *memoryBudget
+	held     int
+	counters *clientCounters
+}
+
+// Send holds data until flushed. If the memory budget is exhausted, the
+// batch is flushed early to make room, and if there is still none, data is
+// dropped and counted, returning ErrOverMemory.
+func (b *batchSender) Send(data []byte) (int, error) {
+	n := len(data) + len(b.separator)
+	if !b.budget.reserve(n) {
+		b.flush()
+		if !b.budget.reserve(n) {
+			atomic.AddInt64(&b.counters.droppedOverMemory, 1)
+			return 0, ErrOverMemory
+		}
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.closed {
+		b.budget.release(n)
+		return 0, ErrClosed
+	}
+	if len(b.data) > 0 {
+		b.data = append(b.data, b.separator...)
+	}
+	b.data = append(b.data, data...)
+	b.held += n
+	return len(data), nil
+}
+
+// flush sends the held stats to the target, in as few sends as possible
+// without exceeding flushBytes (unless a single stat does). Stat boundaries
+// can only be found with a separator, so without one the held stats are
+// sent as-is.
+func (b *batchSender) flush() error {
+	b.mx.Lock()
+	data, held := b.data, b.held
+	b.data = nil
+	b.held = 0
+	b.mx.Unlock()
+	b.budget.release(held)
+
+	var err error
+	for len(data) > 0 {
+		n := len(data)
+		if n > b.flushBytes && len(b.separator) > 0 {
+			if i := bytes.LastIndex(data[:b.flushBytes], b.separator); i > 0 {
+				n = i
+			} else if i := bytes.Index(data, b.separator); i > 0 {
+				n = i
+			}
+		}
+
+		if _, serr := b.target.Send(data[:n]); serr != nil && err == nil {
+			err = serr
+		}
+		data = bytes.TrimPrefix(data[n:], b.separator)
+	}
+	return err
+}
+
+// Close marks the batchSender closed. It does not close the target, which
+// belongs to the client.
+func (b *batchSender) Close() error {
+	b.mx.Lock()
+	b.closed = true
+	b.mx.Unlock()
+	return nil
+}
+
+// RequestBatch is a Client that holds the stats submitted through it (and
+// its SubStatters) until Flush or Close, then sends them together, eg. once
+// at the end of a request. Each batch is independent of the client's other
+// batches, and of any buffering by the client sender, so concurrent requests
+// never see each other's stats.
+//
+// Stats in a batch are not aggregated (see ClientConfig.Aggregate and
+// ClientConfig.LocalCounters), as they are sent together anyway. Batches
+// count towards ClientConfig.MaxBufferMemory, and are flushed early if it is
+// reached. As for a buffered client, held stats are sent in packets of up to
+// ClientConfig.FlushBytes, separated by ClientConfig.BufferSeparator.
+type RequestBatch struct {
+	*Client
+	senders []*batchSender
+}
+
+// NewRequestBatch returns a new, empty, RequestBatch, sending to the client's
+// sender (and any ClientConfig.Destinations) when flushed.
+func (s *Client) NewRequestBatch() *RequestBatch {
+	if s == nil {
+		return &RequestBatch{}
+	}
+
+	c := s.NewSubStatter("").(*Client)
+	c.aggregator = nil
+	c.local = nil
+
+	b := &RequestBatch{Client: c}
+	c.sender = b.wrap(s.sender)
+	c.mirrors = make([]mirror, len(s.mirrors))
+	for i, m := range s.mirrors {
+		c.mirrors[i] = mirror{b.wrap(m.sender), m.tagFormat}
+	}
+	return b
+}
+
+func (b *RequestBatch) wrap(target Sender) *batchSender {
+	bs := &batchSender{
+		target:     target,
+		separator:  b.batchSeparator,
+		flushBytes: b.batchFlushBytes,
+		budget:     b.memory,
+		counters:   b.counters,
+	}
+	if bs.separator == nil {
+		bs.separator = defaultSeparator
+	}
+	if bs.flushBytes <= 0 {
+		bs.flushBytes = defaultFlushBytes
+	}
+	b.senders = append(b.senders, bs)
+	return bs
+}
+
+// Flush sends the stats held by the batch, leaving it empty and ready for
+// more.
+func (b *RequestBatch) Flush() error {
+	var err error
+	for _, bs := range b.senders {
+		if ferr := bs.flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// Close sends the stats held by the batch. Stats submitted after Close
+// return ErrClosed. The client the batch came from is not closed.
+func (b *RequestBatch) Close() error {
+	for _, bs := range b.senders {
+		bs.Close()
+	}
+	return b.Flush()
+}
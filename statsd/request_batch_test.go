@@ -0,0 +1,144 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRequestBatch(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := c.(*Client).NewRequestBatch()
+	b.Inc("count", 1, 1.0)
+	b.NewSubStatter("sub").Timing("timing", 5, 1.0)
+	c.Inc("direct", 1, 1.0)
+
+	// nothing from the batch is sent until it is flushed
+	if got, expected := rs.sent(), []string{"test.direct:1|c"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b.Gauge("gauge", 2, 1.0)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"test.direct:1|c",
+		"test.count:1|c\ntest.sub.timing:5|ms",
+		"test.gauge:2|g",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	if err := b.Inc("count", 1, 1.0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after close, got %v", err)
+	}
+	// the client is still usable
+	if err := c.Inc("direct", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestBatchConcurrent(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"first", "second"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			b := c.(*Client).NewRequestBatch()
+			for i := 0; i < 10; i++ {
+				b.Inc(name, int64(i), 1.0)
+			}
+			b.Close()
+		}(name)
+	}
+	wg.Wait()
+
+	sent := rs.sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected one send per batch, got %q", sent)
+	}
+	for _, packet := range sent {
+		name := "first"
+		if strings.HasPrefix(packet, "test.second") {
+			name = "second"
+		}
+		lines := make([]string, 10)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("test.%s:%d|c", name, i)
+		}
+		if expected := strings.Join(lines, "\n"); packet != expected {
+			t.Fatalf("got %q expected %q", packet, expected)
+		}
+	}
+}
+
+func TestRequestBatchSplit(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := c.(*Client).NewRequestBatch()
+	stat := strings.Repeat("x", 500)
+	for i := 0; i < 4; i++ {
+		b.Inc(stat, 1, 1.0)
+	}
+	b.Close()
+
+	// no more stats per send than fit in defaultFlushBytes
+	line := "test." + stat + ":1|c"
+	expected := []string{line + "\n" + line, line + "\n" + line}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %d sends, expected %d", len(got), len(expected))
+	}
+}
+
+func TestRequestBatchConfig(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:          "test",
+		BufferSeparator: []byte{0},
+		FlushBytes:      32,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client's separator and flush size apply to its batches
+	b := c.(*Client).NewRequestBatch()
+	for i := 0; i < 3; i++ {
+		b.Inc("count", 1, 1.0)
+	}
+	b.Close()
+
+	line := "test.count:1|c"
+	expected := []string{line + "\x00" + line, line}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
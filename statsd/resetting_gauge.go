@@ -0,0 +1,104 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultResettingGaugeInterval is the period of a ResettingGauge created
+// with an interval <= 0.
+const defaultResettingGaugeInterval = time.Second
+
+// A ResettingGauge periodically submits the last value it was set to. If it
+// is not set again before the next period, the value decays (by default,
+// straight back to 0). This gives "spike then return to baseline" semantics
+// for gauges that are only set when something happens.
+type ResettingGauge struct {
+	client *Client
+	stat   string
+	tags   []Tag
+
+	mx      sync.Mutex
+	value   int64
+	updated bool
+	decay   func(int64) int64
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewResettingGauge returns a ResettingGauge that submits to stat once every
+// interval (1 second if <= 0), until stopped.
+func (s *Client) NewResettingGauge(stat string, interval time.Duration, tags ...Tag) *ResettingGauge {
+	if interval <= 0 {
+		interval = defaultResettingGaugeInterval
+	}
+
+	g := &ResettingGauge{
+		client: s,
+		stat:   stat,
+		tags:   tags,
+		decay:  func(int64) int64 { return 0 },
+		done:   make(chan struct{}),
+	}
+	go g.run(interval)
+	return g
+}
+
+// Set sets the gauge value, to be submitted on the next period.
+func (g *ResettingGauge) Set(value int64) {
+	g.mx.Lock()
+	g.value = value
+	g.updated = true
+	g.mx.Unlock()
+}
+
+// SetDecay sets the function used to decay the value for each period that
+// passes without an update. The default resets the value to 0.
+// For example, to halve the value each idle period:
+//
+//	g.SetDecay(func(v int64) int64 { return v / 2 })
+func (g *ResettingGauge) SetDecay(decay func(int64) int64) {
+	g.mx.Lock()
+	g.decay = decay
+	g.mx.Unlock()
+}
+
+// Stop stops the periodic submission. It is safe to call more than once.
+func (g *ResettingGauge) Stop() {
+	g.once.Do(func() {
+		close(g.done)
+	})
+}
+
+func (g *ResettingGauge) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+			g.tick()
+		}
+	}
+}
+
+// tick decays the value if it was not updated in the last period, then
+// submits it.
+func (g *ResettingGauge) tick() error {
+	g.mx.Lock()
+	if !g.updated {
+		g.value = g.decay(g.value)
+	}
+	g.updated = false
+	value := g.value
+	g.mx.Unlock()
+
+	return g.client.Gauge(g.stat, value, 1.0, g.tags...)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResettingGauge(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// long interval, so ticks are driven by hand
+	g := c.(*Client).NewResettingGauge("spike", time.Hour)
+	defer g.Stop()
+
+	g.Set(5)
+	g.tick()
+	g.tick() // idle, so resets
+	g.tick()
+	g.Set(7)
+	g.tick()
+
+	expected := []string{
+		"test.spike:5|g",
+		"test.spike:0|g",
+		"test.spike:0|g",
+		"test.spike:7|g",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestResettingGaugeDecay(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := c.(*Client).NewResettingGauge("spike", time.Hour, Tag{"tag1", "val1"})
+	defer g.Stop()
+	g.SetDecay(func(v int64) int64 { return v / 2 })
+
+	g.Set(8)
+	g.tick()
+	g.tick()
+	g.tick()
+
+	expected := []string{
+		"test.spike:8|g|#tag1:val1",
+		"test.spike:4|g|#tag1:val1",
+		"test.spike:2|g|#tag1:val1",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestResettingGaugeTimer(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := c.(*Client).NewResettingGauge("spike", 5*time.Millisecond)
+	g.Set(5)
+
+	// wait for the value to be sent, then reset after an idle interval
+	deadline := time.Now().Add(time.Second)
+	for {
+		sent := rs.sent()
+		if len(sent) >= 2 {
+			g.Stop()
+			if sent[0] != "test.spike:5|g" || sent[1] != "test.spike:0|g" {
+				t.Fatalf("unexpected gauges sent: %q", sent)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for gauges, got %q", sent)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	g.Stop()
+}
+
+func TestResettingGaugeDefaultInterval(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an interval <= 0 is defaulted, rather than panicking
+	g := c.(*Client).NewResettingGauge("spike", 0)
+	defer g.Stop()
+	g.Set(5)
+
+	deadline := time.Now().Add(2 * defaultResettingGaugeInterval)
+	for len(rs.sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the gauge")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if sent := rs.sent(); sent[0] != "test.spike:5|g" {
+		t.Fatalf("unexpected gauges sent: %q", sent)
+	}
+}
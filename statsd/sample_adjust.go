@@ -0,0 +1,66 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxAdjustedStats bounds the number of distinct counters a sampleAdjuster
+// will hold sampled out values for. Once reached, sampled out values for
+// previously unseen counters are dropped, as with plain sampling.
+const maxAdjustedStats = 10000
+
+// sampleAdjuster holds the values of sampled out counters, until the next
+// sampled in submission of the same counter (name and tags) carries them.
+type sampleAdjuster struct {
+	mx      sync.Mutex
+	pending map[string]int64
+}
+
+func newSampleAdjuster() *sampleAdjuster {
+	return &sampleAdjuster{
+		pending: make(map[string]int64),
+	}
+}
+
+// adjustKey identifies a counter series, by prefix, name and tags. Per-call
+// options are not part of the series, so are skipped, apart from a prefix
+// override (see WithPrefix).
+func adjustKey(prefix, stat string, tags []Tag) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	b.WriteByte(0)
+	b.WriteString(stat)
+	for _, t := range tags {
+		if k := optionOf(t); k != optNone && k != optPrefix {
+			continue
+		}
+		b.WriteByte(0)
+		b.WriteString(t[0])
+		b.WriteByte('=')
+		b.WriteString(t[1])
+	}
+	return b.String()
+}
+
+// hold adds a sampled out value to the pending total for the series.
+func (a *sampleAdjuster) hold(key string, value int64) {
+	a.mx.Lock()
+	if _, ok := a.pending[key]; ok || len(a.pending) < maxAdjustedStats {
+		a.pending[key] += value
+	}
+	a.mx.Unlock()
+}
+
+// take returns, and clears, the pending total for the series.
+func (a *sampleAdjuster) take(key string) int64 {
+	a.mx.Lock()
+	value := a.pending[key]
+	delete(a.pending, key)
+	a.mx.Unlock()
+	return value
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSampleAdjust(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:       "test",
+		SampleAdjust: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampled := false
+	c.(*Client).SetSamplerFunc(func(float32) bool { return sampled })
+
+	c.Inc("count", 2, 0.5)
+	c.Inc("count", 3, 0.5)
+	// a different series (by tags) is held separately
+	c.Inc("count", 7, 0.5, Tag{"tag1", "val1"})
+	c.Dec("count", 1, 0.5)
+	sampled = true
+	c.Inc("count", 1, 0.5)
+	c.Inc("count", 1, 0.5)
+
+	expected := []string{"test.count:5|c", "test.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestSampleAdjustPreservesTotal(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		SampleAdjust: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	c.(*Client).SetSamplerFunc(func(rate float32) bool {
+		return r.Float32() < rate
+	})
+
+	const iterations = 10000
+	const rate = 0.1
+	for i := 0; i < iterations; i++ {
+		c.Inc("count", 1, rate)
+	}
+
+	sent := rs.sent()
+	var total int64
+	for _, stat := range sent {
+		v := strings.TrimSuffix(strings.TrimPrefix(stat, "count:"), "|c")
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			t.Fatalf("unexpected stat %q", stat)
+		}
+		total += n
+	}
+
+	// only the values held since the last sampled in submission are missing
+	if total > iterations || total < iterations*0.99 {
+		t.Fatalf("sent total %d too far from true total %d", total, iterations)
+	}
+	// and sampling still reduced the packet count
+	if len(sent) > iterations*rate*1.2 {
+		t.Fatalf("expected about %d packets, got %d", int(iterations*rate), len(sent))
+	}
+}
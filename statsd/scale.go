@@ -0,0 +1,40 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// valueScales holds scale factors for the counts of the named stats (see
+// ClientConfig.ScaleFactor). It is read-only once created, so is safe for
+// concurrent use.
+type valueScales map[string]float64
+
+// newValueScales returns a copy of factors, or nil if there are none, so
+// scaling can be skipped entirely.
+func newValueScales(factors map[string]float64) valueScales {
+	if len(factors) == 0 {
+		return nil
+	}
+
+	sc := make(valueScales, len(factors))
+	for stat, factor := range factors {
+		sc[stat] = factor
+	}
+	return sc
+}
+
+// submitScaledCount is submitCount, for a count already multiplied by its
+// scale factor. Whole values are formatted without a fraction, so a scaled
+// count is only a float on the wire if it has to be.
+func (s *Client) submitScaledCount(stat string, value float64, rate float32, tags []Tag) error {
+	if rate < 1 {
+		switch s.counterScaling {
+		case CounterScaleClient:
+			value /= float64(rate)
+			rate = 1
+		case CounterScaleNone:
+			rate = 1
+		}
+	}
+	return s.submit(stat, "", value, "|c", rate, tags)
+}
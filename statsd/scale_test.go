@@ -0,0 +1,48 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScaleFactor(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix: "test",
+		ScaleFactor: map[string]float64{
+			"bytes": 1.0 / 1024,
+			"items": 10,
+		},
+		CounterScaling: CounterScaleClient,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	client.SetSamplerFunc(func(float32) bool { return true })
+
+	c.Inc("bytes", 2048, 1.0)
+	c.Inc("bytes", 512, 1.0)
+	c.Dec("bytes", 1024, 1.0)
+	c.NewSubStatter("sub").Inc("items", 3, 1.0)
+	// scaled, then scaled up for the sample rate
+	c.Inc("items", 1, 0.5)
+	// unscaled
+	c.Inc("other", 2048, 1.0)
+
+	expected := []string{
+		"test.bytes:2|c",
+		"test.bytes:0.5|c",
+		"test.bytes:-1|c",
+		"test.sub.items:30|c",
+		"test.items:20|c",
+		"test.other:2048|c",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
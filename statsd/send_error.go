@@ -0,0 +1,54 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A SendError is returned by the metric methods when a stat could not be
+// sent, so that errors logged from a shared error path say which stat
+// failed. It unwraps to the error from the Sender, for errors.Is and
+// errors.As.
+type SendError struct {
+	// Stat is the stat name, including any prefix
+	Stat string
+	// Type is the stat type, as on the wire (eg. "c", "g", "ms")
+	Type string
+	// Err is the error from the Sender
+	Err error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("statsd: send %q (%s): %v", e.Stat, e.Type, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// sendError wraps an error from sending stat, with the stat type suffix and
+// per-call options
+func (s *Client) sendError(stat, suffix string, opts *callOptions, err error) error {
+	return &SendError{
+		Stat: s.fullStatName(stat, opts),
+		Type: strings.TrimPrefix(suffix, "|"),
+		Err:  err,
+	}
+}
+
+// fullStatName returns a stat name as submitted, with any prefix, which may
+// be overridden by the per-call options
+func (s *Client) fullStatName(stat string, opts *callOptions) string {
+	prefix := s.prefix
+	if opts.hasPrefix {
+		prefix = opts.prefix
+	}
+	if prefix != "" {
+		return prefix + "." + stat
+	}
+	return stat
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSendError(t *testing.T) {
+	fs := &flakySender{fails: 10, err: syscall.ECONNREFUSED}
+	c, err := NewClientWithSender(fs, "app", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Err      error
+		Expected string
+	}{
+		{c.Timing("latency", 5, 1.0), `statsd: send "app.latency" (ms): connection refused`},
+		{c.Inc("requests", 1, 1.0, Tag{"tag1", "val1"}), `statsd: send "app.requests" (c): connection refused`},
+		{c.Gauge("shared.heap", 1, 1.0, Absolute()), `statsd: send "shared.heap" (g): connection refused`},
+		{c.NewSubStatter("db").TimingDuration("query", time.Second, 1.0), `statsd: send "app.db.query" (ms): connection refused`},
+		{c.Inc("requests", 1, 1.0, WithPrefix("other")), `statsd: send "other.requests" (c): connection refused`},
+	}
+
+	for _, tt := range tests {
+		if tt.Err == nil || tt.Err.Error() != tt.Expected {
+			t.Errorf("got %v expected %s", tt.Err, tt.Expected)
+			continue
+		}
+		if cause := errors.Unwrap(tt.Err); cause != syscall.ECONNREFUSED {
+			t.Errorf("expected the send error to unwrap to the cause, got %v", cause)
+		}
+		var se *SendError
+		if !errors.As(tt.Err, &se) {
+			t.Errorf("expected a *SendError, got %T", tt.Err)
+		}
+	}
+}
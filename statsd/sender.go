@@ -7,14 +7,33 @@ package statsd
 import (
 	"errors"
 	"net"
+	"time"
 )
 
+// pingTimeout is how long a udp Ping waits for an error response.
+const pingTimeout = 100 * time.Millisecond
+
 // The Sender interface wraps a Send and Close
 type Sender interface {
 	Send(data []byte) (int, error)
 	Close() error
 }
 
+// ErrClosed is returned when sending on a sender that has been closed.
+var ErrClosed = errors.New("sender is closed")
+
+// The Pinger interface wraps a Ping, which checks whether the remote endpoint
+// is reachable.
+type Pinger interface {
+	Ping() error
+}
+
+// The Flusher interface wraps a Flush, which sends any buffered data right
+// away.
+type Flusher interface {
+	Flush() error
+}
+
 // SimpleSender provides a socket send interface.
 type SimpleSender struct {
 	// underlying connection
@@ -43,6 +62,40 @@ func (s *SimpleSender) Close() error {
 	return err
 }
 
+// Ping checks whether the server endpoint is reachable.
+// See pingUDP for the caveats of checking a udp endpoint.
+func (s *SimpleSender) Ping() error {
+	return pingUDP(s.ra)
+}
+
+// pingUDP checks whether a udp endpoint is reachable, by sending an empty
+// packet over a connected socket, then waiting briefly for an error.
+//
+// Note that this is best-effort only. A refused connection (nothing
+// listening on the port) is reported by the remote host, and surfaces as an
+// error. But the absence of an error does not guarantee that anything
+// received the packet, as udp has no acknowledgements, and the error
+// response may itself be dropped or filtered.
+func pingUDP(ra *net.UDPAddr) error {
+	conn, err := net.DialUDP("udp", nil, ra)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte{}); err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(pingTimeout))
+	_, err = conn.Read(make([]byte, 1))
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		// no news is good news
+		return nil
+	}
+	return err
+}
+
 // NewSimpleSender returns a new SimpleSender for sending to the supplied
 // addresss.
 //
@@ -0,0 +1,195 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultBreakerCooldown is how long the circuit breaker stays open, if
+// ClientConfig.BreakerFailures is set without a BreakerCooldown.
+const defaultBreakerCooldown = 10 * time.Second
+
+// BreakerState is the state of the circuit breaker around the client sender
+// (see ClientConfig.BreakerFailures).
+type BreakerState uint8
+
+const (
+	// BreakerClosed sends stats as usual. This is the state of a client
+	// without a circuit breaker.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen drops stats without sending them, after repeated send
+	// failures, until the cool-down is over.
+	BreakerOpen
+	// BreakerHalfOpen sends a single stat, as a probe, once the cool-down is
+	// over, dropping any others until the probe has been sent. If it
+	// succeeds the breaker closes, otherwise it opens again.
+	BreakerHalfOpen
+)
+
+// breakerSender is a circuit breaker around a sender (see
+// ClientConfig.BreakerFailures), to stop sending, rather than adding the
+// latency of a failing send to every stat, while the server is overloaded.
+// Stats dropped while the breaker is open are counted.
+type breakerSender struct {
+	Sender
+	failures int
+	window   time.Duration
+	cooldown time.Duration
+	counters *clientCounters
+	// separator between the stats in a buffer, nil if unbuffered
+	separator []byte
+	// returned for dropped stats, nil unless strict (see ClientConfig.Strict)
+	dropErr error
+
+	mx    sync.Mutex
+	state BreakerState
+	// the current run of consecutive failures, and when it started
+	failed      int
+	failedSince time.Time
+	openedAt    time.Time
+}
+
+func newBreakerSender(sender Sender, config *ClientConfig, counters *clientCounters) *breakerSender {
+	cooldown := config.BreakerCooldown
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	b := &breakerSender{
+		Sender:   sender,
+		failures: config.BreakerFailures,
+		window:   config.BreakerWindow,
+		cooldown: cooldown,
+		counters: counters,
+	}
+	if config.UseBuffered {
+		b.separator = defaultSeparator
+		if config.BufferSeparator != nil {
+			b.separator = append([]byte{}, config.BufferSeparator...)
+		}
+	}
+	if config.Strict {
+		b.dropErr = ErrCircuitOpen
+	}
+	return b
+}
+
+// Send sends data via the underlying sender, unless the breaker is open, in
+// which case data is dropped, and each stat in it counted.
+func (b *breakerSender) Send(data []byte) (int, error) {
+	ok, probe := b.allow()
+	if !ok {
+		atomic.AddInt64(&b.counters.droppedStats, int64(statCount(data, b.separator)))
+		return 0, b.dropErr
+	}
+
+	n, err := b.Sender.Send(data)
+	b.record(err, probe)
+	return n, err
+}
+
+// allow reports whether a send may go ahead, and whether it is the probe,
+// moving an open breaker to half-open once the cool-down is over.
+func (b *breakerSender) allow() (ok, probe bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false, false
+		}
+		// this send is the probe
+		b.state = BreakerHalfOpen
+		return true, true
+	case BreakerHalfOpen:
+		// a probe is already being sent
+		return false, false
+	}
+	return true, false
+}
+
+// record updates the breaker with the result of a send. Only the probe
+// decides whether a breaker that is not closed closes or opens again: the
+// result of a send started before the breaker opened is stale.
+func (b *breakerSender) record(err error, probe bool) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if !probe && b.state != BreakerClosed {
+		return
+	}
+
+	if err == nil {
+		b.state = BreakerClosed
+		b.failed = 0
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case probe:
+		// the probe failed
+		b.open(now)
+	case fatalSendError(err):
+		// a closed sender is not a sign of an overloaded server
+	default:
+		if b.failed == 0 || (b.window > 0 && now.Sub(b.failedSince) > b.window) {
+			// start a new run of failures
+			b.failed = 0
+			b.failedSince = now
+		}
+		b.failed++
+		if b.failed >= b.failures {
+			b.open(now)
+		}
+	}
+}
+
+func (b *breakerSender) open(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.failed = 0
+}
+
+// statCount returns the number of stats in data, a buffer of stats separated
+// by sep. Stats can't be told apart without a separator, so count as one.
+func statCount(data, sep []byte) int {
+	if len(sep) == 0 {
+		return 1
+	}
+	return bytes.Count(data, sep) + 1
+}
+
+// BreakerState returns the current state of the breaker.
+func (b *breakerSender) BreakerState() BreakerState {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.state
+}
+
+// Ping pings the underlying sender, if it supports it.
+func (b *breakerSender) Ping() error {
+	return ping(b.Sender)
+}
+
+// Flush flushes the underlying sender, if it supports it.
+func (b *breakerSender) Flush() error {
+	if f, ok := b.Sender.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// breakerState returns the state of any circuit breaker in sender.
+func breakerState(sender Sender) BreakerState {
+	if bs, ok := sender.(interface{ BreakerState() BreakerState }); ok {
+		return bs.BreakerState()
+	}
+	return BreakerClosed
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// three failures open the breaker, and the first probe fails too
+	fs := &flakySender{fails: 4, err: syscall.ENOBUFS}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:          fs,
+		Prefix:          "test",
+		BreakerFailures: 3,
+		BreakerCooldown: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	for i := 0; i < 3; i++ {
+		if state := client.BreakerState(); state != BreakerClosed {
+			t.Fatalf("failure %d: expected a closed breaker, got %d", i, state)
+		}
+		if err := c.Inc("count", 1, 1.0); err == nil {
+			t.Fatalf("failure %d: expected the send error", i)
+		}
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("expected an open breaker, got %d", state)
+	}
+
+	// while open, sends are short-circuited
+	for i := 0; i < 2; i++ {
+		if err := c.Inc("count", 1, 1.0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fs.attempts != 3 {
+		t.Fatalf("expected 3 send attempts, got %d", fs.attempts)
+	}
+	if dropped := client.Stats().DroppedStats; dropped != 2 {
+		t.Fatalf("expected 2 dropped stats, got %d", dropped)
+	}
+
+	// a failed probe opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	if err := c.Inc("count", 1, 1.0); err == nil {
+		t.Fatal("expected the probe send error")
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("expected an open breaker, got %d", state)
+	}
+	c.Inc("count", 1, 1.0)
+	if fs.attempts != 4 {
+		t.Fatalf("expected 4 send attempts, got %d", fs.attempts)
+	}
+
+	// a successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	if err := c.Inc("count", 2, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Fatalf("expected a closed breaker, got %d", state)
+	}
+	c.Inc("count", 3, 1.0)
+
+	expected := []string{"test.count:2|c", "test.count:3|c"}
+	if got := fs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if dropped := client.Stats().DroppedStats; dropped != 3 {
+		t.Fatalf("expected 3 dropped stats, got %d", dropped)
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	fs := &flakySender{fails: 3, err: syscall.ENOBUFS}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:          fs,
+		BreakerFailures: 2,
+		BreakerWindow:   20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// failures further apart than the window don't open the breaker
+	c.Inc("count", 1, 1.0)
+	time.Sleep(30 * time.Millisecond)
+	c.Inc("count", 1, 1.0)
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Fatalf("expected a closed breaker, got %d", state)
+	}
+
+	c.Inc("count", 1, 1.0)
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("expected an open breaker, got %d", state)
+	}
+}
+
+func TestBreakerBuffered(t *testing.T) {
+	fs := &flakySender{fails: 1, err: syscall.ENOBUFS}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:          fs,
+		UseBuffered:     true,
+		FlushInterval:   time.Hour,
+		BreakerFailures: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	client := c.(*Client)
+
+	c.Inc("count", 1, 1.0)
+	if err := client.Flush(); err == nil {
+		t.Fatal("expected the flush error")
+	}
+	if state := client.BreakerState(); state != BreakerOpen {
+		t.Fatalf("expected an open breaker, got %d", state)
+	}
+
+	// each stat in a dropped buffer is counted
+	dropped := client.Stats().DroppedStats
+	c.Inc("count", 1, 1.0)
+	c.Inc("count", 1, 1.0)
+	c.Inc("count", 1, 1.0)
+	client.Flush()
+	if n := client.Stats().DroppedStats - dropped; n != 3 {
+		t.Fatalf("expected 3 dropped stats, got %d", n)
+	}
+}
+
+// slowSender blocks sends of "slow" until released, then succeeds. Other
+// sends fail with err.
+type slowSender struct {
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (s *slowSender) Send(data []byte) (int, error) {
+	if string(data) != "slow" {
+		return 0, s.err
+	}
+	close(s.started)
+	<-s.release
+	return len(data), nil
+}
+
+func (s *slowSender) Close() error {
+	return nil
+}
+
+func TestBreakerStaleSuccess(t *testing.T) {
+	ss := &slowSender{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		err:     syscall.ENOBUFS,
+	}
+	b := newBreakerSender(ss, &ClientConfig{
+		BreakerFailures: 1,
+		BreakerCooldown: time.Hour,
+	}, &clientCounters{})
+
+	// a send started while closed, which succeeds after the breaker opens
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Send([]byte("slow"))
+	}()
+	<-ss.started
+	b.Send([]byte("fail"))
+	if state := b.BreakerState(); state != BreakerOpen {
+		t.Fatalf("expected an open breaker, got %d", state)
+	}
+
+	close(ss.release)
+	<-done
+	if state := b.BreakerState(); state != BreakerOpen {
+		t.Fatalf("expected the breaker to stay open, got %d", state)
+	}
+}
+
+func TestBreakerUnset(t *testing.T) {
+	c, err := NewClientWithConfig(&ClientConfig{Sender: &recordingSender{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := c.(*Client).BreakerState(); state != BreakerClosed {
+		t.Fatalf("expected a closed breaker, got %d", state)
+	}
+
+	var nilClient *Client
+	if state := nilClient.BreakerState(); state != BreakerClosed {
+		t.Fatalf("expected a closed breaker, got %d", state)
+	}
+}
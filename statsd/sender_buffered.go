@@ -7,22 +7,102 @@ package statsd
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var senderPool = newBufferPool()
 
+var defaultSeparator = []byte{'\n'}
+
+// BufferStats holds buffer fill and flush statistics for a BufferedSender,
+// useful for tuning FlushBytes and FlushInterval.
+type BufferStats struct {
+	// Bytes is the number of bytes currently buffered.
+	Bytes int
+	// HighWater is the largest number of bytes buffered at once.
+	HighWater int
+	// FullFlushes is the number of flushes triggered by the buffer filling.
+	FullFlushes int64
+	// IntervalFlushes is the number of flushes triggered by the flush
+	// interval passing.
+	IntervalFlushes int64
+	// CountFlushes is the number of flushes triggered by the buffer holding
+	// flushCount stats.
+	CountFlushes int64
+	// ExplicitFlushes is the number of flushes triggered by calling Flush.
+	ExplicitFlushes int64
+	// CloseFlushes is the number of flushes triggered by closing the sender.
+	CloseFlushes int64
+	// IdleFlushes is the number of flushes triggered by no stats being
+	// sent for the idle flush duration.
+	IdleFlushes int64
+	// Expired is the number of sends dropped for being buffered longer than
+	// the maximum buffer age.
+	Expired int64
+}
+
+// bufStamp records when a send was buffered, and where it ends in the buffer
+type bufStamp struct {
+	end int
+	at  time.Time
+}
+
+// stampedBuffer is a buffer queued for flushing, along with its stamps
+type stampedBuffer struct {
+	buf    *bytes.Buffer
+	stamps []bufStamp
+}
+
+// flush trigger reasons, as passed to BufferedSender.onFlush
+const (
+	flushFull     = "full"
+	flushCount    = "count"
+	flushInterval = "interval"
+	flushExplicit = "explicit"
+	flushClose    = "close"
+	flushIdle     = "idle"
+)
+
 // BufferedSender provides a buffered statsd udp, sending multiple
 // metrics, where possible.
 type BufferedSender struct {
 	sender        Sender
 	flushBytes    int
 	flushInterval time.Duration
+	// number of stats that triggers a flush. 0 means no limit.
+	flushCount int
+	// sort stats within each flush, for reproducible packets
+	sortStats bool
+	// flush once no sends have been buffered for idleFlush. 0 means never.
+	idleFlush time.Duration
+	idleTimer *time.Timer
+	// drop sends buffered for longer than maxAge. 0 means never.
+	maxAge time.Duration
+	now    func() time.Time
+	// single writer mode: no locking, and no background flushing. flushes
+	// happen synchronously, on the sending goroutine. intervalDue is set by
+	// a ticker once the flush interval passes, and checked on each send.
+	single      bool
+	intervalDue int32
+	tickDone    chan struct{}
+	// separator between buffered stats. nil means the default, a newline.
+	separator []byte
 	// buffers
 	bufmx  sync.Mutex
 	buffer *bytes.Buffer
-	bufs   chan *bytes.Buffer
+	bufs   chan stampedBuffer
+	// number of stats in buffer
+	count int
+	// when each send in buffer was buffered, if there is a maxAge
+	stamps []bufStamp
+	// buffer stats, guarded by bufmx
+	stats BufferStats
+	// called with the reason after each flush, outside of any locks. guarded
+	// by bufmx.
+	onFlush func(reason string)
 	// lifecycle
 	runmx    sync.RWMutex
 	shutdown chan chan error
@@ -38,31 +118,72 @@ func (s *BufferedSender) Send(data []byte) (int, error) {
 	// but it is still faster to not use it in some cases
 	// (like this one).
 
+	if s.single {
+		return s.sendSingle(data)
+	}
+
 	s.runmx.RLock()
 	if !s.running {
 		s.runmx.RUnlock()
 		return 0, fmt.Errorf("BufferedSender is not running")
 	}
 
+	// up to two flushes: one to make room, and one once full
+	var flushes [2]string
+	var n int
+	var onFlush func(string)
 	s.withBufferLock(func() {
 		blen := s.buffer.Len()
-		if blen > 0 && blen+len(data)+1 >= s.flushBytes {
+		sep := s.sep()
+		if blen > 0 && blen+len(data)+len(sep) >= s.flushBytes {
 			s.swapnqueue()
+			s.stats.FullFlushes++
+			flushes[n] = flushFull
+			n++
 		}
 
 		s.buffer.Write(data)
-		s.buffer.WriteByte('\n')
+		s.buffer.Write(sep)
+		s.count++
+		s.stamp()
+		if s.idleTimer != nil {
+			s.idleTimer.Reset(s.idleFlush)
+		}
+
+		if s.buffer.Len() > s.stats.HighWater {
+			s.stats.HighWater = s.buffer.Len()
+		}
 
-		if s.buffer.Len() >= s.flushBytes {
+		switch {
+		case s.buffer.Len() >= s.flushBytes:
 			s.swapnqueue()
+			s.stats.FullFlushes++
+			flushes[n] = flushFull
+			n++
+		case s.flushCount > 0 && s.count >= s.flushCount:
+			s.swapnqueue()
+			s.stats.CountFlushes++
+			flushes[n] = flushCount
+			n++
 		}
+		onFlush = s.onFlush
 	})
 	s.runmx.RUnlock()
+
+	if onFlush != nil {
+		for _, reason := range flushes[:n] {
+			onFlush(reason)
+		}
+	}
 	return len(data), nil
 }
 
 // Close closes the Buffered Sender and cleans up.
 func (s *BufferedSender) Close() error {
+	if s.single {
+		return s.closeSingle()
+	}
+
 	// since we are running, write lock during cleanup
 	s.runmx.Lock()
 	defer s.runmx.Unlock()
@@ -72,10 +193,77 @@ func (s *BufferedSender) Close() error {
 
 	errChan := make(chan error)
 	s.running = false
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
 	s.shutdown <- errChan
 	return <-errChan
 }
 
+// Flush sends any buffered data right away, without waiting for the buffer
+// to fill or the flush interval to pass.
+func (s *BufferedSender) Flush() error {
+	if s.single {
+		return s.flushSingleExplicit()
+	}
+
+	s.runmx.RLock()
+	if !s.running {
+		s.runmx.RUnlock()
+		return fmt.Errorf("BufferedSender is not running")
+	}
+
+	var buf *bytes.Buffer
+	var stamps []bufStamp
+	var onFlush func(string)
+	s.withBufferLock(func() {
+		if s.buffer.Len() > 0 {
+			buf, stamps = s.buffer, s.stamps
+			s.buffer = senderPool.Get()
+			s.count = 0
+			s.stamps = nil
+			s.stats.ExplicitFlushes++
+		}
+		onFlush = s.onFlush
+	})
+	s.runmx.RUnlock()
+
+	if buf == nil {
+		return nil
+	}
+	s.expire(buf, stamps)
+	_, err := s.flush(buf)
+	senderPool.Put(buf)
+
+	if onFlush != nil {
+		onFlush(flushExplicit)
+	}
+	return err
+}
+
+// BufferStats returns a snapshot of the buffer fill and flush statistics.
+func (s *BufferedSender) BufferStats() BufferStats {
+	var stats BufferStats
+	s.withBufferLock(func() {
+		stats = s.stats
+		stats.Bytes = s.buffer.Len()
+	})
+	return stats
+}
+
+// Ping checks whether the server endpoint of the wrapped sender is
+// reachable. If the wrapped sender does not implement Pinger, an error is
+// returned.
+func (s *BufferedSender) Ping() error {
+	return ping(s.sender)
+}
+
+// BreakerState returns the state of any circuit breaker in the underlying
+// sender.
+func (s *BufferedSender) BreakerState() BreakerState {
+	return breakerState(s.sender)
+}
+
 // Start Buffered Sender
 // Begins ticker and read loop
 func (s *BufferedSender) Start() {
@@ -87,10 +275,191 @@ func (s *BufferedSender) Start() {
 	}
 
 	s.running = true
-	s.bufs = make(chan *bytes.Buffer, 32)
+	if s.single {
+		// flushing happens on send. the ticker only marks the interval as due
+		s.tickDone = make(chan struct{})
+		go s.tick()
+		return
+	}
+	s.bufs = make(chan stampedBuffer, 32)
+	if s.idleFlush > 0 {
+		s.idleTimer = time.AfterFunc(s.idleFlush, s.flushWhenIdle)
+	}
 	go s.run()
 }
 
+// flushWhenIdle queues the buffer for sending, once no sends have been
+// buffered for the idle flush duration.
+func (s *BufferedSender) flushWhenIdle() {
+	s.runmx.RLock()
+	if !s.running {
+		s.runmx.RUnlock()
+		return
+	}
+
+	var onFlush func(string)
+	s.withBufferLock(func() {
+		if s.swapnqueue() {
+			s.stats.IdleFlushes++
+			onFlush = s.onFlush
+		}
+	})
+	s.runmx.RUnlock()
+
+	if onFlush != nil {
+		onFlush(flushIdle)
+	}
+}
+
+// tick marks the flush interval as passed, for single writer mode
+func (s *BufferedSender) tick() {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.tickDone:
+			return
+		case <-ticker.C:
+			atomic.StoreInt32(&s.intervalDue, 1)
+		}
+	}
+}
+
+// sendSingle is Send for single writer mode. The buffer is used without
+// locking, and any flush (including one due to the flush interval having
+// passed) happens synchronously, before returning.
+func (s *BufferedSender) sendSingle(data []byte) (int, error) {
+	if !s.running {
+		return 0, fmt.Errorf("BufferedSender is not running")
+	}
+
+	var err error
+	blen := s.buffer.Len()
+	sep := s.sep()
+	if blen > 0 && blen+len(data)+len(sep) >= s.flushBytes {
+		s.stats.FullFlushes++
+		err = s.flushSingle(flushFull)
+	}
+
+	s.buffer.Write(data)
+	s.buffer.Write(sep)
+	s.count++
+	s.stamp()
+
+	if s.buffer.Len() > s.stats.HighWater {
+		s.stats.HighWater = s.buffer.Len()
+	}
+
+	var ferr error
+	switch {
+	case s.buffer.Len() >= s.flushBytes:
+		s.stats.FullFlushes++
+		ferr = s.flushSingle(flushFull)
+	case s.flushCount > 0 && s.count >= s.flushCount:
+		s.stats.CountFlushes++
+		ferr = s.flushSingle(flushCount)
+	case atomic.LoadInt32(&s.intervalDue) != 0:
+		s.stats.IntervalFlushes++
+		ferr = s.flushSingle(flushInterval)
+	}
+	if err == nil {
+		err = ferr
+	}
+	return len(data), err
+}
+
+// flushSingle sends the buffer synchronously, in single writer mode.
+func (s *BufferedSender) flushSingle(reason string) error {
+	s.expire(s.buffer, s.stamps)
+	_, err := s.flush(s.buffer)
+	s.count = 0
+	s.stamps = nil
+	atomic.StoreInt32(&s.intervalDue, 0)
+
+	if s.onFlush != nil && reason != flushClose {
+		s.onFlush(reason)
+	}
+	return err
+}
+
+// flushSingleExplicit is Flush for single writer mode.
+func (s *BufferedSender) flushSingleExplicit() error {
+	if !s.running {
+		return fmt.Errorf("BufferedSender is not running")
+	}
+	if s.buffer.Len() == 0 {
+		return nil
+	}
+	s.stats.ExplicitFlushes++
+	return s.flushSingle(flushExplicit)
+}
+
+// closeSingle is Close for single writer mode.
+func (s *BufferedSender) closeSingle() error {
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+	close(s.tickDone)
+	if s.buffer.Len() > 0 {
+		s.stats.CloseFlushes++
+		s.flushSingle(flushClose)
+	}
+	return s.sender.Close()
+}
+
+// sendNoFlush buffers data without checking whether a flush is due, so that
+// stats about flushing can not themselves trigger flushes. Any excess is
+// split off by flush as usual. The data still counts towards flushCount, so
+// the next send flushes as if it had been sent as usual.
+//
+// It is called from the run loop, so must not take runmx, which Close holds
+// while waiting on the run loop. Data buffered once closed is never sent.
+func (s *BufferedSender) sendNoFlush(data []byte) (int, error) {
+	s.withBufferLock(func() {
+		s.buffer.Write(data)
+		s.buffer.Write(s.sep())
+		s.count++
+		s.stamp()
+		if s.buffer.Len() > s.stats.HighWater {
+			s.stats.HighWater = s.buffer.Len()
+		}
+	})
+	return len(data), nil
+}
+
+// noFlushSender sends to a BufferedSender without triggering flushes.
+type noFlushSender struct {
+	bs *BufferedSender
+}
+
+func (s noFlushSender) Send(data []byte) (int, error) {
+	return s.bs.sendNoFlush(data)
+}
+
+// Close is a noop. The BufferedSender is closed by its owner.
+func (s noFlushSender) Close() error {
+	return nil
+}
+
+// setOnFlush sets the func called with the reason after each flush (except
+// when closing).
+func (s *BufferedSender) setOnFlush(fn func(reason string)) {
+	s.withBufferLock(func() {
+		s.onFlush = fn
+	})
+}
+
+// separator between buffered stats
+func (s *BufferedSender) sep() []byte {
+	if s.separator == nil {
+		return defaultSeparator
+	}
+	return s.separator
+}
+
 func (s *BufferedSender) withBufferLock(fn func()) {
 	// Note: use manual unlocking instead of defer unlocking,
 	// due to the overhead of defers in this hot code path.
@@ -104,14 +473,59 @@ func (s *BufferedSender) withBufferLock(fn func()) {
 	s.bufmx.Unlock()
 }
 
-func (s *BufferedSender) swapnqueue() {
+// swap out the current buffer and queue it for sending, if it holds
+// anything. reports whether a buffer was queued.
+func (s *BufferedSender) swapnqueue() bool {
 	if s.buffer.Len() == 0 {
-		return
+		return false
 	}
 	ob := s.buffer
 	nb := senderPool.Get()
 	s.buffer = nb
-	s.bufs <- ob
+	s.count = 0
+	s.bufs <- stampedBuffer{ob, s.stamps}
+	s.stamps = nil
+	return true
+}
+
+// stamp records the time the send just buffered was buffered, if sends can
+// expire. must be called with bufmx held.
+func (s *BufferedSender) stamp() {
+	if s.maxAge > 0 {
+		s.stamps = append(s.stamps, bufStamp{s.buffer.Len(), s.now()})
+	}
+}
+
+// expire removes the sends buffered for longer than maxAge from b, counting
+// them as expired.
+func (s *BufferedSender) expire(b *bytes.Buffer, stamps []bufStamp) {
+	if s.maxAge <= 0 || len(stamps) == 0 {
+		return
+	}
+
+	cutoff := s.now().Add(-s.maxAge)
+	if !stamps[0].at.Before(cutoff) {
+		// stamps are in order, so nothing has expired
+		return
+	}
+
+	// compact the live sends in place
+	data := b.Bytes()
+	var start, kept int
+	var expired int64
+	for _, st := range stamps {
+		if st.at.Before(cutoff) {
+			expired++
+		} else {
+			kept += copy(data[kept:], data[start:st.end])
+		}
+		start = st.end
+	}
+	b.Truncate(kept)
+
+	s.withBufferLock(func() {
+		s.stats.Expired += expired
+	})
 }
 
 func (s *BufferedSender) run() {
@@ -120,9 +534,10 @@ func (s *BufferedSender) run() {
 
 	doneChan := make(chan bool)
 	go func() {
-		for buf := range s.bufs {
-			s.flush(buf)
-			senderPool.Put(buf)
+		for sb := range s.bufs {
+			s.expire(sb.buf, sb.stamps)
+			s.flush(sb.buf)
+			senderPool.Put(sb.buf)
 		}
 		doneChan <- true
 	}()
@@ -130,12 +545,22 @@ func (s *BufferedSender) run() {
 	for {
 		select {
 		case <-ticker.C:
+			var onFlush func(string)
 			s.withBufferLock(func() {
-				s.swapnqueue()
+				if s.swapnqueue() {
+					s.stats.IntervalFlushes++
+					onFlush = s.onFlush
+				}
 			})
+			if onFlush != nil {
+				onFlush(flushInterval)
+			}
 		case errChan := <-s.shutdown:
+			// no onFlush for close flushes, as nothing more can be sent
 			s.withBufferLock(func() {
-				s.swapnqueue()
+				if s.swapnqueue() {
+					s.stats.CloseFlushes++
+				}
 			})
 			close(s.bufs)
 			<-doneChan
@@ -145,17 +570,86 @@ func (s *BufferedSender) run() {
 	}
 }
 
-// send to remove endpoint and truncate buffer
+// send to remote endpoint and truncate buffer.
+// If the buffer has grown larger than flushBytes, it is split on stat
+// boundaries and sent as multiple packets, so that no single write exceeds
+// the packet size limit. A single stat is never split, even if it alone is
+// larger than flushBytes.
+//
+// Note that stat boundaries can only be found when there is a separator, so
+// with an empty separator the buffer is always sent as-is.
 func (s *BufferedSender) flush(b *bytes.Buffer) (int, error) {
-	bb := b.Bytes()
-	bbl := len(bb)
-	if bb[bbl-1] == '\n' {
-		bb = bb[:bbl-1]
+	sep := s.sep()
+	bb := bytes.TrimSuffix(b.Bytes(), sep)
+
+	if s.sortStats && len(sep) > 0 {
+		sorted := senderPool.Get()
+		defer senderPool.Put(sorted)
+		sortStats(sorted, bb, sep)
+		bb = sorted.Bytes()
+	}
+
+	var total int
+	var ferr error
+	for len(bb) > 0 {
+		packet := bb
+		if len(packet) > s.flushBytes && len(sep) > 0 {
+			// split at the last stat boundary that fits, or failing that
+			// (a single oversized stat), at the end of the first stat.
+			limit := s.flushBytes + len(sep)
+			if limit > len(packet) {
+				limit = len(packet)
+			}
+			cut := bytes.LastIndex(packet[:limit], sep)
+			if cut == -1 {
+				cut = bytes.Index(packet, sep)
+			}
+			if cut != -1 {
+				packet = packet[:cut]
+			}
+		}
+
+		n, err := s.sender.Send(packet)
+		total += n
+		// keep sending the rest, but report the first error seen
+		if err != nil && ferr == nil {
+			ferr = err
+		}
+
+		bb = bb[len(packet):]
+		if len(bb) > 0 {
+			// drop the separator between packets
+			bb = bb[len(sep):]
+		}
 	}
-	//n, err := s.sender.Send(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
-	n, err := s.sender.Send(bb)
+
 	b.Truncate(0) // clear the buffer
-	return n, err
+	return total, ferr
+}
+
+// sortStats writes the sep separated stats in data to buf, sorted by stat
+// name.
+func sortStats(buf *bytes.Buffer, data []byte, sep []byte) {
+	stats := bytes.Split(data, sep)
+	// sort by name only, keeping stats with the same name in the order they
+	// were sent, as eg. reordering gauge updates would change the result
+	sort.SliceStable(stats, func(i, j int) bool {
+		return bytes.Compare(statName(stats[i]), statName(stats[j])) < 0
+	})
+	for i, stat := range stats {
+		if i > 0 {
+			buf.Write(sep)
+		}
+		buf.Write(stat)
+	}
+}
+
+// statName returns the name part of a formatted stat, up to the first ':'.
+func statName(stat []byte) []byte {
+	if i := bytes.IndexByte(stat, ':'); i >= 0 {
+		return stat[:i]
+	}
+	return stat
 }
 
 // NewBufferedSender returns a new BufferedSender
@@ -195,14 +689,20 @@ func NewBufferedSenderWithSender(sender Sender, flushInterval time.Duration, flu
 		return nil, fmt.Errorf("sender may not be nil")
 	}
 
-	bufSender := &BufferedSender{
+	bufSender := newBufferedSenderWithSender(sender, flushInterval, flushBytes)
+	bufSender.Start()
+	return bufSender, nil
+}
+
+// newBufferedSenderWithSender returns a new BufferedSender, that has not yet
+// been started.
+func newBufferedSenderWithSender(sender Sender, flushInterval time.Duration, flushBytes int) *BufferedSender {
+	return &BufferedSender{
 		flushBytes:    flushBytes,
 		flushInterval: flushInterval,
 		sender:        sender,
 		buffer:        senderPool.Get(),
 		shutdown:      make(chan chan error),
+		now:           time.Now,
 	}
-
-	bufSender.Start()
-	return bufSender, nil
 }
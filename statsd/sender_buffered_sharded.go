@@ -0,0 +1,112 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// shardedBufferedSender spreads stats over several BufferedSenders (see
+// ClientConfig.BufferShards), each with its own lock, buffer and flushes, to
+// cut lock contention when many goroutines send at once. The shards share
+// the underlying sender, which is closed once all shards have been.
+//
+// Stats sent from the same goroutine usually go to the same shard, but
+// stats in different shards are sent in no particular order.
+type shardedBufferedSender struct {
+	shards []*BufferedSender
+	sender Sender
+	// shard indexes, pooled for affinity: sync.Pool keeps a private item
+	// per P, so goroutines running on the same P mostly use the same shard,
+	// and goroutines running in parallel mostly use different ones.
+	pick sync.Pool
+	next uint32
+}
+
+func newShardedBufferedSender(sender Sender, shards []*BufferedSender) *shardedBufferedSender {
+	s := &shardedBufferedSender{shards: shards, sender: sender}
+	s.pick.New = func() interface{} {
+		i := int(atomic.AddUint32(&s.next, 1)-1) % len(s.shards)
+		return &i
+	}
+	return s
+}
+
+// Send buffers data in one of the shards.
+func (s *shardedBufferedSender) Send(data []byte) (int, error) {
+	i := s.pick.Get().(*int)
+	n, err := s.shards[*i].Send(data)
+	s.pick.Put(i)
+	return n, err
+}
+
+// Flush flushes every shard, returning the first error.
+func (s *shardedBufferedSender) Flush() error {
+	var err error
+	for _, shard := range s.shards {
+		if ferr := shard.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// Close flushes and closes every shard, then closes the underlying sender.
+func (s *shardedBufferedSender) Close() error {
+	var err error
+	for _, shard := range s.shards {
+		if cerr := shard.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	if cerr := s.sender.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Ping pings the underlying sender.
+func (s *shardedBufferedSender) Ping() error {
+	return ping(s.sender)
+}
+
+// BreakerState returns the state of any circuit breaker in the underlying
+// sender.
+func (s *shardedBufferedSender) BreakerState() BreakerState {
+	return breakerState(s.sender)
+}
+
+// BufferStats returns the buffer stats summed over the shards, apart from
+// HighWater, which is the highest of any shard.
+func (s *shardedBufferedSender) BufferStats() BufferStats {
+	var stats BufferStats
+	for _, shard := range s.shards {
+		ss := shard.BufferStats()
+		stats.Bytes += ss.Bytes
+		if ss.HighWater > stats.HighWater {
+			stats.HighWater = ss.HighWater
+		}
+		stats.FullFlushes += ss.FullFlushes
+		stats.IntervalFlushes += ss.IntervalFlushes
+		stats.CountFlushes += ss.CountFlushes
+		stats.ExplicitFlushes += ss.ExplicitFlushes
+		stats.CloseFlushes += ss.CloseFlushes
+		stats.IdleFlushes += ss.IdleFlushes
+		stats.Expired += ss.Expired
+	}
+	return stats
+}
+
+// bufferedShards returns the BufferedSenders buffering for sender, if any.
+func bufferedShards(sender Sender) []*BufferedSender {
+	switch s := sender.(type) {
+	case *BufferedSender:
+		return []*BufferedSender{s}
+	case *shardedBufferedSender:
+		return s.shards
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBufferShards(t *testing.T) {
+	const goroutines, perGoroutine = 8, 1000
+
+	rs := &recordingSender{}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:        rs,
+		UseBuffered:   true,
+		FlushInterval: time.Hour,
+		BufferShards:  4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.(*Client).sender.(*shardedBufferedSender).shards); n != 4 {
+		t.Fatalf("expected 4 shards, got %d", n)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				c.Inc(fmt.Sprintf("count.%d", g), 1, 1.0)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every stat is sent, from whichever shard buffered it
+	counts := make(map[string]int)
+	for _, packet := range rs.sent() {
+		for _, line := range strings.Split(strings.TrimSuffix(packet, "\n"), "\n") {
+			counts[line]++
+		}
+	}
+	for g := 0; g < goroutines; g++ {
+		line := fmt.Sprintf("count.%d:1|c", g)
+		if counts[line] != perGoroutine {
+			t.Errorf("%s: got %d expected %d", line, counts[line], perGoroutine)
+		}
+	}
+}
+
+func TestBufferShardsStats(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:        rs,
+		UseBuffered:   true,
+		FlushInterval: time.Hour,
+		BufferShards:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	client := c.(*Client)
+	sharded := client.sender.(*shardedBufferedSender)
+	for _, shard := range sharded.shards {
+		shard.Send([]byte("count:1|c"))
+	}
+
+	stats := client.BufferStats()
+	if stats.Bytes != 20 || stats.HighWater != 10 {
+		t.Fatalf("unexpected buffer stats %+v", stats)
+	}
+
+	client.Flush()
+	if stats := client.BufferStats(); stats.Bytes != 0 || stats.ExplicitFlushes != 2 {
+		t.Fatalf("unexpected buffer stats after flush %+v", stats)
+	}
+}
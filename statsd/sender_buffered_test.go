@@ -6,6 +6,13 @@ package statsd
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -114,3 +121,579 @@ func TestCloseDuringSendConcurrent(t *testing.T) {
 		t.Errorf("expected close to have been called once, but got %d", mockSender.closeCallCount)
 	}
 }
+
+func TestFlushSplitsOversizedBuffer(t *testing.T) {
+	rs := &recordingSender{}
+	sender := &BufferedSender{
+		flushBytes: 64,
+		sender:     rs,
+	}
+
+	var stats []string
+	buf := &bytes.Buffer{}
+	for i := 0; i < 50; i++ {
+		stat := fmt.Sprintf("test.count%d:%d|c", i, i)
+		stats = append(stats, stat)
+		buf.WriteString(stat)
+		buf.WriteByte('\n')
+	}
+	// a single stat larger than flushBytes goes out on its own
+	big := "test.big:" + strings.Repeat("x", 100) + "|s"
+	stats = append(stats, big)
+	buf.WriteString(big)
+	buf.WriteByte('\n')
+
+	_, err := sender.flush(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("expected buffer to be cleared")
+	}
+
+	packets := rs.sent()
+	if len(packets) < 2 {
+		t.Fatalf("expected multiple packets, got %d", len(packets))
+	}
+	var got []string
+	for _, p := range packets {
+		if len(p) > sender.flushBytes && p != big {
+			t.Fatalf("packet larger than flushBytes: '%s'", p)
+		}
+		got = append(got, strings.Split(p, "\n")...)
+	}
+	if strings.Join(got, "\n") != strings.Join(stats, "\n") {
+		t.Fatalf("got %q expected %q", got, stats)
+	}
+}
+
+func TestBufferedSenderManyStats(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := NewBufferedSenderWithSender(rs, 10*time.Second, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats []string
+	for i := 0; i < 50; i++ {
+		stat := fmt.Sprintf("test.count%d:%d|c", i, i)
+		stats = append(stats, stat)
+		if _, err := sender.Send([]byte(stat)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sender.Close()
+
+	packets := rs.sent()
+	if len(packets) < 2 {
+		t.Fatalf("expected multiple packets, got %d", len(packets))
+	}
+	var got []string
+	for _, p := range packets {
+		if len(p) > 64 {
+			t.Fatalf("packet larger than flushBytes: '%s'", p)
+		}
+		got = append(got, strings.Split(p, "\n")...)
+	}
+	if strings.Join(got, "\n") != strings.Join(stats, "\n") {
+		t.Fatalf("got %q expected %q", got, stats)
+	}
+}
+
+func TestBufferStats(t *testing.T) {
+	rs := &recordingSender{}
+	// interval long enough to only flush on a full buffer
+	sender, err := NewBufferedSenderWithSender(rs, time.Hour, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+
+	bs.Send([]byte("test.count:1|c"))
+	stats := bs.BufferStats()
+	if stats.Bytes != 15 || stats.HighWater != 15 || stats.FullFlushes != 0 {
+		t.Fatalf("unexpected stats after one send: %+v", stats)
+	}
+
+	// does not fit alongside the first stat, so flushes it
+	bs.Send([]byte("test.count:22|c"))
+	stats = bs.BufferStats()
+	if stats.Bytes != 16 || stats.HighWater != 16 || stats.FullFlushes != 1 {
+		t.Fatalf("unexpected stats after full flush: %+v", stats)
+	}
+
+	// fills the buffer past flushBytes by itself, so flushes straight away
+	bs.Send([]byte("test.really.long.name.count:1|c"))
+	stats = bs.BufferStats()
+	if stats.Bytes != 0 || stats.HighWater != 32 || stats.FullFlushes != 3 || stats.IntervalFlushes != 0 {
+		t.Fatalf("unexpected stats after oversized send: %+v", stats)
+	}
+	bs.Close()
+}
+
+func TestBufferStatsInterval(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := NewBufferedSenderWithSender(rs, 5*time.Millisecond, 1432)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+	defer bs.Close()
+
+	bs.Send([]byte("test.count:1|c"))
+	deadline := time.Now().Add(time.Second)
+	for bs.BufferStats().IntervalFlushes == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for interval flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := bs.BufferStats()
+	// empty buffers are not flushed, so are not counted
+	if stats.IntervalFlushes != 1 || stats.FullFlushes != 0 || stats.Bytes != 0 {
+		t.Fatalf("unexpected stats after interval flush: %+v", stats)
+	}
+
+	c, err := NewClientWithSender(bs, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.(*Client).BufferStats(); got != stats {
+		t.Fatalf("client stats %+v differ from sender stats %+v", got, stats)
+	}
+}
+
+func TestBufferSeparator(t *testing.T) {
+	tests := []struct {
+		Separator []byte
+		Expected  []string
+	}{
+		{nil, []string{"test.count:1|c\ntest.gauge:2|g", "test.set:a|s"}},
+		{[]byte("\n"), []string{"test.count:1|c\ntest.gauge:2|g", "test.set:a|s"}},
+		{[]byte{}, []string{"test.count:1|ctest.gauge:2|g", "test.set:a|s"}},
+		{[]byte("\r\n"), []string{"test.count:1|c\r\ntest.gauge:2|g", "test.set:a|s"}},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		sender, err := newBufferedSender(rs, &ClientConfig{
+			FlushInterval:   time.Hour,
+			BufferSeparator: tt.Separator,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewClientWithSender(sender, "test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Inc("count", 1, 1.0)
+		c.Gauge("gauge", 2, 1.0)
+		c.(*Client).Flush()
+		// a lone stat gets no trailing separator
+		c.Set("set", "a", 1.0)
+		c.Close()
+
+		if got := rs.sent(); !reflect.DeepEqual(got, tt.Expected) {
+			t.Errorf("separator %q: got %q expected %q", tt.Separator, got, tt.Expected)
+		}
+	}
+}
+
+func TestFlushSplitsWithSeparator(t *testing.T) {
+	rs := &recordingSender{}
+	sender := &BufferedSender{
+		flushBytes: 20,
+		sender:     rs,
+		separator:  []byte("\r\n"),
+	}
+
+	buf := bytes.NewBufferString("test.a:1|c\r\ntest.b:1|c\r\ntest.c:1|c\r\n")
+	if _, err := sender.flush(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"test.a:1|c", "test.b:1|c", "test.c:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestBufferFlushCount(t *testing.T) {
+	rs := &recordingSender{}
+	// interval long enough to only flush on FlushCount
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: time.Hour,
+		FlushCount:    3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+	defer bs.Close()
+
+	bs.Send([]byte("test.count:1|c"))
+	bs.Send([]byte("test.count:2|c"))
+	if stats := bs.BufferStats(); stats.CountFlushes != 0 {
+		t.Fatalf("flushed before reaching FlushCount: %+v", stats)
+	}
+	bs.Send([]byte("test.count:3|c"))
+	bs.Send([]byte("test.count:4|c"))
+
+	stats := bs.BufferStats()
+	if stats.CountFlushes != 1 || stats.FullFlushes != 0 || stats.Bytes != 15 {
+		t.Fatalf("unexpected stats after FlushCount stats: %+v", stats)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(rs.sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expected := []string{"test.count:1|c\ntest.count:2|c\ntest.count:3|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestBufferIdleFlush(t *testing.T) {
+	rs := &recordingSender{}
+	// interval long enough to only flush when idle
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: time.Hour,
+		IdleFlush:     10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+	defer bs.Close()
+
+	bs.Send([]byte("test.count:1|c"))
+	bs.Send([]byte("test.count:2|c"))
+
+	deadline := time.Now().Add(time.Second)
+	for len(rs.sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for idle flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	expected := []string{"test.count:1|c\ntest.count:2|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if stats := bs.BufferStats(); stats.IdleFlushes != 1 || stats.IntervalFlushes != 0 {
+		t.Fatalf("unexpected stats after idle flush: %+v", stats)
+	}
+}
+
+func TestBufferSortStats(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: time.Hour,
+		SortBuffered:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClientWithSender(sender, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Inc("zeta", 1, 1.0)
+	c.Gauge("alpha", 2, 1.0)
+	c.Timing("mu", 3, 1.0)
+	c.Inc("alpha", 4, 1.0)
+	// same name stats keep their order, so the last gauge still wins
+	c.Gauge("beta", 5, 1.0)
+	c.Gauge("beta", 3, 1.0)
+	// and the reset still comes first
+	c.(*Client).GaugeDiff("delta", 1, 3, 1.0)
+	c.Close()
+
+	expected := []string{"test.alpha:2|g\ntest.alpha:4|c\ntest.beta:5|g\ntest.beta:3|g\ntest.delta:0|g\ntest.delta:-2|g\ntest.mu:3|ms\ntest.zeta:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestBufferFlushReasons(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: 5 * time.Millisecond,
+		FlushBytes:    30,
+		FlushCount:    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+
+	// full
+	bs.Send([]byte("test.really.long.name.count:1|c"))
+	// count
+	bs.Send([]byte("a:1|c"))
+	bs.Send([]byte("b:1|c"))
+	// explicit
+	bs.Send([]byte("c:1|c"))
+	bs.Flush()
+	// interval
+	bs.Send([]byte("d:1|c"))
+	deadline := time.Now().Add(time.Second)
+	for bs.BufferStats().IntervalFlushes == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for interval flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// close
+	bs.Send([]byte("e:1|c"))
+	bs.Close()
+
+	stats := bs.BufferStats()
+	if stats.FullFlushes != 1 || stats.CountFlushes != 1 || stats.ExplicitFlushes != 1 ||
+		stats.IntervalFlushes != 1 || stats.CloseFlushes != 1 {
+		t.Fatalf("unexpected flush counts: %+v", stats)
+	}
+}
+
+func TestBufferFlushStats(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:        rs,
+		Prefix:        "test",
+		UseBuffered:   true,
+		FlushInterval: time.Hour,
+		FlushCount:    1,
+		FlushStats:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with FlushCount 1, every stat flushes. the flush counters must not
+	// trigger flushes themselves, or they would cascade.
+	c.Inc("count", 1, 1.0)
+	c.Inc("count", 2, 1.0)
+	c.(*Client).Flush()
+	// waits for all queued flushes
+	c.Close()
+
+	// the explicit flush is sent synchronously, so may overtake the queued
+	// count flushes
+	expected := []string{
+		"test.count:1|c",
+		"test.statsd.flush:1|c|#reason:count",
+		"test.statsd.flush:1|c|#reason:count\ntest.count:2|c",
+		"test.statsd.flush:1|c|#reason:explicit",
+	}
+	got := rs.sent()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestBufferFlushStatsCount(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:        rs,
+		Prefix:        "test",
+		UseBuffered:   true,
+		FlushInterval: time.Hour,
+		FlushCount:    2,
+		FlushStats:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the flush counter counts towards FlushCount, so the next stat flushes
+	c.Inc("a", 1, 1.0)
+	c.Inc("b", 1, 1.0)
+	c.Inc("c", 1, 1.0)
+	// waits for all queued flushes
+	c.Close()
+
+	expected := []string{
+		"test.a:1|c\ntest.b:1|c",
+		"test.statsd.flush:1|c|#reason:count\ntest.c:1|c",
+		// sent on close
+		"test.statsd.flush:1|c|#reason:count",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestBufferMaxAge(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: time.Hour,
+		MaxBufferAge:  time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+	defer bs.Close()
+
+	// fake clock, to age the buffered stats
+	var mx sync.Mutex
+	now := time.Unix(1656581400, 0)
+	bs.withBufferLock(func() {
+		bs.now = func() time.Time {
+			mx.Lock()
+			defer mx.Unlock()
+			return now
+		}
+	})
+	advance := func(d time.Duration) {
+		mx.Lock()
+		now = now.Add(d)
+		mx.Unlock()
+	}
+
+	bs.Send([]byte("stale:1|c"))
+	advance(30 * time.Second)
+	bs.Send([]byte("stale:2|c"))
+	advance(31 * time.Second)
+	bs.Send([]byte("fresh:1|c"))
+
+	if err := bs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"stale:2|c\nfresh:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if stats := bs.BufferStats(); stats.Expired != 1 {
+		t.Fatalf("expected 1 expired stat, got %+v", stats)
+	}
+
+	// everything expired, so nothing is sent
+	bs.Send([]byte("stale:3|c"))
+	advance(2 * time.Minute)
+	bs.Flush()
+	if got := rs.sent(); len(got) != 1 {
+		t.Fatalf("expired stats were sent: %q", got)
+	}
+	if stats := bs.BufferStats(); stats.Expired != 2 {
+		t.Fatalf("expected 2 expired stats, got %+v", stats)
+	}
+}
+
+func TestBufferSingleWriter(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: time.Minute,
+		FlushBytes:    30,
+		SingleWriter:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := sender.(*BufferedSender)
+
+	c, err := NewClientWithSender(bs, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flushes happen synchronously, so are visible straight away
+	c.Inc("a", 1, 1.0)
+	c.Inc("b", 1, 1.0)
+	if got := rs.sent(); len(got) != 0 {
+		t.Fatalf("flushed before the buffer filled: %q", got)
+	}
+	c.Inc("long.name.to.fill", 1, 1.0)
+	expected := []string{"a:1|c\nb:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("full flush: got %q expected %q", got, expected)
+	}
+
+	// the interval is checked on send
+	atomic.StoreInt32(&bs.intervalDue, 1)
+	c.Inc("c", 1, 1.0)
+	expected = append(expected, "long.name.to.fill:1|c\nc:1|c")
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("interval flush: got %q expected %q", got, expected)
+	}
+
+	c.Inc("d", 1, 1.0)
+	c.(*Client).Flush()
+	c.Inc("e", 1, 1.0)
+	c.Close()
+	expected = append(expected, "d:1|c", "e:1|c")
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("explicit and close flushes: got %q expected %q", got, expected)
+	}
+	if !rs.closed {
+		t.Fatal("wrapped sender not closed")
+	}
+
+	stats := bs.BufferStats()
+	if stats.FullFlushes != 1 || stats.IntervalFlushes != 1 || stats.ExplicitFlushes != 1 || stats.CloseFlushes != 1 {
+		t.Fatalf("unexpected flush counts: %+v", stats)
+	}
+	if err := c.Inc("f", 1, 1.0); err == nil {
+		t.Fatal("expected an error sending after close")
+	}
+}
+
+func TestBufferSingleWriterRace(t *testing.T) {
+	rs := &recordingSender{}
+	sender, err := newBufferedSender(rs, &ClientConfig{
+		FlushInterval: time.Millisecond,
+		FlushBytes:    64,
+		SingleWriter:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newClientWithConfig(sender, &ClientConfig{FlushStats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	// sending, flushing and reading stats from one goroutine is safe, while
+	// the interval ticker runs on its own (run with -race)
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		client.Inc("count", 1, 1.0)
+		if i%100 == 0 {
+			client.Flush()
+			client.BufferStats()
+		}
+	}
+	client.Close()
+
+	if len(rs.sent()) == 0 {
+		t.Fatal("expected stats to be flushed")
+	}
+}
+
+func TestBufferSingleWriterRejectsBackground(t *testing.T) {
+	configs := []ClientConfig{
+		{FlushOnSignal: []os.Signal{os.Interrupt}},
+		{IdleFlush: time.Second},
+		{Aggregate: true},
+		{LocalCounters: true},
+	}
+	for _, config := range configs {
+		config.Address = "127.0.0.1:8125"
+		config.UseBuffered = true
+		config.SingleWriter = true
+		if _, err := NewClientWithConfig(&config); err != errSingleWriterBackground {
+			t.Errorf("%+v: expected errSingleWriterBackground, got %v", config, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpTimeout bounds each HTTPSender request, so a slow endpoint can not
+// block sending indefinitely.
+const httpTimeout = 5 * time.Second
+
+// defaultGzipMinBytes is the smallest body compressed, if gzip is enabled
+// without a threshold.
+const defaultGzipMinBytes = 1024
+
+// HTTPSender provides an http ingest interface, POSTing stats to a url as a
+// text/plain body, with multiple stats (as sent by a BufferedSender)
+// separated by newlines.
+type HTTPSender struct {
+	url    string
+	client *http.Client
+	// gzip compress bodies of at least gzipMin bytes. 0 means never.
+	gzipMin int
+}
+
+// Send POSTs the data to the endpoint. Any non-2xx response status is
+// returned as an error.
+func (s *HTTPSender) Send(data []byte) (int, error) {
+	body := data
+	compressed := false
+	if s.gzipMin > 0 && len(data) >= s.gzipMin {
+		buf := bufPool.Get()
+		defer bufPool.Put(buf)
+		zw := gzip.NewWriter(buf)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		body = buf.Bytes()
+		compressed = true
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("HTTPSender got response status %s", resp.Status)
+	}
+	return len(data), nil
+}
+
+// Close closes any idle connections to the endpoint.
+func (s *HTTPSender) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// NewHTTPSender returns a new HTTPSender for POSTing to the supplied url.
+//
+// rawurl is an absolute http or https url. eg. "https://host/ingest".
+func NewHTTPSender(rawurl string) (Sender, error) {
+	return newHTTPSender(rawurl)
+}
+
+func newHTTPSender(rawurl string) (*HTTPSender, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("HTTPSender requires an http or https url")
+	}
+
+	return &HTTPSender{
+		url:    rawurl,
+		client: &http.Client{Timeout: httpTimeout},
+	}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type httpRequest struct {
+	encoding string
+	body     string
+}
+
+// newHTTPIngest returns a test server recording the decoded request bodies.
+func newHTTPIngest(t *testing.T) (*httptest.Server, <-chan httpRequest) {
+	requests := make(chan httpRequest, 8)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		encoding := r.Header.Get("Content-Encoding")
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Error(err)
+		}
+		requests <- httpRequest{encoding, string(data)}
+	}))
+	return ts, requests
+}
+
+func TestHTTPSender(t *testing.T) {
+	ts, requests := newHTTPIngest(t)
+	defer ts.Close()
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Network: "http",
+		Address: ts.URL,
+		Prefix:  "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.encoding != "" || req.body != "test.count:1|c" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+}
+
+func TestHTTPSenderGzip(t *testing.T) {
+	ts, requests := newHTTPIngest(t)
+	defer ts.Close()
+
+	sender, err := newConfigHTTPSender(&ClientConfig{
+		Address:          ts.URL,
+		HTTPGzip:         true,
+		HTTPGzipMinBytes: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	// below the threshold, so sent as-is
+	small := "test.count:1|c"
+	if _, err := sender.Send([]byte(small)); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.encoding != "" || req.body != small {
+		t.Fatalf("unexpected small request %+v", req)
+	}
+
+	large := strings.Repeat("test.count:1|c\n", 10) + "test.count:1|c"
+	if _, err := sender.Send([]byte(large)); err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.encoding != "gzip" || req.body != large {
+		t.Fatalf("unexpected large request %+v", req)
+	}
+}
+
+func TestHTTPSenderErrorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	sender, err := NewHTTPSender(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	if _, err := sender.Send([]byte("test.count:1|c")); err == nil {
+		t.Fatal("expected an error for a non-2xx status")
+	}
+	if _, err := NewHTTPSender("udp://127.0.0.1:8125"); err == nil {
+		t.Fatal("expected an error for a non-http url")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// The Producer interface wraps a Kafka (or similar) producer, so that
+// KafkaSender does not depend on any particular client library. Produce may
+// keep key and value, as they are not reused.
+type Producer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// A KeyFunc returns the message partition key for a stat name.
+type KeyFunc func(stat []byte) []byte
+
+// KafkaSender produces stats as messages to a Kafka topic, with the statsd
+// wire format as the message payload. When wrapped by a BufferedSender, each
+// flushed batch becomes a message.
+//
+// If a KeyFunc is set, the stats in each batch are grouped by key, and each
+// group is produced as a message with that key, so that stats with the same
+// key (eg. the same name) stay in order on one partition. Otherwise, each
+// batch is produced as a single message, without a key.
+type KafkaSender struct {
+	producer  Producer
+	topic     string
+	key       KeyFunc
+	separator []byte
+}
+
+// Send produces data to the topic.
+func (s *KafkaSender) Send(data []byte) (int, error) {
+	if s.key == nil {
+		return len(data), s.producer.Produce(s.topic, nil, append([]byte(nil), data...))
+	}
+
+	sep := s.separator
+	if sep == nil {
+		sep = defaultSeparator
+	}
+
+	// group the stats by key, keeping the order of first appearance
+	var keys [][]byte
+	groups := make(map[string]*bytes.Buffer)
+	for len(data) > 0 {
+		// without a separator, the stats can't be told apart, so the
+		// batch is keyed by its first stat
+		stat := data
+		if i := bytes.Index(data, sep); len(sep) > 0 && i != -1 {
+			stat, data = data[:i], data[i+len(sep):]
+		} else {
+			data = nil
+		}
+
+		name := stat
+		if i := bytes.IndexByte(stat, ':'); i != -1 {
+			name = stat[:i]
+		}
+		key := s.key(name)
+
+		b, ok := groups[string(key)]
+		if !ok {
+			b = &bytes.Buffer{}
+			groups[string(key)] = b
+			// the key may alias data, which is reused once Send returns
+			keys = append(keys, append([]byte(nil), key...))
+		} else {
+			b.Write(sep)
+		}
+		b.Write(stat)
+	}
+
+	var total int
+	for _, key := range keys {
+		b := groups[string(key)]
+		if err := s.producer.Produce(s.topic, key, b.Bytes()); err != nil {
+			return total, err
+		}
+		total += b.Len()
+	}
+	return total, nil
+}
+
+// Close is a noop. The Producer is owned, and closed, by the caller.
+func (s *KafkaSender) Close() error {
+	return nil
+}
+
+// NewKafkaSender returns a new KafkaSender, producing to topic via producer.
+//
+// key is the KeyFunc used to partition stats by name. If nil, messages are
+// produced without a key.
+func NewKafkaSender(producer Producer, topic string, key KeyFunc) (Sender, error) {
+	return NewKafkaSenderWithSeparator(producer, topic, key, nil)
+}
+
+// NewKafkaSenderWithSeparator returns a new KafkaSender, as NewKafkaSender,
+// for batches with stats separated by separator. It must match the
+// ClientConfig.BufferSeparator of the clients using the sender. A nil
+// separator means the default, a newline.
+func NewKafkaSenderWithSeparator(producer Producer, topic string, key KeyFunc, separator []byte) (Sender, error) {
+	if producer == nil {
+		return nil, fmt.Errorf("producer may not be nil")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("topic may not be empty")
+	}
+
+	sender := &KafkaSender{
+		producer: producer,
+		topic:    topic,
+		key:      key,
+	}
+	if separator != nil {
+		sender.separator = append([]byte{}, separator...)
+	}
+	return sender, nil
+}
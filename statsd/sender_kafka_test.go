@@ -0,0 +1,139 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type kafkaMessage struct {
+	topic, key, value string
+}
+
+type fakeProducer struct {
+	mx       sync.Mutex
+	messages []kafkaMessage
+}
+
+func (p *fakeProducer) Produce(topic string, key, value []byte) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.messages = append(p.messages, kafkaMessage{topic, string(key), string(value)})
+	return nil
+}
+
+func (p *fakeProducer) produced() []kafkaMessage {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]kafkaMessage(nil), p.messages...)
+}
+
+func TestKafkaSender(t *testing.T) {
+	p := &fakeProducer{}
+	sender, err := NewKafkaSender(p, "metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:        sender,
+		Prefix:        "test",
+		UseBuffered:   true,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Inc("count", 1, 1.0)
+	c.Gauge("gauge", 2, 1.0)
+	c.Close()
+
+	expected := []kafkaMessage{{"metrics", "", "test.count:1|c\ntest.gauge:2|g"}}
+	if got := p.produced(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestKafkaSenderKey(t *testing.T) {
+	p := &fakeProducer{}
+	// key by stat name
+	sender, err := NewKafkaSender(p, "metrics", func(stat []byte) []byte { return stat })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender.Send([]byte("a:1|c\nb:2|g\na:3|c"))
+
+	expected := []kafkaMessage{
+		{"metrics", "a", "a:1|c\na:3|c"},
+		{"metrics", "b", "b:2|g"},
+	}
+	if got := p.produced(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	if _, err := NewKafkaSender(nil, "metrics", nil); err == nil {
+		t.Fatal("expected an error for a nil producer")
+	}
+}
+
+type retainingProducer struct {
+	keys [][]byte
+}
+
+func (p *retainingProducer) Produce(topic string, key, value []byte) error {
+	p.keys = append(p.keys, key)
+	return nil
+}
+
+func TestKafkaSenderKeyCopied(t *testing.T) {
+	p := &retainingProducer{}
+	sender, err := NewKafkaSender(p, "metrics", func(stat []byte) []byte { return stat })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("a:1|c\nb:2|g")
+	sender.Send(data)
+	// the caller reuses its buffer once Send returns
+	copy(data, "xxxxxxxxxxx")
+
+	if len(p.keys) != 2 || string(p.keys[0]) != "a" || string(p.keys[1]) != "b" {
+		t.Fatalf("expected the produced keys to be kept, got %q", p.keys)
+	}
+}
+
+func TestKafkaSenderSeparator(t *testing.T) {
+	p := &fakeProducer{}
+	sender, err := NewKafkaSenderWithSeparator(p, "metrics", func(stat []byte) []byte { return stat }, []byte{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:          sender,
+		UseBuffered:     true,
+		FlushInterval:   time.Hour,
+		BufferSeparator: []byte{0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Inc("a", 1, 1.0)
+	c.Gauge("b", 2, 1.0)
+	c.Inc("a", 3, 1.0)
+	c.Close()
+
+	expected := []kafkaMessage{
+		{"metrics", "a", "a:1|c\x00a:3|c"},
+		{"metrics", "b", "b:2|g"},
+	}
+	if got := p.produced(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
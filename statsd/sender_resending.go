@@ -0,0 +1,62 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// resendBackoff is the wait before the first resend of a failed send. Each
+// further resend waits resendBackoff longer than the last.
+var resendBackoff = time.Millisecond
+
+// resendingSender retries failed sends (see ClientConfig.SendRetries), for
+// networks where a send transiently fails (eg. EAGAIN or ENOBUFS from a udp
+// sendto) and an immediate retry succeeds. Sends that still fail after all
+// the retries are dropped and counted.
+type resendingSender struct {
+	Sender
+	retries  int
+	counters *clientCounters
+}
+
+// Send sends data via the underlying sender, retrying up to s.retries times
+// if it fails. A closed sender is never retried.
+func (s *resendingSender) Send(data []byte) (int, error) {
+	n, err := s.Sender.Send(data)
+	for i := 1; err != nil && i <= s.retries; i++ {
+		if fatalSendError(err) {
+			return n, err
+		}
+		time.Sleep(time.Duration(i) * resendBackoff)
+		n, err = s.Sender.Send(data)
+	}
+	if err != nil && !fatalSendError(err) {
+		atomic.AddInt64(&s.counters.droppedStats, 1)
+	}
+	return n, err
+}
+
+// Ping pings the underlying sender, if it supports it.
+func (s *resendingSender) Ping() error {
+	return ping(s.Sender)
+}
+
+// Flush flushes the underlying sender, if it supports it.
+func (s *resendingSender) Flush() error {
+	if f, ok := s.Sender.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// fatalSendError reports whether a send error is permanent, so not worth
+// retrying.
+func fatalSendError(err error) bool {
+	return errors.Is(err, ErrClosed) || errors.Is(err, net.ErrClosed)
+}
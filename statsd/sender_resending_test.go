@@ -0,0 +1,98 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// flakySender fails its first few sends (up to fails) with err, then records
+// the rest
+type flakySender struct {
+	recordingSender
+	fails    int
+	err      error
+	attempts int
+}
+
+func (s *flakySender) Send(data []byte) (int, error) {
+	s.attempts++
+	if s.attempts <= s.fails {
+		return 0, s.err
+	}
+	return s.recordingSender.Send(data)
+}
+
+func TestSendRetries(t *testing.T) {
+	fs := &flakySender{fails: 1, err: syscall.ENOBUFS}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:      fs,
+		Prefix:      "test",
+		SendRetries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"test.count:1|c"}
+	if got := fs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if fs.attempts != 2 {
+		t.Fatalf("expected 2 send attempts, got %d", fs.attempts)
+	}
+	if dropped := c.(*Client).Stats().DroppedStats; dropped != 0 {
+		t.Fatalf("expected no dropped stats, got %d", dropped)
+	}
+}
+
+func TestSendRetriesExhausted(t *testing.T) {
+	fs := &flakySender{fails: 10, err: syscall.EAGAIN}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:      fs,
+		Prefix:      "test",
+		SendRetries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Inc("count", 1, 1.0); !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("expected EAGAIN, got %v", err)
+	}
+	if fs.attempts != 3 {
+		t.Fatalf("expected 3 send attempts, got %d", fs.attempts)
+	}
+	if dropped := c.(*Client).Stats().DroppedStats; dropped != 1 {
+		t.Fatalf("expected 1 dropped stat, got %d", dropped)
+	}
+}
+
+func TestSendRetriesClosed(t *testing.T) {
+	fs := &flakySender{fails: 10, err: ErrClosed}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:      fs,
+		Prefix:      "test",
+		SendRetries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// closed senders are not retried
+	if err := c.Inc("count", 1, 1.0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if fs.attempts != 1 {
+		t.Fatalf("expected 1 send attempt, got %d", fs.attempts)
+	}
+}
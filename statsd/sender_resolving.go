@@ -76,6 +76,20 @@ func (s *ResolvingSimpleSender) Close() error {
 	return err
 }
 
+// Ping checks whether the currently resolved server endpoint is reachable.
+// See SimpleSender.Ping for the caveats of checking a udp endpoint.
+func (s *ResolvingSimpleSender) Ping() error {
+	s.mx.RLock()
+	if !s.running {
+		s.mx.RUnlock()
+		return fmt.Errorf("ResolvingSimpleSender is not running")
+	}
+	addr := s.addrResolved
+	s.mx.RUnlock()
+
+	return pingUDP(addr)
+}
+
 func (s *ResolvingSimpleSender) Reconnect() {
 	// Note: use manual unlocking instead of defer unlocking.
 	// This is done here because we use a read lock first,
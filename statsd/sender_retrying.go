@@ -0,0 +1,244 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRetryInterval is the interval between initial dial attempts, if
+// ClientConfig.RetryInitialDial is set without a RetryInterval.
+const defaultRetryInterval = 5 * time.Second
+
+// defaultDisconnectedBufferBytes is the most data held for later delivery
+// with DisconnectedBuffer, if ClientConfig.DisconnectedBufferBytes is not set.
+const defaultDisconnectedBufferBytes = 64 * 1024
+
+// ErrNotConnected is returned for stats dropped because the server is not yet
+// reachable (see ClientConfig.RetryInitialDial and ClientConfig.Strict).
+var ErrNotConnected = errors.New("statsd server not yet reachable, stat dropped")
+
+// DisconnectedPolicy controls what happens to stats sent while the server is
+// not yet reachable (see ClientConfig.RetryInitialDial).
+type DisconnectedPolicy uint8
+
+const (
+	// DisconnectedError drops the stat, and returns an error from the send.
+	// This is the default.
+	DisconnectedError DisconnectedPolicy = iota
+	// DisconnectedDrop drops the stat, without an error.
+	DisconnectedDrop
+	// DisconnectedBuffer holds stats, up to ClientConfig.DisconnectedBufferBytes,
+	// and sends them once the server is reachable. Stats past the bound are
+	// dropped, without an error.
+	DisconnectedBuffer
+)
+
+// retryingSender stands in for a sender that could not be created (the
+// initial dial or resolution failed), retrying the creation in the
+// background until it succeeds. Until then, sends are handled according to
+// the DisconnectedPolicy, and dropped stats are counted.
+type retryingSender struct {
+	dial     func() (Sender, error)
+	interval time.Duration
+	policy   DisconnectedPolicy
+	counters *clientCounters
+	// lifecycle
+	mx       sync.RWMutex
+	sender   Sender
+	doneChan chan struct{}
+	running  bool
+	// data held for later delivery, with DisconnectedBuffer
+	held      [][]byte
+	heldBytes int
+	maxHeld   int
+	// returned for dropped stats, nil unless strict (see ClientConfig.Strict)
+	dropErr error
+}
+
+// Send sends data via the underlying sender, once it is available. Until
+// then, data is held or dropped according to the policy.
+//
+// Once the retryingSender is closed, Send returns ErrClosed.
+func (s *retryingSender) Send(data []byte) (int, error) {
+	s.mx.RLock()
+	sender, running := s.sender, s.running
+	s.mx.RUnlock()
+
+	if sender != nil {
+		return sender.Send(data)
+	}
+	if !running {
+		return 0, ErrClosed
+	}
+
+	switch s.policy {
+	case DisconnectedDrop:
+		atomic.AddInt64(&s.counters.droppedStats, 1)
+		return 0, s.dropErr
+	case DisconnectedBuffer:
+		return s.hold(data)
+	default:
+		atomic.AddInt64(&s.counters.droppedStats, 1)
+		return 0, ErrNotConnected
+	}
+}
+
+// hold keeps a copy of data for delivery once the underlying sender is
+// available, or drops it if the held data would exceed the bound.
+func (s *retryingSender) hold(data []byte) (int, error) {
+	s.mx.Lock()
+	// the sender may have become available in the meantime
+	if s.sender != nil {
+		sender := s.sender
+		s.mx.Unlock()
+		return sender.Send(data)
+	}
+	if !s.running {
+		s.mx.Unlock()
+		return 0, ErrClosed
+	}
+	if s.heldBytes+len(data) > s.maxHeld {
+		s.mx.Unlock()
+		atomic.AddInt64(&s.counters.droppedStats, 1)
+		return 0, s.dropErr
+	}
+
+	s.held = append(s.held, append([]byte(nil), data...))
+	s.heldBytes += len(data)
+	s.mx.Unlock()
+	return len(data), nil
+}
+
+// deliverHeld sends any held data via sender, in order. Must be called with
+// mx held, so the held data is sent before any later stats.
+func (s *retryingSender) deliverHeld(sender Sender) {
+	for _, data := range s.held {
+		if _, err := sender.Send(data); err != nil {
+			atomic.AddInt64(&s.counters.droppedStats, 1)
+		}
+	}
+	s.held = nil
+	s.heldBytes = 0
+}
+
+// Close stops retrying, and closes the underlying sender if there is one.
+func (s *retryingSender) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+	close(s.doneChan)
+
+	// held data can no longer be delivered
+	atomic.AddInt64(&s.counters.droppedStats, int64(len(s.held)))
+	s.held = nil
+	s.heldBytes = 0
+
+	if s.sender != nil {
+		return s.sender.Close()
+	}
+	return nil
+}
+
+// Flush flushes the underlying sender, if it is available and supports it.
+func (s *retryingSender) Flush() error {
+	if f, ok := s.current().(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Ping pings the underlying sender, or returns an error if it is not yet
+// available.
+func (s *retryingSender) Ping() error {
+	sender := s.current()
+	if sender == nil {
+		return ErrNotConnected
+	}
+	return ping(sender)
+}
+
+// BufferStats returns the buffer stats of the underlying sender, if it is
+// available and buffers.
+func (s *retryingSender) BufferStats() BufferStats {
+	if bs, ok := s.current().(interface{ BufferStats() BufferStats }); ok {
+		return bs.BufferStats()
+	}
+	return BufferStats{}
+}
+
+// BreakerState returns the state of any circuit breaker in the underlying
+// sender, once it is available.
+func (s *retryingSender) BreakerState() BreakerState {
+	return breakerState(s.current())
+}
+
+func (s *retryingSender) current() Sender {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.sender
+}
+
+func (s *retryingSender) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.doneChan:
+			return
+		case <-ticker.C:
+			sender, err := s.dial()
+			if err != nil {
+				continue
+			}
+
+			s.mx.Lock()
+			if !s.running {
+				// closed in the meantime
+				s.mx.Unlock()
+				sender.Close()
+				return
+			}
+			s.sender = sender
+			s.deliverHeld(sender)
+			s.mx.Unlock()
+			return
+		}
+	}
+}
+
+// newRetryingSender returns a retryingSender, that calls dial every interval
+// until it succeeds. Until then, sends are handled according to policy, with
+// up to maxHeld bytes held for DisconnectedBuffer. Dropped stats are counted
+// in counters.
+func newRetryingSender(dial func() (Sender, error), interval time.Duration, policy DisconnectedPolicy, maxHeld int, counters *clientCounters) *retryingSender {
+	if interval <= 0 {
+		interval = defaultRetryInterval
+	}
+	if maxHeld <= 0 {
+		maxHeld = defaultDisconnectedBufferBytes
+	}
+
+	s := &retryingSender{
+		dial:     dial,
+		interval: interval,
+		policy:   policy,
+		maxHeld:  maxHeld,
+		counters: counters,
+		doneChan: make(chan struct{}),
+		running:  true,
+	}
+	go s.run()
+	return s
+}
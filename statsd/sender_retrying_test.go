@@ -0,0 +1,198 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unusedTCPAddr returns a local tcp address nothing is listening on.
+func unusedTCPAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestRetryInitialDial(t *testing.T) {
+	addr := unusedTCPAddr(t)
+
+	config := &ClientConfig{
+		Address:          addr,
+		Network:          "tcp",
+		Prefix:           "test",
+		RetryInitialDial: true,
+		RetryInterval:    10 * time.Millisecond,
+	}
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatalf("expected a degraded client, got error: %s", err)
+	}
+	defer c.Close()
+
+	// degraded: stats are dropped and counted
+	if err := c.Inc("dropped", 1, 1.0); err == nil {
+		t.Fatal("expected an error sending before the server is reachable")
+	}
+	if n := c.(*Client).Stats().DroppedStats; n != 1 {
+		t.Fatalf("expected 1 dropped stat, got %d", n)
+	}
+
+	// endpoint becomes available
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := readStream(l)
+
+	deadline := time.Now().Add(time.Second)
+	for c.(*Client).Ping() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not upgrade once the server was reachable")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := c.Inc("sent", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	expected := []byte("test.sent:1|c\n")
+	if data := <-received; !bytes.Equal(data, expected) {
+		t.Fatalf("got %q expected %q", data, expected)
+	}
+}
+
+func TestRetryInitialDialDisabled(t *testing.T) {
+	_, err := NewClientWithConfig(&ClientConfig{
+		Address: unusedTCPAddr(t),
+		Network: "tcp",
+	})
+	if err == nil {
+		t.Fatal("expected an error without RetryInitialDial")
+	}
+}
+
+func TestRetryingSenderClose(t *testing.T) {
+	counters := &clientCounters{}
+	dialed := make(chan struct{}, 1)
+	s := newRetryingSender(func() (Sender, error) {
+		select {
+		case dialed <- struct{}{}:
+		default:
+		}
+		return nil, ErrNotConnected
+	}, time.Millisecond, DisconnectedError, 0, counters)
+
+	<-dialed
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Send([]byte("stat:1|c")); err != ErrClosed {
+		t.Fatalf("expected ErrClosed after close, got %v", err)
+	}
+	// stats sent after close are not counted as dropped
+	if dropped := counters.droppedStats; dropped != 0 {
+		t.Fatalf("expected no dropped stats, got %d", dropped)
+	}
+}
+
+// newDisconnectedSender returns a retryingSender that can not dial until
+// connect is called, then dials to rs.
+func newDisconnectedSender(t *testing.T, policy DisconnectedPolicy, maxHeld int, rs *recordingSender, counters *clientCounters) (s *retryingSender, connect func()) {
+	var reachable int32
+	s = newRetryingSender(func() (Sender, error) {
+		if atomic.LoadInt32(&reachable) == 0 {
+			return nil, ErrNotConnected
+		}
+		return rs, nil
+	}, time.Millisecond, policy, maxHeld, counters)
+
+	connect = func() {
+		atomic.StoreInt32(&reachable, 1)
+		deadline := time.Now().Add(time.Second)
+		for s.current() == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("sender did not connect")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return s, connect
+}
+
+func TestWhenDisconnected(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   DisconnectedPolicy
+		err      error
+		expected []string
+		dropped  int64
+	}{
+		{"error", DisconnectedError, ErrNotConnected, []string{"after:1|c"}, 2},
+		{"drop", DisconnectedDrop, nil, []string{"after:1|c"}, 2},
+		{"buffer", DisconnectedBuffer, nil, []string{"before:1|c", "before:2|c", "after:1|c"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := &recordingSender{}
+			counters := &clientCounters{}
+			s, connect := newDisconnectedSender(t, tt.policy, 0, rs, counters)
+			defer s.Close()
+
+			for _, data := range []string{"before:1|c", "before:2|c"} {
+				if _, err := s.Send([]byte(data)); err != tt.err {
+					t.Fatalf("expected error %v sending while disconnected, got %v", tt.err, err)
+				}
+			}
+
+			connect()
+			if _, err := s.Send([]byte("after:1|c")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rs.sent(); !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("got %q expected %q", got, tt.expected)
+			}
+			if n := atomic.LoadInt64(&counters.droppedStats); n != tt.dropped {
+				t.Fatalf("expected %d dropped stats, got %d", tt.dropped, n)
+			}
+		})
+	}
+}
+
+func TestWhenDisconnectedBufferBounded(t *testing.T) {
+	rs := &recordingSender{}
+	counters := &clientCounters{}
+	s, connect := newDisconnectedSender(t, DisconnectedBuffer, 20, rs, counters)
+	defer s.Close()
+
+	// 10 bytes each, so only the first two fit
+	for _, data := range []string{"held:11|c", "held:12|c", "held:13|c"} {
+		if _, err := s.Send([]byte(data + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt64(&counters.droppedStats); n != 1 {
+		t.Fatalf("expected 1 dropped stat past the bound, got %d", n)
+	}
+
+	connect()
+	expected := []string{"held:11|c\n", "held:12|c\n"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// shardReplicas is the number of points each node has on the hash ring.
+// More points spread stats more evenly between nodes.
+const shardReplicas = 100
+
+// A HashFunc hashes a stat name, for choosing a ShardingSender node.
+type HashFunc func([]byte) uint32
+
+// FNVHash is the default ShardingSender HashFunc, 32 bit FNV-1a.
+func FNVHash(data []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(data)
+	return h.Sum32()
+}
+
+type ringPoint struct {
+	hash uint32
+	node int
+}
+
+// ShardingSender spreads stats over multiple servers, by consistent hashing
+// of the stat name, so that the same stat is always sent to the same server.
+// This allows skipping a consistent hashing proxy in front of a statsd
+// cluster.
+//
+// If sending to a server fails, the stats are sent to the next server
+// instead, and so on, until a send succeeds or all servers have been tried.
+type ShardingSender struct {
+	senders []Sender
+	hash    HashFunc
+	ring    []ringPoint
+	// separates the stats in a batch, the default if nil
+	separator []byte
+}
+
+// Send sends each stat in data (multiple stats are separated by the
+// separator, a newline by default) to the server chosen for its name.
+func (s *ShardingSender) Send(data []byte) (int, error) {
+	sep := s.separator
+	if sep == nil {
+		sep = defaultSeparator
+	}
+
+	// batch stats up by node, to send as few packets as possible
+	batches := make([]*bytes.Buffer, len(s.senders))
+	defer func() {
+		for _, b := range batches {
+			if b != nil {
+				bufPool.Put(b)
+			}
+		}
+	}()
+
+	for len(data) > 0 {
+		// without a separator, the stats can't be told apart, so the
+		// batch goes to the server for its first stat
+		stat := data
+		if i := bytes.Index(data, sep); len(sep) > 0 && i != -1 {
+			stat, data = data[:i], data[i+len(sep):]
+		} else {
+			data = nil
+		}
+
+		node := s.node(stat)
+		b := batches[node]
+		if b == nil {
+			b = bufPool.Get()
+			batches[node] = b
+		} else {
+			b.Write(sep)
+		}
+		b.Write(stat)
+	}
+
+	var total int
+	var ferr error
+	for node, b := range batches {
+		if b == nil {
+			continue
+		}
+		n, err := s.sendFailover(node, b.Bytes())
+		total += n
+		if err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return total, ferr
+}
+
+// sendFailover sends data to node, falling back to the following nodes in
+// turn if sending fails.
+func (s *ShardingSender) sendFailover(node int, data []byte) (int, error) {
+	var ferr error
+	for i := 0; i < len(s.senders); i++ {
+		n, err := s.senders[(node+i)%len(s.senders)].Send(data)
+		if err == nil {
+			return n, nil
+		}
+		if ferr == nil {
+			ferr = err
+		}
+	}
+	return 0, ferr
+}
+
+// node returns the index of the node a stat should be sent to, by hashing
+// the stat name (everything before the ':').
+func (s *ShardingSender) node(stat []byte) int {
+	if i := bytes.IndexByte(stat, ':'); i != -1 {
+		stat = stat[:i]
+	}
+
+	h := s.hash(stat)
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i].hash >= h
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].node
+}
+
+// Close closes all the underlying senders.
+func (s *ShardingSender) Close() error {
+	var ferr error
+	for _, sender := range s.senders {
+		if err := sender.Close(); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return ferr
+}
+
+// NewShardingSender returns a new ShardingSender, sending to the supplied
+// addresses.
+//
+// addrs is a list of strings of the format "hostname:port", each of which
+// must be parsable by net.ResolveUDPAddr.
+//
+// hash is the HashFunc used to choose a server for each stat. If nil,
+// FNVHash is used.
+func NewShardingSender(addrs []string, hash HashFunc) (Sender, error) {
+	return NewShardingSenderWithSeparator(addrs, hash, nil)
+}
+
+// NewShardingSenderWithSeparator returns a new ShardingSender, as
+// NewShardingSender, for batches with stats separated by separator. It must
+// match the ClientConfig.BufferSeparator of the clients using the sender. A
+// nil separator means the default, a newline.
+func NewShardingSenderWithSeparator(addrs []string, hash HashFunc, separator []byte) (Sender, error) {
+	senders := make([]Sender, 0, len(addrs))
+	for _, addr := range addrs {
+		sender, err := NewSimpleSender(addr)
+		if err != nil {
+			for _, s := range senders {
+				s.Close()
+			}
+			return nil, err
+		}
+		senders = append(senders, sender)
+	}
+
+	sender, err := newShardingSender(addrs, senders, hash)
+	if err != nil {
+		return nil, err
+	}
+	if separator != nil {
+		sender.separator = append([]byte{}, separator...)
+	}
+	return sender, nil
+}
+
+// newShardingSender returns a new ShardingSender for the supplied senders.
+// names identify each sender on the hash ring, so must be stable for a given
+// sender, and unique.
+func newShardingSender(names []string, senders []Sender, hash HashFunc) (*ShardingSender, error) {
+	if len(senders) == 0 {
+		return nil, fmt.Errorf("ShardingSender requires at least one sender")
+	}
+
+	if hash == nil {
+		hash = FNVHash
+	}
+
+	ring := make([]ringPoint, 0, len(senders)*shardReplicas)
+	for node, name := range names {
+		for r := 0; r < shardReplicas; r++ {
+			h := hash([]byte(name + "-" + strconv.Itoa(r)))
+			ring = append(ring, ringPoint{h, node})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	return &ShardingSender{
+		senders: senders,
+		hash:    hash,
+		ring:    ring,
+	}, nil
+}
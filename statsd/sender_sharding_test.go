@@ -0,0 +1,151 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type failingSender struct{}
+
+func (failingSender) Send(data []byte) (int, error) { return 0, errors.New("send failed") }
+func (failingSender) Close() error                  { return nil }
+
+// nodeStats returns the stat names received by each recording sender.
+func nodeStats(senders []*recordingSender) [][]string {
+	result := make([][]string, len(senders))
+	for i, s := range senders {
+		for _, packet := range s.sent() {
+			for _, stat := range strings.Split(packet, "\n") {
+				result[i] = append(result[i], stat[:strings.IndexByte(stat, ':')])
+			}
+		}
+		sort.Strings(result[i])
+	}
+	return result
+}
+
+func newTestShardingSender(t *testing.T, n int) (*ShardingSender, []*recordingSender) {
+	names := make([]string, n)
+	recorders := make([]*recordingSender, n)
+	senders := make([]Sender, n)
+	for i := range senders {
+		names[i] = fmt.Sprintf("127.0.0.1:%d", 8125+i)
+		recorders[i] = &recordingSender{}
+		senders[i] = recorders[i]
+	}
+	s, err := newShardingSender(names, senders, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, recorders
+}
+
+func TestShardingSenderStableRouting(t *testing.T) {
+	s, recorders := newTestShardingSender(t, 3)
+
+	// send each stat individually, then all together in one packet, and
+	// check each stat lands on the same node both times.
+	var all []string
+	for i := 0; i < 50; i++ {
+		stat := fmt.Sprintf("stat%d:1|c", i)
+		all = append(all, stat)
+		if _, err := s.Send([]byte(stat)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	single := nodeStats(recorders)
+
+	s, recorders = newTestShardingSender(t, 3)
+	if _, err := s.Send([]byte(strings.Join(all, "\n"))); err != nil {
+		t.Fatal(err)
+	}
+	batched := nodeStats(recorders)
+
+	for i := range single {
+		if len(single[i]) == 0 {
+			t.Errorf("node %d received no stats", i)
+		}
+		if strings.Join(single[i], ",") != strings.Join(batched[i], ",") {
+			t.Errorf("node %d routing not stable: %v != %v", i, single[i], batched[i])
+		}
+		// batched stats are sent as one packet per node
+		if n := len(recorders[i].sent()); n != 1 {
+			t.Errorf("node %d: expected 1 packet, got %d", i, n)
+		}
+	}
+}
+
+func TestShardingSenderFailover(t *testing.T) {
+	s, recorders := newTestShardingSender(t, 3)
+
+	// find a stat that routes to node 1, then fail that node
+	var stat string
+	for i := 0; ; i++ {
+		stat = fmt.Sprintf("stat%d:1|c", i)
+		if s.node([]byte(stat)) == 1 {
+			break
+		}
+	}
+	s.senders[1] = failingSender{}
+
+	if _, err := s.Send([]byte(stat)); err != nil {
+		t.Fatal(err)
+	}
+	if sent := recorders[2].sent(); len(sent) != 1 || sent[0] != stat {
+		t.Fatalf("expected failover to next node, got %q", sent)
+	}
+
+	// all nodes failing reports an error
+	for i := range s.senders {
+		s.senders[i] = failingSender{}
+	}
+	if _, err := s.Send([]byte(stat)); err == nil {
+		t.Fatal("expected an error when all nodes fail")
+	}
+}
+
+func TestShardingSenderNoSenders(t *testing.T) {
+	if _, err := NewShardingSender(nil, nil); err == nil {
+		t.Fatal("expected an error for no addresses")
+	}
+}
+
+func TestShardingSenderSeparator(t *testing.T) {
+	s, recorders := newTestShardingSender(t, 3)
+	s.separator = []byte("\x00")
+
+	var all []string
+	for i := 0; i < 50; i++ {
+		all = append(all, fmt.Sprintf("stat%d:1|c", i))
+	}
+	if _, err := s.Send([]byte(strings.Join(all, "\x00"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// each node receives its stats, still separated by the separator
+	var got []string
+	for i, r := range recorders {
+		sent := r.sent()
+		if len(sent) != 1 {
+			t.Fatalf("node %d: expected 1 packet, got %q", i, sent)
+		}
+		for _, stat := range strings.Split(sent[0], "\x00") {
+			if s.node([]byte(stat)) != i {
+				t.Errorf("node %d received %q", i, stat)
+			}
+			got = append(got, stat)
+		}
+	}
+	sort.Strings(got)
+	sort.Strings(all)
+	if strings.Join(got, ",") != strings.Join(all, ",") {
+		t.Fatalf("got %q expected %q", got, all)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// sharedSender wraps a Sender shared between clients (see
+// ClientConfig.Sender), so that closing one client does not close the
+// sender out from under the others. The owner of the sender closes it.
+type sharedSender struct {
+	Sender
+}
+
+// Close is a noop. The shared sender is closed by its owner.
+func (s sharedSender) Close() error {
+	return nil
+}
+
+// Ping pings the shared sender, if it supports it.
+func (s sharedSender) Ping() error {
+	return ping(s.Sender)
+}
+
+// Flush flushes the shared sender, if it supports it.
+func (s sharedSender) Flush() error {
+	if f, ok := s.Sender.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// BufferStats returns the buffer stats of the shared sender, if it
+// buffers.
+func (s sharedSender) BufferStats() BufferStats {
+	if bs, ok := s.Sender.(interface{ BufferStats() BufferStats }); ok {
+		return bs.BufferStats()
+	}
+	return BufferStats{}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSharedSender(t *testing.T) {
+	rs := &recordingSender{}
+
+	c1, err := NewClientWithConfig(&ClientConfig{Sender: rs, Prefix: "plugin1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewClientWithConfig(&ClientConfig{
+		Sender: rs,
+		Prefix: "plugin2",
+		Tags:   []Tag{{"tag1", "val1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c1.Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c2.Inc("count", 2, 1.0); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing one client leaves the shared sender open for the other
+	c1.Close()
+	if rs.closed {
+		t.Fatal("closing a client closed the shared sender")
+	}
+	if err := c2.Inc("count", 3, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+
+	expected := []string{
+		"plugin1.count:1|c",
+		"plugin2.count:2|c|#tag1:val1",
+		"plugin2.count:3|c|#tag1:val1",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestSharedSenderBuffered(t *testing.T) {
+	rs := &recordingSender{}
+
+	c, err := NewClientWithConfig(&ClientConfig{Sender: rs, UseBuffered: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Inc("count", 1, 1.0)
+
+	// the client buffer is flushed on close, without closing the shared
+	// sender
+	c.Close()
+	if rs.closed {
+		t.Fatal("closing a buffered client closed the shared sender")
+	}
+	expected := []string{"count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultDialTimeout is the timeout for dialing a stream connection, if
+// ClientConfig.DialTimeout is not set.
+const defaultDialTimeout = 5 * time.Second
+
+// Framing controls how stats are delimited on a stream (tcp, unix)
+// connection, where there are no packet boundaries to separate them.
+type Framing uint8
+
+const (
+	// FramingNewline terminates each stat with a newline. This is the
+	// default, and is what the etsy statsd tcp server, and most other
+	// stream capable servers, expect.
+	FramingNewline Framing = iota
+	// FramingLengthPrefixed prefixes each stat with its length, as a 4 byte
+	// little-endian unsigned integer. This is what the DogStatsD agent
+	// expects on unix stream sockets.
+	FramingLengthPrefixed
+	// FramingNone writes stats back to back, with no delimiter at all, for
+	// relays that frame stats by some other means (eg. by length, from the
+	// stat lines themselves).
+	FramingNone
+)
+
+// StreamSender provides a stream socket (tcp, unix) send interface.
+// Stats are delimited according to the configured Framing.
+//
+// If a write fails, the connection is dropped, and re-dialed on the next
+// send.
+type StreamSender struct {
+	network string
+	addr    string
+	framing Framing
+	// separates the stats in a buffered send
+	separator []byte
+	timeout   time.Duration
+	// serializes writes, so framed stats are never interleaved
+	wmx sync.Mutex
+	// lifecycle, and connection. never held during a write, so that Close
+	// can interrupt a blocked one.
+	mx      sync.Mutex
+	conn    net.Conn
+	running bool
+}
+
+// Send sends the data to the server endpoint. data may hold multiple stats
+// separated by the buffer separator (as sent by a BufferedSender), in which
+// case each stat is framed individually.
+//
+// Once the StreamSender is closed, Send returns ErrClosed.
+func (s *StreamSender) Send(data []byte) (int, error) {
+	buf := bufPool.Get()
+	defer bufPool.Put(buf)
+	s.frame(buf, data)
+
+	s.wmx.Lock()
+	defer s.wmx.Unlock()
+
+	conn, err := s.connect()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = conn.Write(buf.Bytes())
+	if err != nil {
+		// connection is in an unknown state, so start afresh next time
+		s.mx.Lock()
+		running := s.running
+		if s.conn == conn {
+			s.conn = nil
+		}
+		s.mx.Unlock()
+		conn.Close()
+
+		if !running {
+			// interrupted by Close
+			return 0, ErrClosed
+		}
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// connect returns the current connection, dialing a new one if needed.
+func (s *StreamSender) connect() (net.Conn, error) {
+	s.mx.Lock()
+	running, conn := s.running, s.conn
+	s.mx.Unlock()
+
+	if !running {
+		return nil, ErrClosed
+	}
+	if conn != nil {
+		return conn, nil
+	}
+
+	conn, err := net.DialTimeout(s.network, s.addr, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.running {
+		// closed while dialing
+		conn.Close()
+		return nil, ErrClosed
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// frame writes data to buf with the configured framing applied
+func (s *StreamSender) frame(buf *bytes.Buffer, data []byte) {
+	switch s.framing {
+	case FramingLengthPrefixed, FramingNone:
+		var size [4]byte
+		for len(data) > 0 {
+			stat := data
+			if i := bytes.Index(data, s.separator); i != -1 && len(s.separator) > 0 {
+				stat, data = data[:i], data[i+len(s.separator):]
+			} else {
+				data = nil
+			}
+			if s.framing == FramingLengthPrefixed {
+				binary.LittleEndian.PutUint32(size[:], uint32(len(stat)))
+				buf.Write(size[:])
+			}
+			buf.Write(stat)
+		}
+	default:
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+}
+
+// Ping checks whether the server endpoint is reachable, by opening (and then
+// closing) a new connection to it.
+func (s *StreamSender) Ping() error {
+	conn, err := net.DialTimeout(s.network, s.addr, pingTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Close closes the StreamSender and cleans up. Closing the connection
+// interrupts any blocked Send, which then returns ErrClosed, so Close
+// returns promptly even if the server has stopped reading.
+func (s *StreamSender) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// NewStreamSender returns a new StreamSender for sending to the supplied
+// address.
+//
+// network is a stream network, as understood by net.Dial. eg. "tcp" or
+// "unix".
+//
+// addr is the address to dial. eg. "hostname:port" for tcp, or a socket path
+// for unix.
+//
+// framing controls how stats are delimited on the stream.
+//
+// Dialing, initially and after a failed write, times out after 5 seconds.
+func NewStreamSender(network, addr string, framing Framing) (Sender, error) {
+	return newStreamSender(network, addr, framing, nil, defaultDialTimeout)
+}
+
+// newStreamSender returns a new StreamSender, with buffered stats separated
+// by separator (a newline if nil, and never split if empty), and dials
+// timing out after timeout.
+func newStreamSender(network, addr string, framing Framing, separator []byte, timeout time.Duration) (*StreamSender, error) {
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	if separator == nil {
+		separator = defaultSeparator
+	}
+
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := &StreamSender{
+		network:   network,
+		addr:      addr,
+		framing:   framing,
+		separator: separator,
+		timeout:   timeout,
+		conn:      conn,
+		running:   true,
+	}
+	return sender, nil
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// readStream accepts a single connection on l, and returns everything read
+// from it until the connection is closed.
+func readStream(l net.Listener) <-chan []byte {
+	out := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			out <- nil
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		data, _ := io.ReadAll(conn)
+		out <- data
+	}()
+	return out
+}
+
+func TestStreamSenderFraming(t *testing.T) {
+	tests := []struct {
+		Framing  Framing
+		Expected []byte
+	}{
+		{FramingNewline, []byte("test.count:1|c\ntest.gauge:2|g\n")},
+		{FramingLengthPrefixed, []byte("\x0e\x00\x00\x00test.count:1|c\x0e\x00\x00\x00test.gauge:2|g")},
+		{FramingNone, []byte("test.count:1|ctest.gauge:2|g")},
+	}
+
+	for _, tt := range tests {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := readStream(l)
+
+		c, err := NewClientWithConfig(&ClientConfig{
+			Address: l.Addr().String(),
+			Network: "tcp",
+			Framing: tt.Framing,
+			Prefix:  "test",
+		})
+		if err != nil {
+			l.Close()
+			t.Fatal(err)
+		}
+		c.Inc("count", 1, 1.0)
+		c.Gauge("gauge", 2, 1.0)
+		c.Close()
+
+		data := <-received
+		l.Close()
+		if !bytes.Equal(data, tt.Expected) {
+			t.Fatalf("Framing %d: got %q expected %q", tt.Framing, data, tt.Expected)
+		}
+	}
+}
+
+func TestStreamSenderBufferedFraming(t *testing.T) {
+	tests := []struct {
+		Framing  Framing
+		Expected []byte
+	}{
+		{FramingLengthPrefixed, []byte("\x0e\x00\x00\x00test.count:1|c\x0e\x00\x00\x00test.gauge:2|g")},
+		// the separators are dropped, not written as part of the stats
+		{FramingNone, []byte("test.count:1|ctest.gauge:2|g")},
+	}
+
+	for _, tt := range tests {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := readStream(l)
+
+		c, err := NewClientWithConfig(&ClientConfig{
+			Address:     l.Addr().String(),
+			Network:     "tcp",
+			Framing:     tt.Framing,
+			Prefix:      "test",
+			UseBuffered: true,
+		})
+		if err != nil {
+			l.Close()
+			t.Fatal(err)
+		}
+		// both stats are flushed together on close, and framed individually
+		c.Inc("count", 1, 1.0)
+		c.Gauge("gauge", 2, 1.0)
+		c.Close()
+
+		data := <-received
+		l.Close()
+		if !bytes.Equal(data, tt.Expected) {
+			t.Fatalf("Framing %d: got %q expected %q", tt.Framing, data, tt.Expected)
+		}
+	}
+}
+
+func TestStreamSenderBufferSeparator(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := readStream(l)
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Address:         l.Addr().String(),
+		Network:         "tcp",
+		Framing:         FramingLengthPrefixed,
+		Prefix:          "test",
+		UseBuffered:     true,
+		BufferSeparator: []byte("\r\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// buffered stats are split on the configured separator
+	c.Inc("count", 1, 1.0)
+	c.Gauge("gauge", 2, 1.0)
+	c.Close()
+
+	expected := []byte("\x0e\x00\x00\x00test.count:1|c\x0e\x00\x00\x00test.gauge:2|g")
+	if data := <-received; !bytes.Equal(data, expected) {
+		t.Fatalf("got %q expected %q", data, expected)
+	}
+}
+
+func TestStreamSenderPing(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	sender, err := NewStreamSender("tcp", addr, FramingNewline)
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	if err := sender.(Pinger).Ping(); err != nil {
+		t.Errorf("expected reachable endpoint, got %v", err)
+	}
+
+	l.Close()
+	if err := sender.(Pinger).Ping(); err == nil {
+		t.Error("expected error for unreachable endpoint")
+	}
+}
+
+func TestStreamSenderCloseInterruptsSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// accept, but never read, so writes eventually block
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	s, err := NewStreamSender("tcp", l.Addr().String(), FramingNewline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := <-accepted
+	defer conn.Close()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		data := bytes.Repeat([]byte("x"), 1<<20)
+		for {
+			if _, err := s.Send(data); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+	}()
+
+	// give the sends time to fill the socket buffers and block
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.Close()
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on an in-flight send")
+	}
+
+	select {
+	case err := <-sendErr:
+		if err != ErrClosed {
+			t.Fatalf("expected ErrClosed from the interrupted send, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("send was not interrupted by Close")
+	}
+
+	if _, err := s.Send([]byte("stat:1|c")); err != ErrClosed {
+		t.Fatalf("expected ErrClosed after close, got %v", err)
+	}
+}
+
+func TestStreamSenderDialTimeout(t *testing.T) {
+	// a non-routable address (TEST-NET-1), so the dial hangs or fails
+	config := &ClientConfig{
+		Address:     "192.0.2.1:8125",
+		Network:     "tcp",
+		DialTimeout: 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	if _, err := NewClientWithConfig(config); err == nil {
+		t.Fatal("expected an error dialing an unreachable address")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the dial to time out after 100ms, took %s", elapsed)
+	}
+
+	// degraded rather than failing
+	config.RetryInitialDial = true
+	start = time.Now()
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the dial to time out after 100ms, took %s", elapsed)
+	}
+}
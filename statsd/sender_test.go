@@ -0,0 +1,81 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// closedUDPAddr returns the address of a udp port that nothing is
+// listening on.
+func closedUDPAddr(t *testing.T) string {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.LocalAddr().String()
+	l.Close()
+	return addr
+}
+
+func TestPing(t *testing.T) {
+	l, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, port, err := net.SplitHostPort(l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configs := map[string]*ClientConfig{
+		"simple":    {Address: l.LocalAddr().String()},
+		"buffered":  {Address: l.LocalAddr().String(), UseBuffered: true},
+		"resolving": {Address: net.JoinHostPort("localhost", port), ResInterval: time.Second},
+	}
+	for name, config := range configs {
+		c, err := NewClientWithConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.(*Client).Ping(); err != nil {
+			t.Errorf("%s: expected reachable endpoint, got %v", name, err)
+		}
+		c.Close()
+	}
+}
+
+func TestPingUnreachable(t *testing.T) {
+	addr := closedUDPAddr(t)
+	for _, buffered := range []bool{false, true} {
+		c, err := NewClientWithConfig(&ClientConfig{Address: addr, UseBuffered: buffered})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.(*Client).Ping(); err == nil {
+			t.Errorf("buffered=%t: expected error for unreachable endpoint", buffered)
+		}
+		c.Close()
+	}
+}
+
+func TestPingUnsupported(t *testing.T) {
+	c, err := NewClientWithSender(&recordingSender{}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(*Client).Ping(); err != errPingUnsupported {
+		t.Fatalf("expected errPingUnsupported, got %v", err)
+	}
+
+	var nilClient *Client
+	if err := nilClient.Ping(); err != nil {
+		t.Fatalf("expected nil client ping to be a noop, got %v", err)
+	}
+}
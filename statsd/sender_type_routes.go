@@ -0,0 +1,187 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"sort"
+)
+
+// typeRoutingSender sends each stat to the sender for its type (see
+// ClientConfig.TypeRoutes), or to the fallback sender if its type has no
+// route.
+type typeRoutingSender struct {
+	routes   map[string]Sender
+	fallback Sender
+	// every distinct sender, including the fallback, for Close and Flush
+	senders []Sender
+	// separates the stats in a batch (see ClientConfig.BufferSeparator)
+	separator []byte
+}
+
+// Send sends each stat in data (multiple stats are separated by the
+// separator) to the sender for its type.
+func (s *typeRoutingSender) Send(data []byte) (int, error) {
+	// fast path: a single stat, as sent by a Client. without a separator,
+	// the stats can't be told apart, so the batch goes to the sender for
+	// its first stat
+	sep := s.separator
+	if sep == nil {
+		sep = defaultSeparator
+	}
+	if len(sep) == 0 || bytes.Index(data, sep) == -1 {
+		return s.route(data).Send(data)
+	}
+
+	// batch stats up by sender, to send as few packets as possible
+	batches := make(map[Sender]*bytes.Buffer, len(s.senders))
+	defer func() {
+		for _, b := range batches {
+			bufPool.Put(b)
+		}
+	}()
+
+	var order []Sender
+	for len(data) > 0 {
+		stat := data
+		if i := bytes.Index(data, sep); i != -1 {
+			stat, data = data[:i], data[i+len(sep):]
+		} else {
+			data = nil
+		}
+
+		sender := s.route(stat)
+		b, ok := batches[sender]
+		if !ok {
+			b = bufPool.Get()
+			batches[sender] = b
+			order = append(order, sender)
+		} else {
+			b.Write(sep)
+		}
+		b.Write(stat)
+	}
+
+	var total int
+	var ferr error
+	for _, sender := range order {
+		n, err := sender.Send(batches[sender].Bytes())
+		total += n
+		if err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return total, ferr
+}
+
+// route returns the sender for a stat, by its type (the field after the
+// value, eg. "ms" for "name:1|ms|@0.5").
+func (s *typeRoutingSender) route(stat []byte) Sender {
+	i := bytes.IndexByte(stat, '|')
+	if i == -1 {
+		return s.fallback
+	}
+	statType := stat[i+1:]
+	if j := bytes.IndexByte(statType, '|'); j != -1 {
+		statType = statType[:j]
+	}
+
+	// the string conversion in a map index does not allocate
+	if sender, ok := s.routes[string(statType)]; ok {
+		return sender
+	}
+	return s.fallback
+}
+
+// Flush flushes every underlying sender that supports it. Returns the first
+// error.
+func (s *typeRoutingSender) Flush() error {
+	var ferr error
+	for _, sender := range s.senders {
+		if f, ok := sender.(Flusher); ok {
+			if err := f.Flush(); err != nil && ferr == nil {
+				ferr = err
+			}
+		}
+	}
+	return ferr
+}
+
+// Ping pings every underlying sender. Returns the first error.
+func (s *typeRoutingSender) Ping() error {
+	var ferr error
+	for _, sender := range s.senders {
+		if err := ping(sender); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return ferr
+}
+
+// Close closes all the underlying senders.
+func (s *typeRoutingSender) Close() error {
+	var ferr error
+	for _, sender := range s.senders {
+		if err := sender.Close(); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return ferr
+}
+
+// newTypeRoutingSender returns a typeRoutingSender for config.TypeRoutes,
+// with one sender per distinct address, each configured as for Address.
+// Stats without a route go to the sender for config itself. Any stats the
+// senders drop are counted in counters.
+func newTypeRoutingSender(config *ClientConfig, counters *clientCounters) (Sender, error) {
+	s := &typeRoutingSender{
+		routes:    make(map[string]Sender, len(config.TypeRoutes)),
+		separator: defaultSeparator,
+	}
+	if config.BufferSeparator != nil {
+		s.separator = append([]byte{}, config.BufferSeparator...)
+	}
+
+	fconfig := *config
+	fconfig.TypeRoutes = nil
+	fallback, err := newConfigSender(&fconfig, counters)
+	if err != nil {
+		return nil, err
+	}
+	s.fallback = fallback
+	s.senders = append(s.senders, fallback)
+
+	// sorted, so senders are created in a stable order
+	types := make([]string, 0, len(config.TypeRoutes))
+	for statType := range config.TypeRoutes {
+		types = append(types, statType)
+	}
+	sort.Strings(types)
+
+	byAddress := map[string]Sender{}
+	if config.Sender == nil && len(config.Addresses) == 0 {
+		// routes to the default address share its sender
+		byAddress[config.Address] = fallback
+	}
+	for _, statType := range types {
+		addr := config.TypeRoutes[statType]
+		sender, ok := byAddress[addr]
+		if !ok {
+			rconfig := fconfig
+			rconfig.Address = addr
+			rconfig.Sender = nil
+			rconfig.Addresses = nil
+			sender, err = newConfigSender(&rconfig, counters)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+			byAddress[addr] = sender
+			s.senders = append(s.senders, sender)
+		}
+		s.routes[statType] = sender
+	}
+	return s, nil
+}
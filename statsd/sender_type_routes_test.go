@@ -0,0 +1,111 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTypeRoutes(t *testing.T) {
+	timings, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer timings.Close()
+	others, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer others.Close()
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Address: others.LocalAddr().String(),
+		Prefix:  "test",
+		TypeRoutes: map[string]string{
+			TypeTiming:    timings.LocalAddr().String(),
+			TypeHistogram: timings.LocalAddr().String(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Timing("latency", 12, 1.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 128)
+	n, _, err := timings.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "test.latency:12|ms"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Errorf("timings got '%s' expected '%s'", data[:n], expected)
+	}
+
+	n, _, err = others.ReadFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "test.count:1|c"
+	if !bytes.Equal(data[:n], []byte(expected)) {
+		t.Errorf("others got '%s' expected '%s'", data[:n], expected)
+	}
+}
+
+func TestTypeRoutingSenderBatches(t *testing.T) {
+	timings, others := &recordingSender{}, &recordingSender{}
+	s := &typeRoutingSender{
+		routes:   map[string]Sender{TypeTiming: timings},
+		fallback: others,
+		senders:  []Sender{others, timings},
+	}
+
+	// as sent by a buffered sender
+	data := "a:1|c\nb:2|ms|@0.5\nc:3|g|#tag1:val1\nd:4|ms"
+	if _, err := s.Send([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"b:2|ms|@0.5\nd:4|ms"}
+	if got := timings.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("timings got %q expected %q", got, expected)
+	}
+	expected = []string{"a:1|c\nc:3|g|#tag1:val1"}
+	if got := others.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("others got %q expected %q", got, expected)
+	}
+}
+
+func TestTypeRoutingSenderSeparator(t *testing.T) {
+	timings, others := &recordingSender{}, &recordingSender{}
+	s := &typeRoutingSender{
+		routes:    map[string]Sender{TypeTiming: timings},
+		fallback:  others,
+		senders:   []Sender{others, timings},
+		separator: []byte("\x00"),
+	}
+
+	data := "a:1|c\x00b:2|ms\x00c:3|g"
+	if _, err := s.Send([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"b:2|ms"}
+	if got := timings.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("timings got %q expected %q", got, expected)
+	}
+	expected = []string{"a:1|c\x00c:3|g"}
+	if got := others.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("others got %q expected %q", got, expected)
+	}
+}
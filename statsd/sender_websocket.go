@@ -0,0 +1,223 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// websocketTextMessage is the WebSocket text message type, as used by
+// gorilla/websocket and others.
+const websocketTextMessage = 1
+
+// websocketQueue is the number of stats queued for each WebSocket connection.
+// A connection that falls further behind than this is dropped.
+const websocketQueue = 1024
+
+var errSlowConsumer = errors.New("websocket connection too slow, dropped")
+
+// A WebSocketConn is the part of a WebSocket connection a WebSocketTee
+// writes to. It is satisfied by a *websocket.Conn from gorilla/websocket,
+// so the tee does not depend on any particular WebSocket library.
+type WebSocketConn interface {
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// WebSocketUpgrader upgrades an http request to a WebSocket connection,
+// eg. by wrapping websocket.Upgrader.Upgrade from gorilla/websocket.
+type WebSocketUpgrader func(w http.ResponseWriter, r *http.Request) (WebSocketConn, error)
+
+// WebSocketTee sends stats via another Sender, and also streams every stat,
+// as a text message, to any connected WebSocket clients, for a live tail of
+// stats during development. eg.
+//
+//	tee := statsd.NewWebSocketTee(sender)
+//	client, err := statsd.NewClientWithSender(tee, "app", 0)
+//	...
+//	http.Handle("/debug/stats", tee.Handler(upgrade))
+//
+// Sends never block on WebSocket clients: a client that can't keep up is
+// disconnected.
+type WebSocketTee struct {
+	sender Sender
+	// separates the stats in a batch, the default if nil
+	separator []byte
+
+	mx      sync.Mutex
+	conns   map[*tailConn]struct{}
+	running bool
+}
+
+// tailConn is a WebSocket connection being streamed stats
+type tailConn struct {
+	conn  WebSocketConn
+	stats chan []byte
+	// closed when the connection is dropped
+	done chan struct{}
+	err  error
+}
+
+// NewWebSocketTee returns a WebSocketTee sending stats via sender.
+func NewWebSocketTee(sender Sender) *WebSocketTee {
+	return NewWebSocketTeeWithSeparator(sender, nil)
+}
+
+// NewWebSocketTeeWithSeparator returns a WebSocketTee, as NewWebSocketTee,
+// for batches with stats separated by separator. It must match the
+// ClientConfig.BufferSeparator of the clients using the tee. A nil separator
+// means the default, a newline.
+func NewWebSocketTeeWithSeparator(sender Sender, separator []byte) *WebSocketTee {
+	t := &WebSocketTee{
+		sender:  sender,
+		conns:   make(map[*tailConn]struct{}),
+		running: true,
+	}
+	if separator != nil {
+		t.separator = append([]byte{}, separator...)
+	}
+	return t
+}
+
+// Send sends data via the underlying sender, and queues each stat in data
+// (multiple stats are separated by the separator, a newline by default) for
+// every WebSocket client.
+func (t *WebSocketTee) Send(data []byte) (int, error) {
+	n, err := t.sender.Send(data)
+
+	sep := t.separator
+	if sep == nil {
+		sep = defaultSeparator
+	}
+
+	t.mx.Lock()
+	for rest := data; len(t.conns) > 0 && len(rest) > 0; {
+		// without a separator, the stats can't be told apart, so the batch
+		// is queued as one
+		stat := rest
+		if i := bytes.Index(rest, sep); len(sep) > 0 && i != -1 {
+			stat, rest = rest[:i], rest[i+len(sep):]
+		} else {
+			rest = nil
+		}
+		if len(stat) == 0 {
+			continue
+		}
+		// copied, as data may be reused once Send returns. the copy
+		// is shared, as it is never modified.
+		stat = append([]byte(nil), stat...)
+		for c := range t.conns {
+			select {
+			case c.stats <- stat:
+			default:
+				t.drop(c, errSlowConsumer)
+			}
+		}
+	}
+	t.mx.Unlock()
+
+	return n, err
+}
+
+// drop stops streaming to c. Must be called with mx held.
+func (t *WebSocketTee) drop(c *tailConn, err error) {
+	if _, ok := t.conns[c]; !ok {
+		return
+	}
+	delete(t.conns, c)
+	c.err = err
+	close(c.done)
+}
+
+// Serve streams stats to conn, until writing to it fails, it falls too far
+// behind, or the tee is closed. conn is closed when Serve returns. Returns
+// nil if the tee was closed.
+func (t *WebSocketTee) Serve(conn WebSocketConn) error {
+	defer conn.Close()
+
+	c := &tailConn{
+		conn:  conn,
+		stats: make(chan []byte, websocketQueue),
+		done:  make(chan struct{}),
+	}
+
+	t.mx.Lock()
+	if !t.running {
+		t.mx.Unlock()
+		return ErrClosed
+	}
+	t.conns[c] = struct{}{}
+	t.mx.Unlock()
+
+	for {
+		select {
+		case <-c.done:
+			return t.dropErr(c)
+		case stat := <-c.stats:
+			// stop promptly once dropped, rather than draining the queue
+			select {
+			case <-c.done:
+				return t.dropErr(c)
+			default:
+			}
+			if err := conn.WriteMessage(websocketTextMessage, stat); err != nil {
+				t.mx.Lock()
+				t.drop(c, err)
+				t.mx.Unlock()
+				return err
+			}
+		}
+	}
+}
+
+// dropErr returns the reason c was dropped
+func (t *WebSocketTee) dropErr(c *tailConn) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return c.err
+}
+
+// Handler returns an http.Handler that upgrades each request to a WebSocket
+// connection with upgrade, and streams stats to it (see Serve). If the
+// upgrade fails, upgrade is expected to have replied to the request.
+func (t *WebSocketTee) Handler(upgrade WebSocketUpgrader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		t.Serve(conn)
+	})
+}
+
+// Flush flushes the underlying sender, if it supports it.
+func (t *WebSocketTee) Flush() error {
+	if f, ok := t.sender.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Ping pings the underlying sender, if it supports it.
+func (t *WebSocketTee) Ping() error {
+	return ping(t.sender)
+}
+
+// Close disconnects any WebSocket clients, and closes the underlying sender.
+func (t *WebSocketTee) Close() error {
+	t.mx.Lock()
+	if t.running {
+		t.running = false
+		for c := range t.conns {
+			t.drop(c, nil)
+		}
+	}
+	t.mx.Unlock()
+
+	return t.sender.Close()
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWebSocketConn records the messages written to it. If block is set,
+// writes block until it is closed.
+type fakeWebSocketConn struct {
+	block    chan struct{}
+	messages chan string
+
+	mx     sync.Mutex
+	closed bool
+}
+
+func newFakeWebSocketConn() *fakeWebSocketConn {
+	return &fakeWebSocketConn{messages: make(chan string, 100)}
+}
+
+func (f *fakeWebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if f.block != nil {
+		<-f.block
+	}
+	if messageType != websocketTextMessage {
+		panic("expected a text message")
+	}
+	f.messages <- string(data)
+	return nil
+}
+
+func (f *fakeWebSocketConn) Close() error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.closed = true
+	return nil
+}
+
+// waitConns waits for the tee to have n connections
+func waitConns(t *testing.T, tee *WebSocketTee, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		tee.mx.Lock()
+		got := len(tee.conns)
+		tee.mx.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d websocket connections, got %d", n, got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWebSocketTee(t *testing.T) {
+	rs := &recordingSender{}
+	tee := NewWebSocketTee(rs)
+	c, err := NewClientWithSender(tee, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := newFakeWebSocketConn()
+	served := make(chan error, 1)
+	go func() { served <- tee.Serve(conn) }()
+	waitConns(t, tee, 1)
+
+	c.Inc("count", 1, 1.0)
+	c.Gauge("gauge", 2, 1.0)
+	// as sent by a buffered sender
+	tee.Send([]byte("a:1|c\nb:2|c"))
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		select {
+		case m := <-conn.messages:
+			got = append(got, m)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for stats, got %q", got)
+		}
+	}
+	expected := []string{"test.count:1|c", "test.gauge:2|g", "a:1|c", "b:2|c"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	// still sent as usual
+	if n := len(rs.sent()); n != 3 {
+		t.Fatalf("expected 3 packets sent, got %d", n)
+	}
+
+	c.Close()
+	if err := <-served; err != nil {
+		t.Fatalf("expected Serve to return nil once closed, got %v", err)
+	}
+	if !conn.closed || !rs.closed {
+		t.Fatal("expected the connection and sender to be closed")
+	}
+}
+
+func TestWebSocketTeeSeparator(t *testing.T) {
+	tee := NewWebSocketTeeWithSeparator(&recordingSender{}, []byte("\x00"))
+	defer tee.Close()
+
+	conn := newFakeWebSocketConn()
+	go tee.Serve(conn)
+	waitConns(t, tee, 1)
+
+	tee.Send([]byte("a:1|c\x00b:2|c"))
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-conn.messages:
+			got = append(got, m)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for stats, got %q", got)
+		}
+	}
+	expected := []string{"a:1|c", "b:2|c"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestWebSocketTeeDropsSlowConsumers(t *testing.T) {
+	tee := NewWebSocketTee(&recordingSender{})
+	defer tee.Close()
+
+	slow := newFakeWebSocketConn()
+	slow.block = make(chan struct{})
+	served := make(chan error, 1)
+	go func() { served <- tee.Serve(slow) }()
+	waitConns(t, tee, 1)
+
+	// sends never block, however far behind the connection is
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < websocketQueue+10; i++ {
+			tee.Send([]byte("count:1|c"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("send blocked on a slow websocket connection")
+	}
+
+	waitConns(t, tee, 0)
+	close(slow.block)
+	if err := <-served; err != errSlowConsumer {
+		t.Fatalf("expected errSlowConsumer, got %v", err)
+	}
+}
+
+func TestWebSocketTeeHandler(t *testing.T) {
+	tee := NewWebSocketTee(&recordingSender{})
+
+	conn := newFakeWebSocketConn()
+	h := tee.Handler(func(w http.ResponseWriter, r *http.Request) (WebSocketConn, error) {
+		return conn, nil
+	})
+	handled := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/debug/stats", nil))
+		close(handled)
+	}()
+	waitConns(t, tee, 1)
+
+	tee.Send([]byte("count:1|c"))
+	if m := <-conn.messages; m != "count:1|c" {
+		t.Fatalf("got %q", m)
+	}
+
+	tee.Close()
+	<-handled
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// signalFlusher flushes, or closes, a client when the process receives one
+// of a set of signals (see ClientConfig.FlushOnSignal), then re-raises the
+// signal so it has its usual effect.
+type signalFlusher struct {
+	client  *Client
+	close   bool
+	signals chan os.Signal
+	done    chan struct{}
+	once    sync.Once
+	raise   func(os.Signal) error
+	exit    func(int)
+}
+
+// newSignalFlusher returns a signalFlusher for sigs, or nil if there are
+// none. It watches for them once started.
+func newSignalFlusher(client *Client, sigs []os.Signal, close bool) *signalFlusher {
+	if len(sigs) == 0 {
+		return nil
+	}
+
+	f := &signalFlusher{
+		client:  client,
+		close:   close,
+		signals: make(chan os.Signal, 1),
+		done:    make(chan struct{}),
+		raise:   raiseSignal,
+		exit:    exitProcess,
+	}
+	signal.Notify(f.signals, sigs...)
+	return f
+}
+
+// start starts handling the signals.
+func (f *signalFlusher) start() {
+	go f.run()
+}
+
+func (f *signalFlusher) run() {
+	select {
+	case <-f.done:
+	case sig := <-f.signals:
+		f.handle(sig)
+	}
+}
+
+// handle flushes or closes the client, stops watching for signals, then
+// re-raises sig, which no longer being handled here has its usual effect
+// (eg. exiting the process). If it can't be re-raised (eg. os.Interrupt on
+// windows), the process exits as if killed by it.
+func (f *signalFlusher) handle(sig os.Signal) {
+	if f.close {
+		f.client.Close()
+	} else {
+		f.client.Flush()
+	}
+	f.stop()
+	if err := f.raise(sig); err != nil {
+		f.exit(signalExitCode(sig))
+	}
+}
+
+// signalExitCode returns the conventional exit status of a process killed by
+// sig, 128 plus the signal number.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// stop stops watching for signals. It is safe to call more than once.
+func (f *signalFlusher) stop() {
+	f.once.Do(func() {
+		signal.Stop(f.signals)
+		close(f.done)
+	})
+}
+
+// raiseSignal re-raises a handled signal. Replaced in tests.
+var raiseSignal = func(sig os.Signal) error {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// exitProcess exits the process when a signal can't be re-raised. Replaced
+// in tests.
+var exitProcess = os.Exit
+
+// StopFlushOnSignal stops flushing the client on the signals in
+// ClientConfig.FlushOnSignal, leaving them to their usual effect. Closing
+// the client also stops it.
+func (s *Client) StopFlushOnSignal() {
+	if s == nil || s.signals == nil {
+		return
+	}
+	s.signals.stop()
+}
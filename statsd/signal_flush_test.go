@@ -0,0 +1,127 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newSignalFlushClient(t *testing.T, rs *recordingSender, close bool) (*Client, chan os.Signal) {
+	// record the re-raised signal, rather than exiting the test
+	raised := make(chan os.Signal, 1)
+	raise := raiseSignal
+	raiseSignal = func(sig os.Signal) error {
+		raised <- sig
+		return nil
+	}
+	defer func() { raiseSignal = raise }()
+
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:            "test",
+		Aggregate:         true,
+		AggregateInterval: time.Hour,
+		FlushOnSignal:     []os.Signal{os.Interrupt},
+		CloseOnSignal:     close,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*Client), raised
+}
+
+func TestFlushOnSignal(t *testing.T) {
+	for _, close := range []bool{false, true} {
+		rs := &recordingSender{}
+		client, raised := newSignalFlushClient(t, rs, close)
+
+		client.Inc("count", 1, 1.0)
+		client.signals.handle(os.Interrupt)
+
+		expected := []string{"test.count:1|c"}
+		if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("close %v: got %q expected %q", close, got, expected)
+		}
+		if closed := rs.closed; closed != close {
+			t.Fatalf("close %v: got closed %v", close, closed)
+		}
+		if sig := <-raised; sig != os.Interrupt {
+			t.Fatalf("expected the signal to be re-raised, got %v", sig)
+		}
+		client.Close()
+	}
+}
+
+func TestFlushOnSignalDelivered(t *testing.T) {
+	rs := &recordingSender{}
+	client, raised := newSignalFlushClient(t, rs, false)
+	defer client.Close()
+
+	client.Inc("count", 1, 1.0)
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("can not signal the test process: %s", err)
+	}
+
+	select {
+	case <-raised:
+	case <-time.After(time.Second):
+		t.Fatal("signal was not handled")
+	}
+	expected := []string{"test.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestStopFlushOnSignal(t *testing.T) {
+	rs := &recordingSender{}
+	client, _ := newSignalFlushClient(t, rs, false)
+	defer client.Close()
+
+	client.StopFlushOnSignal()
+	// stopping is idempotent, and Close stops too
+	client.StopFlushOnSignal()
+
+	select {
+	case <-client.signals.done:
+	default:
+		t.Fatal("expected watching for signals to stop")
+	}
+
+	var nilClient *Client
+	nilClient.StopFlushOnSignal()
+}
+
+func TestFlushOnSignalRaiseFails(t *testing.T) {
+	rs := &recordingSender{}
+	client, _ := newSignalFlushClient(t, rs, false)
+	defer client.Close()
+
+	// eg. os.Interrupt on windows, which can't be sent to a process
+	client.signals.raise = func(os.Signal) error {
+		return errors.New("not supported by windows")
+	}
+	code := -1
+	client.signals.exit = func(c int) { code = c }
+
+	client.Inc("count", 1, 1.0)
+	client.signals.handle(syscall.SIGINT)
+
+	expected := []string{"test.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if code != 130 {
+		t.Fatalf("expected the process to exit with 130, got %d", code)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSpanStopped is returned by Span.Stop and Span.StopWithTags for a span
+// that has already been stopped.
+var ErrSpanStopped = errors.New("span already stopped")
+
+// A Span times an operation with explicit start and stop calls, for when
+// wrapping it in a func (see TimeResult) is awkward. It is created by
+// StartSpan, and submits the elapsed time as a timing when stopped. A Span
+// is safe for concurrent use, and any number of spans may be live at once.
+type Span struct {
+	client  *Client
+	stat    string
+	tags    []Tag
+	start   time.Time
+	stopped int32
+}
+
+// StartSpan starts timing an operation, to be submitted as the timing stat
+// once the returned Span is stopped. eg.
+//
+//	span := client.StartSpan("import", statsd.Tag{"source", "s3"})
+//	...
+//	span.Stop(1.0)
+//
+// tags are submitted with the timing.
+func (s *Client) StartSpan(stat string, tags ...Tag) *Span {
+	return &Span{
+		client: s,
+		stat:   stat,
+		tags:   append([]Tag(nil), tags...),
+		start:  time.Now(),
+	}
+}
+
+// Stop submits the time elapsed since the span started, as for
+// TimingDuration.
+// rate is the sample rate (0.0 to 1.0).
+// A span can only be stopped once. Stopping it again (with Stop or
+// StopWithTags) submits nothing, and returns ErrSpanStopped, so that callers
+// can tell a double stop apart from a send error.
+func (sp *Span) Stop(rate float32) error {
+	return sp.StopWithTags(rate)
+}
+
+// StopWithTags is Stop, submitting tags in addition to those the span was
+// started with, eg. for an outcome only known once the operation is done.
+func (sp *Span) StopWithTags(rate float32, tags ...Tag) error {
+	delta := time.Since(sp.start)
+	if !atomic.CompareAndSwapInt32(&sp.stopped, 0, 1) {
+		return ErrSpanStopped
+	}
+
+	if len(tags) > 0 {
+		tags = append(sp.tags[:len(sp.tags):len(sp.tags)], tags...)
+	} else {
+		tags = sp.tags
+	}
+	return sp.client.TimingDuration(sp.stat, delta, rate, tags...)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpan(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	outer := client.StartSpan("outer", Tag{"tag1", "val1"})
+	inner := client.StartSpan("inner")
+	time.Sleep(20 * time.Millisecond)
+	if err := inner.Stop(1.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.StopWithTags(1.0, Tag{"outcome", "success"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := rs.sent()
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 timings, got %q", sent)
+	}
+	for i, prefix := range []string{"test.inner:", "test.outer:"} {
+		stat := sent[i]
+		if !strings.HasPrefix(stat, prefix) {
+			t.Fatalf("expected %q to start with %q", stat, prefix)
+		}
+		value := stat[len(prefix):strings.IndexByte(stat, '|')]
+		ms, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ms < 20 || ms > 1000 {
+			t.Fatalf("expected about 20ms for %q, got %vms", stat, ms)
+		}
+	}
+	if suffix := "|ms|#tag1:val1,outcome:success"; !strings.HasSuffix(sent[1], suffix) {
+		t.Fatalf("expected %q to end with %q", sent[1], suffix)
+	}
+}
+
+func TestSpanDoubleStop(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := c.(*Client).StartSpan("op")
+	if err := span.Stop(1.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := span.Stop(1.0); err != ErrSpanStopped {
+		t.Fatalf("expected ErrSpanStopped, got %v", err)
+	}
+	if sent := rs.sent(); len(sent) != 1 {
+		t.Fatalf("expected 1 timing, got %q", sent)
+	}
+
+	// spans from a nil client submit nothing
+	var nilClient *Client
+	if err := nilClient.StartSpan("op").Stop(1.0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// statCatalog records the distinct stat names a client has submitted, up to
+// a limit (see ClientConfig.SeenStatsLimit).
+type statCatalog struct {
+	mx    sync.Mutex
+	names map[string]struct{}
+	limit int
+}
+
+func newStatCatalog(limit int) *statCatalog {
+	return &statCatalog{
+		names: make(map[string]struct{}),
+		limit: limit,
+	}
+}
+
+// see records a stat name, as submitted. It reports whether the name is in
+// the catalog, which it is not if it is new and the catalog is full.
+func (c *statCatalog) see(name string) bool {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.names[name]; ok {
+		return true
+	}
+	if len(c.names) >= c.limit {
+		return false
+	}
+	c.names[name] = struct{}{}
+	return true
+}
+
+// list returns the names in the catalog, sorted.
+func (c *statCatalog) list() []string {
+	c.mx.Lock()
+	names := make([]string, 0, len(c.names))
+	for name := range c.names {
+		names = append(names, name)
+	}
+	c.mx.Unlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// seeStat records a submitted stat in the catalog, counting it if the catalog
+// is full.
+func (s *Client) seeStat(stat string, opts *callOptions) {
+	if !s.catalog.see(s.fullStatName(stat, opts)) {
+		atomic.AddInt64(&s.counters.untrackedStats, 1)
+	}
+}
+
+// SeenStats returns the distinct stat names (including any prefix) submitted
+// by the client and its SubStatters, sorted, for building a catalog of the
+// metrics an application emits. Returns nil unless enabled with
+// ClientConfig.SeenStatsLimit.
+func (s *Client) SeenStats() []string {
+	if s == nil || s.catalog == nil {
+		return nil
+	}
+	return s.catalog.list()
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSeenStats(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:         "test",
+		SeenStatsLimit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	client.Inc("requests", 1, 1.0)
+	client.Inc("requests", 1, 1.0, Tag{"tag1", "val1"})
+	client.Timing("latency", 5, 1.0)
+	client.Gauge("shared.heap", 4096, 1.0, Absolute())
+	client.NewSubStatter("db").Inc("queries", 1, 1.0)
+	client.Inc("requests", 1, 1.0, WithPrefix("other"))
+
+	expected := []string{"other.requests", "shared.heap", "test.db.queries", "test.latency", "test.requests"}
+	if got := client.SeenStats(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if untracked := client.Stats().UntrackedStats; untracked != 0 {
+		t.Fatalf("expected no untracked stats, got %d", untracked)
+	}
+}
+
+func TestSeenStatsLimit(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:         "test",
+		SeenStatsLimit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	client.Inc("a", 1, 1.0)
+	client.Inc("b", 1, 1.0)
+	client.Inc("c", 1, 1.0)
+	client.Inc("d", 1, 1.0)
+	// already tracked names are still fine once full
+	client.Inc("a", 1, 1.0)
+
+	expected := []string{"test.a", "test.b"}
+	if got := client.SeenStats(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if untracked := client.Stats().UntrackedStats; untracked != 2 {
+		t.Fatalf("expected 2 untracked stats, got %d", untracked)
+	}
+	// untracked stats are still sent
+	if sent := len(rs.sent()); sent != 5 {
+		t.Fatalf("expected 5 stats sent, got %d", sent)
+	}
+}
+
+func TestSeenStatsDisabled(t *testing.T) {
+	c, err := newClientWithConfig(&recordingSender{}, &ClientConfig{Prefix: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+
+	client.Inc("a", 1, 1.0)
+	if got := client.SeenStats(); got != nil {
+		t.Fatalf("expected no seen stats when disabled, got %q", got)
+	}
+}
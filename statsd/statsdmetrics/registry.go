@@ -0,0 +1,135 @@
+// Package statsdmetrics adapts a statsd.StatSender to a generic metrics
+// registry interface, for use as a backend by libraries that instrument
+// themselves against such an abstraction.
+package statsdmetrics
+
+import (
+	"sync"
+
+	"github.com/chrisbailey4/go-statsd-client/v5/statsd"
+)
+
+// Counter is a metric that counts events.
+type Counter interface {
+	Inc(int64)
+	Dec(int64)
+}
+
+// Gauge is a metric that records an instantaneous value.
+type Gauge interface {
+	Update(int64)
+}
+
+// Histogram is a metric that records a distribution of values.
+type Histogram interface {
+	Update(int64)
+}
+
+// Registry hands out named instruments. Asking for the same name (and kind)
+// more than once returns the same instrument.
+type Registry interface {
+	Counter(name string) Counter
+	Gauge(name string) Gauge
+	Histogram(name string) Histogram
+}
+
+// StatsdRegistry implements Registry, forwarding every instrument update to
+// a statsd.StatSender. It should be constructed with NewRegistry().
+//
+// Since the instrument interfaces do not return errors, any errors from the
+// underlying StatSender are discarded.
+type StatsdRegistry struct {
+	sender statsd.StatSender
+	rate   float32
+
+	mx         sync.Mutex
+	counters   map[string]*counter
+	gauges     map[string]*gauge
+	histograms map[string]*histogram
+}
+
+// NewRegistry returns a StatsdRegistry backed by sender.
+//
+// rate is the sample rate (0.0 to 1.0) used for all instrument updates.
+func NewRegistry(sender statsd.StatSender, rate float32) *StatsdRegistry {
+	return &StatsdRegistry{
+		sender:     sender,
+		rate:       rate,
+		counters:   make(map[string]*counter),
+		gauges:     make(map[string]*gauge),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// Counter returns the Counter registered under name, registering a new one
+// if needed. Updates are submitted with StatSender.Inc and StatSender.Dec.
+func (r *StatsdRegistry) Counter(name string) Counter {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	c, ok := r.counters[name]
+	if !ok {
+		c = &counter{r, name}
+		r.counters[name] = c
+	}
+	return c
+}
+
+// Gauge returns the Gauge registered under name, registering a new one if
+// needed. Updates are submitted with StatSender.Gauge.
+func (r *StatsdRegistry) Gauge(name string) Gauge {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	g, ok := r.gauges[name]
+	if !ok {
+		g = &gauge{r, name}
+		r.gauges[name] = g
+	}
+	return g
+}
+
+// Histogram returns the Histogram registered under name, registering a new
+// one if needed. Updates are submitted with StatSender.Histogram.
+func (r *StatsdRegistry) Histogram(name string) Histogram {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	h, ok := r.histograms[name]
+	if !ok {
+		h = &histogram{r, name}
+		r.histograms[name] = h
+	}
+	return h
+}
+
+type counter struct {
+	r    *StatsdRegistry
+	name string
+}
+
+func (c *counter) Inc(n int64) {
+	c.r.sender.Inc(c.name, n, c.r.rate)
+}
+
+func (c *counter) Dec(n int64) {
+	c.r.sender.Dec(c.name, n, c.r.rate)
+}
+
+type gauge struct {
+	r    *StatsdRegistry
+	name string
+}
+
+func (g *gauge) Update(v int64) {
+	g.r.sender.Gauge(g.name, v, g.r.rate)
+}
+
+type histogram struct {
+	r    *StatsdRegistry
+	name string
+}
+
+func (h *histogram) Update(v int64) {
+	h.r.sender.Histogram(h.name, float64(v), h.r.rate)
+}
@@ -0,0 +1,57 @@
+package statsdmetrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chrisbailey4/go-statsd-client/v5/statsd"
+	"github.com/chrisbailey4/go-statsd-client/v5/statsd/statsdtest"
+)
+
+func TestStatsdRegistryIsRegistry(t *testing.T) {
+	var _ Registry = NewRegistry(nil, 1.0)
+}
+
+func TestStatsdRegistry(t *testing.T) {
+	rs := statsdtest.NewRecordingSender()
+	statter, err := statsd.NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry(statter, 1.0)
+	r.Counter("requests").Inc(3)
+	r.Counter("requests").Dec(1)
+	r.Gauge("queue").Update(42)
+	r.Histogram("size").Update(512)
+
+	expected := []string{
+		"test.requests:3|c",
+		"test.requests:-1|c",
+		"test.queue:42|g",
+		"test.size:512|h",
+	}
+	var got []string
+	for _, s := range rs.GetSent() {
+		got = append(got, string(s.Raw))
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestStatsdRegistrySameInstrument(t *testing.T) {
+	r := NewRegistry(nil, 1.0)
+	if r.Counter("a") != r.Counter("a") {
+		t.Error("expected the same counter for the same name")
+	}
+	if r.Gauge("a") != r.Gauge("a") {
+		t.Error("expected the same gauge for the same name")
+	}
+	if r.Histogram("a") != r.Histogram("a") {
+		t.Error("expected the same histogram for the same name")
+	}
+	if r.Counter("a") == r.Counter("b") {
+		t.Error("expected different counters for different names")
+	}
+}
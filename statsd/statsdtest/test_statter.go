@@ -0,0 +1,100 @@
+package statsdtest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/chrisbailey4/go-statsd-client/v5/statsd"
+)
+
+// TestStatter is a statsd.Statter that records the stats submitted to it,
+// with assertions on them that report failures to a testing.TB, for concise
+// tests of instrumentation. It should be constructed with NewTestStatter().
+//
+// Stats are recorded without a prefix, and stat names in assertions are
+// matched exactly. Assertions ignore tags, so cover every tag set of a stat.
+// Every stat is recorded, whatever its sample rate, so that assertions are
+// deterministic. Counts are as submitted, not scaled up by the rate.
+type TestStatter struct {
+	statsd.Statter
+	tb testing.TB
+	rs *RecordingSender
+}
+
+// NewTestStatter creates a new TestStatter, reporting assertion failures to
+// tb.
+func NewTestStatter(tb testing.TB) *TestStatter {
+	rs := NewRecordingSender()
+	// only fails for a nil sender
+	statter, _ := statsd.NewClientWithSender(rs, "", 0)
+	statter.(*statsd.Client).SetSamplerFunc(func(float32) bool { return true })
+	return &TestStatter{Statter: statter, tb: tb, rs: rs}
+}
+
+// Sent returns the stats that have been submitted so far.
+func (s *TestStatter) Sent() Stats {
+	return s.rs.GetSent()
+}
+
+// Reset clears the stats that have been submitted so far.
+func (s *TestStatter) Reset() {
+	s.rs.ClearSent()
+}
+
+// AssertCount checks that the counter values (from Inc and Dec) submitted
+// for name add up to n.
+func (s *TestStatter) AssertCount(name string, n int64) {
+	s.tb.Helper()
+
+	var total int64
+	for _, e := range s.typed(name, "c") {
+		v, err := strconv.ParseInt(e.Value, 10, 64)
+		if err != nil {
+			s.tb.Errorf("%s: invalid count %q", name, e.Value)
+			return
+		}
+		total += v
+	}
+	if total != n {
+		s.tb.Errorf("%s: got count %d, expected %d", name, total, n)
+	}
+}
+
+// AssertGauge checks that the last gauge value submitted for name is value.
+func (s *TestStatter) AssertGauge(name string, value string) {
+	s.tb.Helper()
+
+	gauges := s.typed(name, "g")
+	if len(gauges) == 0 {
+		s.tb.Errorf("%s: no gauge sent, expected %s", name, value)
+		return
+	}
+	if got := gauges[len(gauges)-1].Value; got != value {
+		s.tb.Errorf("%s: got gauge %s, expected %s", name, got, value)
+	}
+}
+
+// AssertSent checks that at least one stat was submitted for name.
+func (s *TestStatter) AssertSent(name string) {
+	s.tb.Helper()
+
+	if len(s.Sent().CollectNamed(name)) == 0 {
+		s.tb.Errorf("%s: not sent", name)
+	}
+}
+
+// AssertNotSent checks that no stats were submitted for name.
+func (s *TestStatter) AssertNotSent(name string) {
+	s.tb.Helper()
+
+	if sent := s.Sent().CollectNamed(name); len(sent) != 0 {
+		s.tb.Errorf("%s: got %d stats sent, expected none", name, len(sent))
+	}
+}
+
+// typed returns the stats submitted for name, of the stat type typ.
+func (s *TestStatter) typed(name, typ string) Stats {
+	return s.Sent().Collect(func(e Stat) bool {
+		return e.Stat == name && e.Tag == typ
+	})
+}
@@ -0,0 +1,85 @@
+package statsdtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chrisbailey4/go-statsd-client/v5/statsd"
+)
+
+func TestTestStatterIsStatter(t *testing.T) {
+	var _ statsd.Statter = NewTestStatter(t)
+}
+
+func TestTestStatter(t *testing.T) {
+	s := NewTestStatter(t)
+
+	s.Inc("requests", 2, 1.0)
+	s.Inc("requests", 3, 1.0, statsd.Tag{"code", "200"})
+	s.Dec("requests", 1, 1.0)
+	s.Gauge("queue", 4, 1.0)
+	s.Gauge("queue", 7, 1.0)
+
+	s.AssertCount("requests", 4)
+	s.AssertGauge("queue", "7")
+	s.AssertSent("queue")
+	s.AssertNotSent("errors")
+	// unsent counters count zero
+	s.AssertCount("errors", 0)
+
+	s.Reset()
+	s.AssertNotSent("requests")
+}
+
+func TestTestStatterSampled(t *testing.T) {
+	s := NewTestStatter(t)
+
+	// sampled stats are always recorded
+	for i := 0; i < 10; i++ {
+		s.Inc("requests", 1, 0.01)
+	}
+	s.Gauge("queue", 4, 0.5)
+
+	s.AssertCount("requests", 10)
+	s.AssertGauge("queue", "4")
+}
+
+// printingTB prints assertion failures, instead of failing a test.
+type printingTB struct {
+	testing.TB
+}
+
+func (printingTB) Helper() {}
+
+func (printingTB) Errorf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func ExampleTestStatter_AssertCount() {
+	// in a test, this would be NewTestStatter(t)
+	s := NewTestStatter(printingTB{})
+
+	s.Inc("requests", 1, 1.0)
+	s.Inc("requests", 2, 1.0)
+
+	// passes
+	s.AssertCount("requests", 3)
+	// fails
+	s.AssertCount("requests", 4)
+	// Output: requests: got count 3, expected 4
+}
+
+func ExampleTestStatter_AssertGauge() {
+	s := NewTestStatter(printingTB{})
+
+	s.Gauge("queue", 4, 1.0)
+
+	// passes
+	s.AssertGauge("queue", "4")
+	// fails
+	s.AssertGauge("queue", "5")
+	s.AssertGauge("pool", "1")
+	// Output:
+	// queue: got gauge 4, expected 5
+	// pool: no gauge sent, expected 1
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "errors"
+
+// Errors returned for stats a strict client (see ClientConfig.Strict) drops,
+// which are otherwise dropped silently. Stats dropped by a sender are
+// returned wrapped in a SendError, so compare with errors.Is.
+var (
+	// ErrSampledOut is returned for a stat not sent because of its sample
+	// rate.
+	ErrSampledOut = errors.New("stat sampled out")
+	// ErrDisabled is returned for a stat not sent because the client is
+	// disabled (see Client.SetEnabled).
+	ErrDisabled = errors.New("client disabled, stat dropped")
+	// ErrPacketTooLarge is returned for a stat too large to send in a single
+	// udp packet.
+	ErrPacketTooLarge = errors.New("stat too large for a packet, stat dropped")
+	// ErrCircuitOpen is returned for a stat dropped while the circuit
+	// breaker is open (see ClientConfig.BreakerFailures). With UseBuffered,
+	// it is returned to the flush goroutine, not the caller, so the stat is
+	// only counted.
+	ErrCircuitOpen = errors.New("circuit breaker open, stat dropped")
+	// ErrOverMemory is returned for a stat dropped because aggregated and
+	// batched stats hold ClientConfig.MaxBufferMemory. Stats dropped from a
+	// RequestBatch always return it, strict or not.
+	ErrOverMemory = errors.New("buffer memory exhausted, stat dropped")
+)
+
+// excluded returns the error for a stat includeStat excluded: nil, unless the
+// client is strict. Sampled out counts are not dropped if they are carried
+// (see ClientConfig.SampleAdjust).
+func (s *Client) excluded(count bool) error {
+	if s == nil || !s.strict {
+		return nil
+	}
+	if !s.Enabled() {
+		return ErrDisabled
+	}
+	if count && s.adjuster != nil {
+		return nil
+	}
+	return ErrSampledOut
+}
+
+// dropped returns err for a dropped stat if the client is strict, or nil.
+func (s *Client) dropped(err error) error {
+	if s.strict {
+		return err
+	}
+	return nil
+}
+
+// strictMaxPacket returns the largest stat line a strict client sends: the
+// flush size for udp, where larger packets risk being fragmented and lost,
+// and unbounded otherwise. A shared Sender may not send over udp at all (eg.
+// a KafkaSender), so is unbounded too.
+func strictMaxPacket(config *ClientConfig) int {
+	if config.Network != "" && config.Network != "udp" {
+		return 0
+	}
+	if config.Sender != nil && len(config.Destinations) == 0 {
+		return 0
+	}
+	if config.FlushBytes > 0 {
+		return config.FlushBytes
+	}
+	return defaultFlushBytes
+}
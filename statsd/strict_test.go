@@ -0,0 +1,215 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStrict(t *testing.T) {
+	// reserve an address, with nothing listening on it
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := l.Addr().String()
+	l.Close()
+
+	udp, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	tests := []struct {
+		Name   string
+		Config ClientConfig
+		Submit func(c *Client) error
+		Err    error
+	}{
+		{
+			"sampled out",
+			ClientConfig{Sender: &recordingSender{}},
+			func(c *Client) error {
+				c.SetSamplerFunc(func(float32) bool { return false })
+				return c.Gauge("gauge", 1, 0.5)
+			},
+			ErrSampledOut,
+		},
+		{
+			"zero rate",
+			ClientConfig{Sender: &recordingSender{}},
+			func(c *Client) error { return c.Inc("count", 1, 0) },
+			ErrSampledOut,
+		},
+		{
+			"disabled",
+			ClientConfig{Sender: &recordingSender{}},
+			func(c *Client) error {
+				c.SetEnabled(false)
+				return c.Inc("count", 1, 1.0)
+			},
+			ErrDisabled,
+		},
+		{
+			"packet too large",
+			ClientConfig{Address: udp.LocalAddr().String(), FlushBytes: 64},
+			func(c *Client) error { return c.Inc(strings.Repeat("x", 64), 1, 1.0) },
+			ErrPacketTooLarge,
+		},
+		{
+			"not connected",
+			ClientConfig{
+				Address:          unreachable,
+				Network:          "tcp",
+				RetryInitialDial: true,
+				RetryInterval:    time.Hour,
+				WhenDisconnected: DisconnectedDrop,
+			},
+			func(c *Client) error { return c.Inc("count", 1, 1.0) },
+			ErrNotConnected,
+		},
+		{
+			"circuit open",
+			ClientConfig{
+				Sender:          &flakySender{fails: 1, err: syscall.ENOBUFS},
+				BreakerFailures: 1,
+				BreakerCooldown: time.Hour,
+			},
+			func(c *Client) error {
+				c.Inc("count", 1, 1.0)
+				return c.Inc("count", 1, 1.0)
+			},
+			ErrCircuitOpen,
+		},
+		{
+			"over memory aggregated",
+			ClientConfig{
+				Sender:            &recordingSender{},
+				Aggregate:         true,
+				AggregateInterval: time.Hour,
+				MaxBufferMemory:   aggregateOverhead,
+			},
+			func(c *Client) error { return c.Gauge("gauge", 1, 1.0) },
+			ErrOverMemory,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, strict := range []bool{true, false} {
+			config := tt.Config
+			config.Strict = strict
+			c, err := NewClientWithConfig(&config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = tt.Submit(c.(*Client))
+			switch {
+			case strict && !errors.Is(err, tt.Err):
+				t.Errorf("%s: expected %v, got %v", tt.Name, tt.Err, err)
+			case !strict && err != nil:
+				t.Errorf("%s: expected no error when not strict, got %v", tt.Name, err)
+			}
+			c.Close()
+		}
+	}
+}
+
+func TestStrictSampleAdjust(t *testing.T) {
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:       &recordingSender{},
+		Strict:       true,
+		SampleAdjust: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.(*Client).SetSamplerFunc(func(float32) bool { return false })
+
+	// sampled out counts are carried, not dropped
+	if err := c.Inc("count", 1, 0.5); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := c.Gauge("gauge", 1, 0.5); err != ErrSampledOut {
+		t.Fatalf("expected ErrSampledOut, got %v", err)
+	}
+}
+
+func TestStrictPacketSize(t *testing.T) {
+	l, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := NewClientWithConfig(&ClientConfig{
+		Address: l.LocalAddr().String(),
+		Strict:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// stats up to the default flush size are sent
+	stat := strings.Repeat("x", defaultFlushBytes-len(":1|c"))
+	if err := c.Inc(stat, 1, 1.0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := c.Inc(stat+"x", 1, 1.0); err != ErrPacketTooLarge {
+		t.Fatalf("expected ErrPacketTooLarge, got %v", err)
+	}
+	if dropped := c.(*Client).Stats().DroppedStats; dropped != 1 {
+		t.Fatalf("expected 1 dropped stat, got %d", dropped)
+	}
+}
+
+func TestStrictPacketSizeNotUDP(t *testing.T) {
+	// senders that may not send over udp are not bounded
+	rs := &recordingSender{}
+	c, err := NewClientWithConfig(&ClientConfig{
+		Sender:     rs,
+		FlushBytes: 64,
+		Strict:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	stat := strings.Repeat("x", 64)
+	if err := c.Inc(stat, 1, 1.0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sent := rs.sent(); len(sent) != 1 || sent[0] != stat+":1|c" {
+		t.Fatalf("expected the stat to be sent, got %q", sent)
+	}
+}
+
+func TestOverMemoryBatched(t *testing.T) {
+	// stats dropped from a batch return an error, strict or not
+	for _, strict := range []bool{true, false} {
+		c, err := NewClientWithConfig(&ClientConfig{
+			Sender:          &recordingSender{},
+			MaxBufferMemory: 8,
+			Strict:          strict,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.(*Client).NewRequestBatch().Inc("count", 1, 1.0)
+		if !errors.Is(err, ErrOverMemory) {
+			t.Errorf("strict %v: expected ErrOverMemory, got %v", strict, err)
+		}
+		c.Close()
+	}
+}
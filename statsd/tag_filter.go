@@ -0,0 +1,60 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+// tagFilter restricts which tag keys are submitted.
+type tagFilter struct {
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// newTagFilter returns a tagFilter for the supplied allow and deny lists, or
+// nil if both are empty (no filtering).
+func newTagFilter(allow, deny []string) *tagFilter {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	f := &tagFilter{}
+	if len(allow) > 0 {
+		f.allow = make(map[string]struct{}, len(allow))
+		for _, k := range allow {
+			f.allow[k] = struct{}{}
+		}
+	}
+	if len(deny) > 0 {
+		f.deny = make(map[string]struct{}, len(deny))
+		for _, k := range deny {
+			f.deny[k] = struct{}{}
+		}
+	}
+	return f
+}
+
+// allowed reports whether a tag key may be submitted
+func (f *tagFilter) allowed(key string) bool {
+	if _, ok := f.deny[key]; ok {
+		return false
+	}
+	if f.allow == nil {
+		return true
+	}
+	_, ok := f.allow[key]
+	return ok
+}
+
+// filter appends the allowed tags to dst, returning the result and the number
+// of tags dropped.
+func (f *tagFilter) filter(dst []Tag, tags []Tag) ([]Tag, int) {
+	dropped := 0
+	for _, t := range tags {
+		if f.allowed(t[0]) {
+			dst = append(dst, t)
+		} else {
+			dropped++
+		}
+	}
+	return dst, dropped
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagFilter(t *testing.T) {
+	tests := []struct {
+		TagFormat TagFormat
+		Allowed   []string
+		Denied    []string
+		Expected  string
+		Dropped   int64
+	}{
+		{SuffixOctothorpe, nil, nil, "test.count:1|c|#env:prod,user:bob,region:us", 0},
+		{SuffixOctothorpe, []string{"env", "region"}, nil, "test.count:1|c|#env:prod,region:us", 1},
+		{SuffixOctothorpe, nil, []string{"user"}, "test.count:1|c|#env:prod,region:us", 1},
+		{SuffixOctothorpe, []string{"env", "region"}, []string{"env"}, "test.count:1|c|#region:us", 2},
+		{SuffixOctothorpe, []string{"other"}, nil, "test.count:1|c", 3},
+		{InfixComma, []string{"env", "region"}, nil, "test.count,env=prod,region=us:1|c", 1},
+		{InfixComma, nil, []string{"user", "region"}, "test.count,env=prod:1|c", 2},
+		{InfixSemicolon, []string{"user"}, nil, "test.count;user=bob:1|c", 2},
+	}
+
+	for _, tt := range tests {
+		rs := &recordingSender{}
+		c, err := newClientWithConfig(rs, &ClientConfig{
+			Prefix:         "test",
+			TagFormat:      tt.TagFormat,
+			Tags:           []Tag{{"env", "prod"}},
+			AllowedTagKeys: tt.Allowed,
+			DeniedTagKeys:  tt.Denied,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.Inc("count", 1, 1.0, Tag{"user", "bob"}, Tag{"region", "us"})
+
+		expected := []string{tt.Expected}
+		if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("got %q expected %q", got, expected)
+		}
+		if got := c.(*Client).Stats().DroppedTags; got != tt.Dropped {
+			t.Errorf("%q: expected %d dropped tags, got %d", tt.Expected, tt.Dropped, got)
+		}
+	}
+}
+
+func TestTagFilterSubStatter(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:        "test",
+		DeniedTagKeys: []string{"user"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.NewSubStatter("sub").Inc("count", 1, 1.0, Tag{"user", "bob"})
+
+	expected := []string{"test.sub.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	// counts are shared with the parent client
+	if got := c.(*Client).Stats().DroppedTags; got != 1 {
+		t.Fatalf("expected 1 dropped tag, got %d", got)
+	}
+}
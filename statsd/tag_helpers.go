@@ -0,0 +1,46 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"errors"
+	"sort"
+)
+
+var errOddTags = errors.New("odd number of tag keys and values")
+
+// TagsFromMap returns the map entries as Tags, ordered by key, so that the
+// result is the same for the same map contents.
+func TagsFromMap(m map[string]string) []Tag {
+	if len(m) == 0 {
+		return nil
+	}
+
+	tags := make([]Tag, 0, len(m))
+	for k, v := range m {
+		tags = append(tags, Tag{k, v})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i][0] < tags[j][0]
+	})
+	return tags
+}
+
+// NewTags returns Tags from alternating keys and values, eg.
+//
+//	tags, err := statsd.NewTags("env", "prod", "region", "eu")
+//
+// An odd number of arguments returns an error.
+func NewTags(kvPairs ...string) ([]Tag, error) {
+	if len(kvPairs)%2 != 0 {
+		return nil, errOddTags
+	}
+
+	tags := make([]Tag, 0, len(kvPairs)/2)
+	for i := 0; i < len(kvPairs); i += 2 {
+		tags = append(tags, Tag{kvPairs[i], kvPairs[i+1]})
+	}
+	return tags, nil
+}
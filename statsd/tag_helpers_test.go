@@ -0,0 +1,41 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagsFromMap(t *testing.T) {
+	m := map[string]string{"region": "eu", "env": "prod", "host": "web1"}
+	expected := []Tag{{"env", "prod"}, {"host", "web1"}, {"region", "eu"}}
+
+	// map iteration order varies, so check the ordering holds repeatedly
+	for i := 0; i < 10; i++ {
+		if got := TagsFromMap(m); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("got %v expected %v", got, expected)
+		}
+	}
+
+	if got := TagsFromMap(nil); got != nil {
+		t.Fatalf("expected nil for an empty map, got %v", got)
+	}
+}
+
+func TestNewTags(t *testing.T) {
+	tags, err := NewTags("env", "prod", "region", "eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Tag{{"env", "prod"}, {"region", "eu"}}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("got %v expected %v", tags, expected)
+	}
+
+	if _, err := NewTags("env", "prod", "region"); err != errOddTags {
+		t.Fatalf("expected errOddTags, got %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package statsd
 
+import "strings"
+
 type Tag [2]string
 type TagFormat uint8
 
@@ -20,12 +22,71 @@ func (tf TagFormat) WriteInfix(data []byte, tags []Tag) []byte {
 			data = append(data, '=')
 			data = append(data, v[1]...)
 		}
+	case tf&InfixDotted != 0:
+		for _, v := range tags {
+			data = append(data, '.')
+			data = appendDottedLabel(data, v[0])
+			data = append(data, '.')
+			data = appendDottedLabel(data, v[1])
+		}
+	}
+
+	return data
+}
+
+// appendDottedLabel appends a tag key or value as a dotted name component,
+// replacing any '.' with an underscore, so it stays a single component.
+func appendDottedLabel(data []byte, label string) []byte {
+	if strings.IndexByte(label, '.') == -1 {
+		return append(data, label...)
 	}
 
+	for i := 0; i < len(label); i++ {
+		if label[i] == '.' {
+			data = append(data, '_')
+		} else {
+			data = append(data, label[i])
+		}
+	}
+	return data
+}
+
+// infixSeparator returns the byte that separates infix tags from the stat
+// name, or 0 if tf is not an infix format.
+func (tf TagFormat) infixSeparator() byte {
+	switch {
+	case tf&InfixComma != 0:
+		return ','
+	case tf&InfixSemicolon != 0:
+		return ';'
+	}
+	return 0
+}
+
+// appendName appends the stat name to data. With infix formats, any tag
+// separator in the name is replaced with an underscore, as it would
+// otherwise be parsed as the start of a tag.
+func (tf TagFormat) appendName(data []byte, name string) []byte {
+	sep := tf.infixSeparator()
+	if sep == 0 || strings.IndexByte(name, sep) == -1 {
+		return append(data, name...)
+	}
+
+	for i := 0; i < len(name); i++ {
+		if name[i] == sep {
+			data = append(data, '_')
+		} else {
+			data = append(data, name[i])
+		}
+	}
 	return data
 }
 
 func (tf TagFormat) WriteSuffix(data []byte, tags []Tag) []byte {
+	return tf.writeSuffix(data, tags, false)
+}
+
+func (tf TagFormat) writeSuffix(data []byte, tags []Tag, keyOnly bool) []byte {
 	switch {
 	// make the zero value useful
 	case tf == 0, tf&SuffixOctothorpe != 0:
@@ -33,8 +94,10 @@ func (tf TagFormat) WriteSuffix(data []byte, tags []Tag) []byte {
 		tlen := len(tags)
 		for i, v := range tags {
 			data = append(data, v[0]...)
-			data = append(data, ':')
-			data = append(data, v[1]...)
+			if !keyOnly || v[1] != "" {
+				data = append(data, ':')
+				data = append(data, v[1]...)
+			}
 			if tlen > 1 && i < tlen-1 {
 				data = append(data, ',')
 			}
@@ -48,7 +111,14 @@ const (
 	SuffixOctothorpe TagFormat = 1 << iota
 	InfixSemicolon
 	InfixComma
+	// InfixDotted writes each tag as two more dotted name components, the
+	// key then the value, after the stat name, eg.
+	// "prefix.stat.key1.val1.key2.val2:1|c". This suits the mapping rules
+	// of the Prometheus statsd_exporter, eg. "prefix.stat.*.*.*.*" mapped
+	// to labels by position. Any '.' in a tag key or value is replaced with
+	// an underscore.
+	InfixDotted
 
-	AllInfix  = InfixSemicolon | InfixComma
+	AllInfix  = InfixSemicolon | InfixComma | InfixDotted
 	AllSuffix = SuffixOctothorpe
 )
@@ -0,0 +1,49 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "context"
+
+type tagsContextKey struct{}
+
+// ContextWithTags returns a copy of ctx carrying the supplied tags, in
+// addition to any tags already carried by ctx.
+func ContextWithTags(ctx context.Context, tags ...Tag) context.Context {
+	existing := TagsFromContext(ctx)
+	merged := make([]Tag, 0, len(existing)+len(tags))
+	merged = append(merged, existing...)
+	merged = append(merged, tags...)
+	return context.WithValue(ctx, tagsContextKey{}, merged)
+}
+
+// TagsFromContext returns the tags carried by ctx, if any.
+func TagsFromContext(ctx context.Context) []Tag {
+	tags, _ := ctx.Value(tagsContextKey{}).([]Tag)
+	return tags
+}
+
+// WithContext returns a SubStatter that adds any tags carried by ctx (see
+// ContextWithTags) to every stat it submits. Any trace ID carried by ctx (see
+// ContextWithTraceID) is used for trace ID tags.
+//
+// Tags are written in order of precedence: the client default tags first,
+// then the context tags, then any per-call tags. Tags with duplicate keys are
+// handled by the client DuplicateTagPolicy (see ClientConfig.DuplicateTags):
+// by default all are kept, with per-call tags last on the wire, and
+// DuplicateTagLastWins lets per-call tags override context tags.
+func (s *Client) WithContext(ctx context.Context) SubStatter {
+	var c *Client
+	if s != nil {
+		c = s.NewSubStatter("").(*Client)
+		ctxTags := TagsFromContext(ctx)
+		if len(ctxTags) > 0 {
+			c.ctxTags = append(append([]Tag(nil), s.ctxTags...), ctxTags...)
+		}
+		if id := TraceIDFromContext(ctx); id != "" {
+			c.ctxTraceID = id
+		}
+	}
+	return c
+}
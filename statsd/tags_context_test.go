@@ -0,0 +1,99 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestContextWithTags(t *testing.T) {
+	ctx := ContextWithTags(context.Background(), Tag{"tenant", "acme"})
+	ctx = ContextWithTags(ctx, Tag{"region", "us"})
+
+	expected := []Tag{{"tenant", "acme"}, {"region", "us"}}
+	if got := TagsFromContext(ctx); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v expected %v", got, expected)
+	}
+
+	if got := TagsFromContext(context.Background()); got != nil {
+		t.Fatalf("expected no tags, got %v", got)
+	}
+}
+
+func TestClientContextTags(t *testing.T) {
+	tests := []struct {
+		TagFormat TagFormat
+		Expected  string
+	}{
+		{SuffixOctothorpe, "test.count:1|c|#env:prod,tenant:acme,tag1:val1"},
+		{InfixComma, "test.count,env=prod,tenant=acme,tag1=val1:1|c"},
+		{InfixSemicolon, "test.count;env=prod;tenant=acme;tag1=val1:1|c"},
+	}
+
+	l, err := newUDPListener("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ctx := ContextWithTags(context.Background(), Tag{"tenant", "acme"})
+	for _, tt := range tests {
+		config := &ClientConfig{
+			Address:   l.LocalAddr().String(),
+			Prefix:    "test",
+			TagFormat: tt.TagFormat,
+			Tags:      []Tag{{"env", "prod"}},
+		}
+		c, err := NewClientWithConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.(*Client).WithContext(ctx).Inc("count", 1, 1.0, Tag{"tag1", "val1"})
+		if err != nil {
+			c.Close()
+			t.Fatal(err)
+		}
+
+		data := make([]byte, 128)
+		n, _, err := l.ReadFrom(data)
+		if err != nil {
+			c.Close()
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data[:n], []byte(tt.Expected)) {
+			c.Close()
+			t.Fatalf("got '%s' expected '%s'", data[:n], tt.Expected)
+		}
+		c.Close()
+	}
+}
+
+func TestClientContextNoTags(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a context without tags leaves the client output unchanged
+	c.(*Client).WithContext(context.Background()).Inc("count", 1, 1.0)
+	// context tags do not leak back into the parent client
+	c.(*Client).WithContext(ContextWithTags(context.Background(), Tag{"a", "b"}))
+	c.Inc("count", 1, 1.0)
+
+	expected := []string{"test.count:1|c", "test.count:1|c"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+
+	var nilClient *Client
+	if err := nilClient.WithContext(context.Background()).Inc("count", 1, 1.0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import "context"
+
+// traceIDTagKey is the key of the tag carrying a trace ID
+const traceIDTagKey = "trace_id"
+
+type traceIDContextKey struct{}
+
+// ContextWithTraceID returns a copy of ctx carrying a trace ID, for stats
+// submitted via Client.WithContext to be tagged with (see
+// ClientConfig.TraceIDFraction).
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDContextKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID carried by ctx, if any.
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDContextKey{}).(string)
+	return id
+}
+
+// traceTagger tags a fraction of stats with the current trace ID (see
+// ClientConfig.TraceIDFraction). It is read-only once created, so is safe
+// for concurrent use.
+type traceTagger struct {
+	fraction float32
+	// trace ID source, for stats without a context trace ID. may be nil.
+	traceID func() string
+	// chooses the stats to tag, replaced in tests
+	sampler SamplerFunc
+}
+
+// newTraceTagger returns a traceTagger, or nil if fraction is 0, so tagging
+// can be skipped entirely.
+func newTraceTagger(fraction float32, traceID func() string) *traceTagger {
+	if fraction <= 0 {
+		return nil
+	}
+
+	return &traceTagger{
+		fraction: fraction,
+		traceID:  traceID,
+		sampler:  DefaultSampler,
+	}
+}
+
+// tag returns tags with a trace ID tag added, if the stat is chosen to carry
+// one and there is a trace ID. ctxID is the trace ID from the client context,
+// if any, which takes precedence over the traceID func. tags is not modified.
+func (t *traceTagger) tag(tags []Tag, ctxID string) []Tag {
+	if !t.sampler(t.fraction) {
+		return tags
+	}
+
+	id := ctxID
+	if id == "" && t.traceID != nil {
+		id = t.traceID()
+	}
+	if id == "" {
+		return tags
+	}
+	return append(tags[:len(tags):len(tags)], Tag{traceIDTagKey, id})
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTraceIDFraction(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:          "test",
+		TraceIDFraction: 0.25,
+		TraceIDFunc:     func() string { return "abc123" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const sends = 10000
+	for i := 0; i < sends; i++ {
+		c.Inc("count", 1, 1.0)
+	}
+
+	var tagged int
+	for _, stat := range rs.sent() {
+		switch stat {
+		case "test.count:1|c|#trace_id:abc123":
+			tagged++
+		case "test.count:1|c":
+		default:
+			t.Fatalf("unexpected stat %q", stat)
+		}
+	}
+	// well over 5 standard deviations either side
+	if fraction := float64(tagged) / sends; fraction < 0.22 || fraction > 0.28 {
+		t.Fatalf("expected about 25%% of stats tagged, got %.1f%%", fraction*100)
+	}
+}
+
+func TestTraceIDTags(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:          "test",
+		Tags:            []Tag{{"env", "prod"}},
+		TraceIDFraction: 0.5,
+		TraceIDFunc:     func() string { return "fromfunc" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := c.(*Client)
+	client.tracer.sampler = func(float32) bool { return true }
+
+	client.Inc("count", 1, 1.0, Tag{"tag1", "val1"})
+
+	// a context trace ID takes precedence
+	ctx := ContextWithTags(context.Background(), Tag{"route", "home"})
+	ctx = ContextWithTraceID(ctx, "fromctx")
+	client.WithContext(ctx).Inc("count", 1, 1.0)
+
+	// sampled out stats are not sent, so not tagged
+	client.SetSamplerFunc(func(float32) bool { return false })
+	client.Inc("count", 1, 0.5)
+
+	expected := []string{
+		"test.count:1|c|#env:prod,tag1:val1,trace_id:fromfunc",
+		"test.count:1|c|#env:prod,route:home,trace_id:fromctx",
+	}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
+
+func TestTraceIDDisabled(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := newClientWithConfig(rs, &ClientConfig{
+		Prefix:      "test",
+		TraceIDFunc: func() string { return "abc123" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Inc("count", 1, 1.0)
+	if got := rs.sent(); len(got) != 1 || strings.Contains(got[0], "trace_id") {
+		t.Fatalf("expected no trace ID tag without a fraction, got %q", got)
+	}
+}
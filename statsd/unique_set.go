@@ -0,0 +1,136 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// A UniqueSet collects set members client side, submitting each distinct
+// member once per Flush, rather than once per occurrence.
+//
+// If a maximum size is configured, memory use is bounded by sampling: once
+// more distinct members have been added than fit, only the members with the
+// smallest hashes are retained. A member's hash never changes, so an evicted
+// member added again is not retained again, and the retained members are a
+// uniform sample of the distinct members added, however often each is
+// added. This trades exactness for bounded memory, which is usually
+// acceptable for cardinality estimation.
+type UniqueSet struct {
+	client *Client
+	stat   string
+	max    int
+
+	mx      sync.Mutex
+	members memberHeap
+	index   map[string]struct{}
+}
+
+// hashedMember is a retained UniqueSet member, and its hash
+type hashedMember struct {
+	member string
+	hash   uint64
+}
+
+// memberHeap is a max-heap of retained members by hash, so that the member
+// with the largest hash, the next to evict, is always first.
+type memberHeap []hashedMember
+
+func (h memberHeap) Len() int           { return len(h) }
+func (h memberHeap) Less(i, j int) bool { return h[i].hash > h[j].hash }
+func (h memberHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *memberHeap) Push(x interface{}) {
+	*h = append(*h, x.(hashedMember))
+}
+
+func (h *memberHeap) Pop() interface{} {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
+
+// NewUniqueSet returns a UniqueSet that submits to stat.
+//
+// max is the maximum number of members retained between flushes. If max is
+// 0, the set is unbounded.
+func (s *Client) NewUniqueSet(stat string, max int) *UniqueSet {
+	return &UniqueSet{
+		client: s,
+		stat:   stat,
+		max:    max,
+		index:  make(map[string]struct{}),
+	}
+}
+
+// Add adds member to the set.
+func (u *UniqueSet) Add(member string) {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	if _, ok := u.index[member]; ok {
+		return
+	}
+
+	if u.max <= 0 {
+		// unbounded, so members are kept in the order added, and never
+		// need their hash
+		u.members = append(u.members, hashedMember{member: member})
+		u.index[member] = struct{}{}
+		return
+	}
+
+	h := memberHash(member)
+	switch {
+	case len(u.members) < u.max:
+		heap.Push(&u.members, hashedMember{member, h})
+	case h < u.members[0].hash:
+		// set is full, so replace the retained member with the largest
+		// hash, as member's is smaller
+		delete(u.index, u.members[0].member)
+		u.members[0] = hashedMember{member, h}
+		heap.Fix(&u.members, 0)
+	default:
+		return
+	}
+	u.index[member] = struct{}{}
+}
+
+// memberHash returns the 64 bit FNV-1a hash of member
+func memberHash(member string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(member); i++ {
+		h ^= uint64(member[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// Len returns the number of members currently retained.
+func (u *UniqueSet) Len() int {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+	return len(u.members)
+}
+
+// Flush submits each retained member as a set stat, and empties the set.
+// rate is the sample rate (0.0 to 1.0).
+func (u *UniqueSet) Flush(rate float32, tags ...Tag) error {
+	u.mx.Lock()
+	members := u.members
+	u.members = nil
+	u.index = make(map[string]struct{}, len(members))
+	u.mx.Unlock()
+
+	var ferr error
+	for _, m := range members {
+		if err := u.client.Set(u.stat, m.member, rate, tags...); err != nil && ferr == nil {
+			ferr = err
+		}
+	}
+	return ferr
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestUniqueSet(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := c.(*Client).NewUniqueSet("users", 0)
+	for _, m := range []string{"bob", "alice", "bob", "carol", "alice"} {
+		u.Add(m)
+	}
+	if err := u.Flush(1.0); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"test.users:bob|s", "test.users:alice|s", "test.users:carol|s"}
+	if got := rs.sent(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+	if u.Len() != 0 {
+		t.Fatal("expected flush to empty the set")
+	}
+}
+
+func TestUniqueSetBounded(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const max = 10
+	u := c.(*Client).NewUniqueSet("users", max)
+	for i := 0; i < 10000; i++ {
+		u.Add(strconv.Itoa(i))
+		if u.Len() > max {
+			t.Fatalf("retained %d members, more than max %d", u.Len(), max)
+		}
+	}
+	if err := u.Flush(1.0); err != nil {
+		t.Fatal(err)
+	}
+
+	got := rs.sent()
+	if len(got) != max {
+		t.Fatalf("expected %d members sent, got %d", max, len(got))
+	}
+	// retained members are all distinct
+	sort.Strings(got)
+	for i := 1; i < len(got); i++ {
+		if got[i] == got[i-1] {
+			t.Fatalf("duplicate member sent: %q", got[i])
+		}
+	}
+	// sampling should not just keep the first members seen
+	first := 0
+	for _, p := range got {
+		n, _ := strconv.Atoi(p[len("test.users:") : len(p)-len("|s")])
+		if n < max {
+			first++
+		}
+	}
+	if first == max {
+		t.Fatal("expected sampling to retain members beyond the first few")
+	}
+}
+
+func TestUniqueSetReoffered(t *testing.T) {
+	retained := func(order []int) []string {
+		rs := &recordingSender{}
+		c, err := NewClientWithSender(rs, "test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := c.(*Client).NewUniqueSet("users", 10)
+		for _, i := range order {
+			u.Add(strconv.Itoa(i))
+		}
+		u.Flush(1.0)
+		got := rs.sent()
+		sort.Strings(got)
+		return got
+	}
+
+	// the same distinct members are retained, whatever the order, and
+	// however often evicted members are added again
+	var once, repeated []int
+	for i := 0; i < 100; i++ {
+		once = append(once, i)
+	}
+	for n := 0; n < 5; n++ {
+		for i := 99; i >= 0; i-- {
+			repeated = append(repeated, i)
+		}
+	}
+	if a, b := retained(once), retained(repeated); !reflect.DeepEqual(a, b) {
+		t.Fatalf("retained %q and %q", a, b)
+	}
+}
+
+func TestUniqueSetSmallestHashes(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const max = 10
+	u := c.(*Client).NewUniqueSet("users", max)
+	members := make([]string, 1000)
+	for i := range members {
+		members[i] = strconv.Itoa(i)
+		u.Add(members[i])
+	}
+	u.Flush(1.0)
+
+	// exactly the members with the smallest hashes are retained
+	sort.Slice(members, func(i, j int) bool {
+		return memberHash(members[i]) < memberHash(members[j])
+	})
+	var expected []string
+	for _, m := range members[:max] {
+		expected = append(expected, "test.users:"+m+"|s")
+	}
+	sort.Strings(expected)
+	got := rs.sent()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q expected %q", got, expected)
+	}
+}
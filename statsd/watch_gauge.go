@@ -0,0 +1,54 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultWatchGaugeInterval is the period of WatchGauge with an interval
+// <= 0.
+const defaultWatchGaugeInterval = time.Second
+
+// WatchGauge calls fn once every interval (1 second if <= 0), and submits the
+// value it returns as a gauge, until the returned stop func is called. It is
+// useful for reporting values that can be sampled at any time, such as
+// channel or queue depths. eg.
+//
+//	stop := client.WatchGauge("queue.depth", time.Second, func() int64 {
+//		return int64(len(queue))
+//	})
+//	defer stop()
+//
+// stop is safe to call more than once.
+func (s *Client) WatchGauge(stat string, interval time.Duration, fn func() int64, tags ...Tag) (stop func()) {
+	if interval <= 0 {
+		interval = defaultWatchGaugeInterval
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.Gauge(stat, fn(), 1.0, tags...)
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
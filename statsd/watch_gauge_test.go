@@ -0,0 +1,72 @@
+// Copyright (c) 2012-2016 Eli Janssen
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package statsd
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchGauge(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int64
+	stop := c.(*Client).WatchGauge("depth", time.Millisecond, func() int64 {
+		atomic.AddInt64(&calls, 1)
+		return 42
+	}, Tag{"tag1", "val1"})
+
+	deadline := time.Now().Add(time.Second)
+	for len(rs.sent()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for gauges")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop() // safe to call again
+
+	for _, stat := range rs.sent() {
+		if stat != "test.depth:42|g|#tag1:val1" {
+			t.Fatalf("unexpected gauge %q", stat)
+		}
+	}
+
+	// no more calls once stopped. allow for a tick in progress at stop.
+	time.Sleep(5 * time.Millisecond)
+	n := atomic.LoadInt64(&calls)
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt64(&calls) != n {
+		t.Fatal("fn called after stop")
+	}
+}
+
+func TestWatchGaugeDefaultInterval(t *testing.T) {
+	rs := &recordingSender{}
+	c, err := NewClientWithSender(rs, "test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an interval <= 0 is defaulted, rather than panicking
+	stop := c.(*Client).WatchGauge("depth", 0, func() int64 { return 42 })
+	defer stop()
+
+	deadline := time.Now().Add(2 * defaultWatchGaugeInterval)
+	for len(rs.sent()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the gauge")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if sent := rs.sent(); sent[0] != "test.depth:42|g" {
+		t.Fatalf("unexpected gauge %q", sent[0])
+	}
+}